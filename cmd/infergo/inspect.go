@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+)
+
+// modelInfo is the summary printed by the inspect command
+type modelInfo struct {
+	Path            string            `json:"path"`
+	IRVersion       int64             `json:"ir_version"`
+	Producer        string            `json:"producer,omitempty"`
+	ProducerVersion string            `json:"producer_version,omitempty"`
+	ModelVersion    int64             `json:"model_version,omitempty"`
+	Opsets          map[string]int64  `json:"opsets"`
+	Inputs          []valueInfo       `json:"inputs"`
+	Outputs         []valueInfo       `json:"outputs"`
+	Initializers    initializerInfo   `json:"initializers"`
+	Operators       []operatorCount   `json:"operators"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+type valueInfo struct {
+	Name  string   `json:"name"`
+	Kind  string   `json:"kind"`
+	Type  string   `json:"type,omitempty"`
+	Shape []string `json:"shape"`
+}
+
+type initializerInfo struct {
+	Count   int               `json:"count"`
+	Bytes   int64             `json:"bytes"`
+	Largest []initializerSize `json:"largest"`
+}
+
+type initializerSize struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Dims  []int64 `json:"dims"`
+	Bytes int64   `json:"bytes"`
+}
+
+type operatorCount struct {
+	OpType string `json:"op_type"`
+	Count  int    `json:"count"`
+}
+
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format (text or json)")
+	top := fs.Int("top", 10, "Number of largest initializers to list")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: infergo inspect [flags] model.onnx")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	switch {
+	case fs.NArg() == 0:
+		fs.Usage()
+		return errors.New("expected a model path")
+	case fs.NArg() > 1:
+		// the flag package stops at the first argument that is not a flag
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments after the model path %q: %q, flags must come before the path", fs.Arg(0), fs.Args()[1:])
+	}
+
+	model, err := onnxproto.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	info := summarize(fs.Arg(0), model, *top)
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	case "text":
+		printInfo(os.Stdout, info)
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+func summarize(path string, model *onnxproto.Model, top int) *modelInfo {
+	info := &modelInfo{
+		Path:            path,
+		IRVersion:       model.IRVersion,
+		Producer:        model.ProducerName,
+		ProducerVersion: model.ProducerVersion,
+		ModelVersion:    model.ModelVersion,
+		Opsets:          make(map[string]int64),
+		Metadata:        model.MetadataProps,
+	}
+
+	for _, opset := range model.OpsetImports {
+		domain := opset.Domain
+		if domain == "" {
+			domain = "ai.onnx"
+		}
+		info.Opsets[domain] = opset.Version
+	}
+
+	for _, input := range model.Graph.RuntimeInputs() {
+		info.Inputs = append(info.Inputs, newValueInfo(input))
+	}
+	for _, output := range model.Graph.Outputs {
+		info.Outputs = append(info.Outputs, newValueInfo(output))
+	}
+
+	sizes := make([]initializerSize, 0, len(model.Graph.Initializers))
+	for _, init := range model.Graph.Initializers {
+		size := initializerSize{Name: init.Name, Type: init.DataType.String(), Dims: init.Dims, Bytes: init.ByteSize()}
+		sizes = append(sizes, size)
+		info.Initializers.Bytes += size.Bytes
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].Bytes > sizes[j].Bytes
+	})
+	if top < len(sizes) {
+		sizes = sizes[:top]
+	}
+	info.Initializers.Count = len(model.Graph.Initializers)
+	info.Initializers.Largest = sizes
+
+	for opType, count := range model.Graph.OpHistogram() {
+		info.Operators = append(info.Operators, operatorCount{OpType: opType, Count: count})
+	}
+	sort.Slice(info.Operators, func(i, j int) bool {
+		if info.Operators[i].Count != info.Operators[j].Count {
+			return info.Operators[i].Count > info.Operators[j].Count
+		}
+		return info.Operators[i].OpType < info.Operators[j].OpType
+	})
+	return info
+}
+
+func newValueInfo(v *onnxproto.ValueInfo) valueInfo {
+	info := valueInfo{Name: v.Name, Kind: v.Kind.String(), Shape: []string{}}
+	if v.Kind == onnxproto.KindTensor || v.Kind == onnxproto.KindSparseTensor {
+		info.Type = v.ElemType.String()
+	}
+	for _, dim := range v.Shape {
+		info.Shape = append(info.Shape, dim.String())
+	}
+	return info
+}
+
+func printInfo(w io.Writer, info *modelInfo) {
+	fmt.Fprintf(w, "Model:        %s\n", info.Path)
+	fmt.Fprintf(w, "IR version:   %d\n", info.IRVersion)
+	if info.Producer != "" {
+		fmt.Fprintf(w, "Producer:     %s %s\n", info.Producer, info.ProducerVersion)
+	}
+	if info.ModelVersion != 0 {
+		fmt.Fprintf(w, "Version:      %d\n", info.ModelVersion)
+	}
+
+	domains := make([]string, 0, len(info.Opsets))
+	for domain := range info.Opsets {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	opsets := make([]string, len(domains))
+	for i, domain := range domains {
+		opsets[i] = fmt.Sprintf("%s=%d", domain, info.Opsets[domain])
+	}
+	fmt.Fprintf(w, "Opsets:       %s\n", strings.Join(opsets, ", "))
+
+	fmt.Fprintln(w, "\nInputs:")
+	for _, input := range info.Inputs {
+		fmt.Fprintf(w, "  %s\n", formatValue(input))
+	}
+
+	fmt.Fprintln(w, "\nOutputs:")
+	for _, output := range info.Outputs {
+		fmt.Fprintf(w, "  %s\n", formatValue(output))
+	}
+
+	fmt.Fprintf(w, "\nInitializers: %d (%s)\n", info.Initializers.Count, formatBytes(info.Initializers.Bytes))
+	for _, init := range info.Initializers.Largest {
+		fmt.Fprintf(w, "  %-48s %-8s %-20v %s\n", init.Name, init.Type, init.Dims, formatBytes(init.Bytes))
+	}
+
+	total := 0
+	for _, op := range info.Operators {
+		total += op.Count
+	}
+	fmt.Fprintf(w, "\nOperators: %d nodes\n", total)
+	for _, op := range info.Operators {
+		fmt.Fprintf(w, "  %-40s %d\n", op.OpType, op.Count)
+	}
+
+	if len(info.Metadata) > 0 {
+		keys := make([]string, 0, len(info.Metadata))
+		for key := range info.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(w, "\nMetadata:")
+		for _, key := range keys {
+			fmt.Fprintf(w, "  %s: %s\n", key, info.Metadata[key])
+		}
+	}
+}
+
+func formatValue(v valueInfo) string {
+	if v.Type == "" {
+		return fmt.Sprintf("%s: %s", v.Name, v.Kind)
+	}
+	return fmt.Sprintf("%s: %s [%s]", v.Name, v.Type, strings.Join(v.Shape, ", "))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
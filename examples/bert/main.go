@@ -31,7 +31,7 @@ func main() {
 
 	text := "The [MASK] is a large animal that lives in the [MASK]."
 
-	tokenOutput, err := tok.Encode(text, 512)
+	tokenOutput, err := tok.Encode(text, 512, tokenizer.WithPadding(tokenizer.DoNotPad))
 	if err != nil {
 		log.Fatal(err)
 	}
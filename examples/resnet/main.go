@@ -65,19 +65,20 @@ func main() {
 		log.Fatalf("Failed to preprocess image: %v", err)
 	}
 
-	output, err := model.Run(&resnet.Input{Pixels: processedImg.Pixels})
-	if err != nil {
-		log.Fatalf("Failed to run inference: %v", err)
-	}
-
-	classifications, err := postprocess.ProcessClassification(output.Logits, postprocess.ClassificationOptions{
-		Labels:   labels.ImageNetLabels,
+	opts := postprocess.ClassificationOptions{
 		TopK:     *topK,
 		MinScore: float32(*confidenceThreshold),
 		Softmax:  true,
-	})
+	}
+	// Classify names classes with the id2label embedded in the model, so only
+	// exports without it need the ImageNet labels
+	if md, err := model.ModelMetadata(); err != nil || md.Labels() == nil {
+		opts.Labels = labels.ImageNetLabels
+	}
+
+	classifications, err := model.Classify(&resnet.Input{Pixels: processedImg.Pixels}, opts)
 	if err != nil {
-		log.Fatalf("Failed to process results: %v", err)
+		log.Fatalf("Failed to classify image: %v", err)
 	}
 
 	fmt.Printf("\nTop %d predictions for %s:\n", *topK, *imagePath)
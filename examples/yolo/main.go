@@ -105,30 +105,26 @@ func main() {
 		log.Fatalf("Failed to preprocess image: %v", err)
 	}
 
-	output, err := model.Run(&yolo.Input{
+	opts := postprocess.DetectionOptions{
+		ConfThreshold: float32(*confidenceThreshold),
+		IoUThreshold:  0.45,
+		MaxDetections: 100,
+	}
+	// Detect names classes with the id2label embedded in the model, so only
+	// exports without it need the COCO labels
+	if md, err := model.ModelMetadata(); err != nil || md.Labels() == nil {
+		opts.Labels = labels.COCOLabels
+	}
+
+	detections, err := model.Detect(&yolo.Input{
 		Height: processedImg.Height,
 		Width:  processedImg.Width,
 		Pixels: processedImg.Pixels,
-	})
+	}, processedImg.OrigSize, opts)
 	if err != nil {
 		log.Fatalf("Failed to run detection: %v", err)
 	}
 
-	detections, err := postprocess.ProcessDetections(
-		output.Logits,
-		output.Boxes,
-		processedImg.OrigSize,
-		postprocess.DetectionOptions{
-			ConfThreshold: float32(*confidenceThreshold),
-			IoUThreshold:  0.45,
-			MaxDetections: 100,
-			Labels:        labels.COCOLabels,
-		},
-	)
-	if err != nil {
-		log.Fatalf("Failed to process detections: %v", err)
-	}
-
 	bounds := img.Bounds()
 	rgba := image.NewRGBA(bounds)
 	draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
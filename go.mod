@@ -3,6 +3,7 @@ module github.com/joeychilson/infergo
 go 1.23.3
 
 require (
-	github.com/yalue/onnxruntime_go v1.13.0
+	github.com/yalue/onnxruntime_go v1.27.0
 	golang.org/x/image v0.22.0
+	golang.org/x/text v0.21.0
 )
@@ -0,0 +1,291 @@
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WireType is the protobuf wire type of a field
+type WireType int
+
+const (
+	Varint  WireType = 0
+	Fixed64 WireType = 1
+	Bytes   WireType = 2
+	Fixed32 WireType = 5
+)
+
+var (
+	// ErrTruncated is returned when a message ends in the middle of a field
+	ErrTruncated = errors.New("protowire: truncated message")
+	// ErrOverflow is returned for varints longer than ten bytes or larger than 64 bits
+	ErrOverflow = errors.New("protowire: varint overflows 64 bits")
+)
+
+// uvarint decodes a varint from the start of buf and returns its length
+func uvarint(buf []byte) (uint64, int, error) {
+	value, n := binary.Uvarint(buf)
+	switch {
+	case n == 0:
+		return 0, 0, ErrTruncated
+	case n < 0:
+		return 0, 0, ErrOverflow
+	}
+	return value, n, nil
+}
+
+// Field is a single decoded protobuf field
+type Field struct {
+	Number int
+	Type   WireType
+	// Value holds the raw value of varint, fixed32 and fixed64 fields
+	Value uint64
+	// Bytes holds the payload of length-delimited fields
+	Bytes []byte
+}
+
+// Int64 returns the field as a two's complement int64
+func (f Field) Int64() int64 {
+	return int64(f.Value)
+}
+
+// Int32 returns the field as a two's complement int32
+func (f Field) Int32() int32 {
+	return int32(f.Value)
+}
+
+// Bool returns the field as a bool
+func (f Field) Bool() bool {
+	return f.Value != 0
+}
+
+// Float32 returns a fixed32 field as a float32
+func (f Field) Float32() float32 {
+	return math.Float32frombits(uint32(f.Value))
+}
+
+// Float64 returns a fixed64 field as a float64
+func (f Field) Float64() float64 {
+	return math.Float64frombits(f.Value)
+}
+
+// String returns a length-delimited field as a string
+func (f Field) String() string {
+	return string(f.Bytes)
+}
+
+// Reader iterates over the fields of an encoded protobuf message
+type Reader struct {
+	buf []byte
+}
+
+// NewReader creates a new Reader for the encoded message
+func NewReader(buf []byte) *Reader {
+	return &Reader{buf: buf}
+}
+
+// Next returns the next field in the message, or io.EOF when the message is exhausted
+func (r *Reader) Next() (Field, error) {
+	if len(r.buf) == 0 {
+		return Field{}, io.EOF
+	}
+
+	tag, n, err := uvarint(r.buf)
+	if err != nil {
+		return Field{}, err
+	}
+	r.buf = r.buf[n:]
+
+	field := Field{Number: int(tag >> 3), Type: WireType(tag & 7)}
+	if field.Number <= 0 {
+		return Field{}, fmt.Errorf("protowire: invalid field number %d", field.Number)
+	}
+
+	switch field.Type {
+	case Varint:
+		value, n, err := uvarint(r.buf)
+		if err != nil {
+			return Field{}, err
+		}
+		field.Value = value
+		r.buf = r.buf[n:]
+	case Fixed64:
+		if len(r.buf) < 8 {
+			return Field{}, ErrTruncated
+		}
+		field.Value = binary.LittleEndian.Uint64(r.buf)
+		r.buf = r.buf[8:]
+	case Fixed32:
+		if len(r.buf) < 4 {
+			return Field{}, ErrTruncated
+		}
+		field.Value = uint64(binary.LittleEndian.Uint32(r.buf))
+		r.buf = r.buf[4:]
+	case Bytes:
+		length, n, err := uvarint(r.buf)
+		if err != nil {
+			return Field{}, err
+		}
+		if uint64(len(r.buf)-n) < length {
+			return Field{}, ErrTruncated
+		}
+		field.Bytes = r.buf[n : n+int(length)]
+		r.buf = r.buf[n+int(length):]
+	default:
+		return Field{}, fmt.Errorf("protowire: unsupported wire type %d for field %d", field.Type, field.Number)
+	}
+	return field, nil
+}
+
+// Walk calls fn for every field in the message
+func Walk(buf []byte, fn func(Field) error) error {
+	r := NewReader(buf)
+	for {
+		field, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+}
+
+// DecodeVarints appends a repeated varint field, which may be packed or unpacked
+func DecodeVarints(dst []uint64, f Field) ([]uint64, error) {
+	if f.Type != Bytes {
+		return append(dst, f.Value), nil
+	}
+	buf := f.Bytes
+	for len(buf) > 0 {
+		value, n, err := uvarint(buf)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, value)
+		buf = buf[n:]
+	}
+	return dst, nil
+}
+
+// DecodeInt64s appends a repeated int64 field, which may be packed or unpacked
+func DecodeInt64s(dst []int64, f Field) ([]int64, error) {
+	values, err := DecodeVarints(nil, f)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		dst = append(dst, int64(value))
+	}
+	return dst, nil
+}
+
+// DecodeInt32s appends a repeated int32 field, which may be packed or unpacked
+func DecodeInt32s(dst []int32, f Field) ([]int32, error) {
+	values, err := DecodeVarints(nil, f)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		dst = append(dst, int32(value))
+	}
+	return dst, nil
+}
+
+// DecodeFloat32s appends a repeated float field, which may be packed or unpacked
+func DecodeFloat32s(dst []float32, f Field) ([]float32, error) {
+	if f.Type != Bytes {
+		return append(dst, f.Float32()), nil
+	}
+	if len(f.Bytes)%4 != 0 {
+		return nil, ErrTruncated
+	}
+	for i := 0; i < len(f.Bytes); i += 4 {
+		dst = append(dst, math.Float32frombits(binary.LittleEndian.Uint32(f.Bytes[i:])))
+	}
+	return dst, nil
+}
+
+// DecodeFloat64s appends a repeated double field, which may be packed or unpacked
+func DecodeFloat64s(dst []float64, f Field) ([]float64, error) {
+	if f.Type != Bytes {
+		return append(dst, f.Float64()), nil
+	}
+	if len(f.Bytes)%8 != 0 {
+		return nil, ErrTruncated
+	}
+	for i := 0; i < len(f.Bytes); i += 8 {
+		dst = append(dst, math.Float64frombits(binary.LittleEndian.Uint64(f.Bytes[i:])))
+	}
+	return dst, nil
+}
+
+// AppendTag appends a field tag
+func AppendTag(b []byte, number int, wireType WireType) []byte {
+	return binary.AppendUvarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+// AppendVarint appends a varint field
+func AppendVarint(b []byte, number int, value uint64) []byte {
+	b = AppendTag(b, number, Varint)
+	return binary.AppendUvarint(b, value)
+}
+
+// AppendFixed32 appends a fixed32 field
+func AppendFixed32(b []byte, number int, value uint32) []byte {
+	b = AppendTag(b, number, Fixed32)
+	return binary.LittleEndian.AppendUint32(b, value)
+}
+
+// AppendFixed64 appends a fixed64 field
+func AppendFixed64(b []byte, number int, value uint64) []byte {
+	b = AppendTag(b, number, Fixed64)
+	return binary.LittleEndian.AppendUint64(b, value)
+}
+
+// AppendBytes appends a length-delimited field
+func AppendBytes(b []byte, number int, value []byte) []byte {
+	b = AppendTag(b, number, Bytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// AppendString appends a length-delimited string field
+func AppendString(b []byte, number int, value string) []byte {
+	b = AppendTag(b, number, Bytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// AppendPackedVarints appends a packed repeated varint field
+func AppendPackedVarints(b []byte, number int, values []uint64) []byte {
+	var packed []byte
+	for _, value := range values {
+		packed = binary.AppendUvarint(packed, value)
+	}
+	return AppendBytes(b, number, packed)
+}
+
+// AppendPackedFixed32 appends a packed repeated fixed32 field
+func AppendPackedFixed32(b []byte, number int, values []uint32) []byte {
+	packed := make([]byte, 0, 4*len(values))
+	for _, value := range values {
+		packed = binary.LittleEndian.AppendUint32(packed, value)
+	}
+	return AppendBytes(b, number, packed)
+}
+
+// AppendPackedFixed64 appends a packed repeated fixed64 field
+func AppendPackedFixed64(b []byte, number int, values []uint64) []byte {
+	packed := make([]byte, 0, 8*len(values))
+	for _, value := range values {
+		packed = binary.LittleEndian.AppendUint64(packed, value)
+	}
+	return AppendBytes(b, number, packed)
+}
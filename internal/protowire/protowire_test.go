@@ -0,0 +1,183 @@
+package protowire
+
+import (
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want []Field
+		err  error
+	}{
+		{
+			name: "varint",
+			buf:  AppendVarint(nil, 1, 150),
+			want: []Field{{Number: 1, Type: Varint, Value: 150}},
+		},
+		{
+			name: "max varint",
+			buf:  AppendVarint(nil, 3, math.MaxUint64),
+			want: []Field{{Number: 3, Type: Varint, Value: math.MaxUint64}},
+		},
+		{
+			// negative int64 values are encoded as ten byte two's complement varints
+			name: "negative int64",
+			buf:  AppendVarint(nil, 2, math.MaxUint64-4),
+			want: []Field{{Number: 2, Type: Varint, Value: math.MaxUint64 - 4}},
+		},
+		{
+			name: "fixed32",
+			buf:  AppendFixed32(nil, 4, math.Float32bits(1.5)),
+			want: []Field{{Number: 4, Type: Fixed32, Value: uint64(math.Float32bits(1.5))}},
+		},
+		{
+			name: "fixed64",
+			buf:  AppendFixed64(nil, 5, math.Float64bits(-2.25)),
+			want: []Field{{Number: 5, Type: Fixed64, Value: math.Float64bits(-2.25)}},
+		},
+		{
+			name: "length delimited",
+			buf:  AppendString(AppendBytes(nil, 7, nil), 8, "onnx"),
+			want: []Field{{Number: 7, Type: Bytes, Bytes: []byte{}}, {Number: 8, Type: Bytes, Bytes: []byte("onnx")}},
+		},
+		{
+			name: "large field number",
+			buf:  AppendVarint(nil, 1<<28, 1),
+			want: []Field{{Number: 1 << 28, Type: Varint, Value: 1}},
+		},
+		{name: "empty", buf: nil},
+		{name: "truncated tag", buf: []byte{0x80}, err: ErrTruncated},
+		{name: "truncated varint", buf: []byte{0x08, 0x96}, err: ErrTruncated},
+		{name: "truncated fixed32", buf: []byte{0x25, 1, 2, 3}, err: ErrTruncated},
+		{name: "truncated fixed64", buf: []byte{0x29, 1, 2, 3, 4, 5, 6, 7}, err: ErrTruncated},
+		{name: "truncated length", buf: []byte{0x12}, err: ErrTruncated},
+		{name: "length past end", buf: []byte{0x12, 0x05, 'a', 'b'}, err: ErrTruncated},
+		{name: "huge length", buf: []byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, err: ErrTruncated},
+		{name: "overlong varint", buf: []byte{0x08, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, err: ErrOverflow},
+		{name: "varint over 64 bits", buf: []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, err: ErrOverflow},
+		{name: "overlong length", buf: []byte{0x12, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, err: ErrOverflow},
+		{name: "field number zero", buf: []byte{0x00, 0x01}, err: errors.New("protowire: invalid field number 0")},
+		{name: "group wire type", buf: []byte{0x0b}, err: errors.New("protowire: unsupported wire type 3 for field 1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(tt.buf)
+			var got []Field
+			var err error
+			for {
+				var f Field
+				if f, err = r.Next(); err != nil {
+					break
+				}
+				got = append(got, f)
+			}
+			if tt.err == nil {
+				if err != io.EOF {
+					t.Fatalf("Next() error = %v, want io.EOF", err)
+				}
+			} else if !errors.Is(err, tt.err) && (err == nil || err.Error() != tt.err.Error()) {
+				t.Fatalf("Next() error = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldConversions(t *testing.T) {
+	if got := (Field{Value: uint64(math.MaxUint64)}).Int64(); got != -1 {
+		t.Errorf("Int64() = %d, want -1", got)
+	}
+	if got := (Field{Value: uint64(math.MaxUint64)}).Int32(); got != -1 {
+		t.Errorf("Int32() = %d, want -1", got)
+	}
+	if got := (Field{Value: uint64(math.Float32bits(0.5))}).Float32(); got != 0.5 {
+		t.Errorf("Float32() = %v, want 0.5", got)
+	}
+	if got := (Field{Value: math.Float64bits(0.25)}).Float64(); got != 0.25 {
+		t.Errorf("Float64() = %v, want 0.25", got)
+	}
+	if !(Field{Value: 2}).Bool() || (Field{}).Bool() {
+		t.Error("Bool() is wrong")
+	}
+}
+
+func TestDecodeRepeated(t *testing.T) {
+	// repeated fields may be packed into one length-delimited field or repeated
+	// as single values
+	packed := func(b []byte) Field {
+		f, err := NewReader(b).Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		return f
+	}
+
+	ints, err := DecodeInt64s(nil, packed(AppendPackedVarints(nil, 1, []uint64{1, 300, math.MaxUint64})))
+	if err != nil || !reflect.DeepEqual(ints, []int64{1, 300, -1}) {
+		t.Errorf("DecodeInt64s(packed) = %v, %v", ints, err)
+	}
+	ints, err = DecodeInt64s(ints, Field{Type: Varint, Value: 7})
+	if err != nil || !reflect.DeepEqual(ints, []int64{1, 300, -1, 7}) {
+		t.Errorf("DecodeInt64s(unpacked) = %v, %v", ints, err)
+	}
+	int32s, err := DecodeInt32s(nil, packed(AppendPackedVarints(nil, 1, []uint64{uint64(math.MaxUint64 - 1)})))
+	if err != nil || !reflect.DeepEqual(int32s, []int32{-2}) {
+		t.Errorf("DecodeInt32s() = %v, %v", int32s, err)
+	}
+
+	floats, err := DecodeFloat32s(nil, packed(AppendPackedFixed32(nil, 1, []uint32{math.Float32bits(1), math.Float32bits(-0.5)})))
+	if err != nil || !reflect.DeepEqual(floats, []float32{1, -0.5}) {
+		t.Errorf("DecodeFloat32s() = %v, %v", floats, err)
+	}
+	doubles, err := DecodeFloat64s(nil, packed(AppendPackedFixed64(nil, 1, []uint64{math.Float64bits(3)})))
+	if err != nil || !reflect.DeepEqual(doubles, []float64{3}) {
+		t.Errorf("DecodeFloat64s() = %v, %v", doubles, err)
+	}
+
+	errs := []struct {
+		name string
+		err  error
+	}{
+		{"varints", func() error { _, err := DecodeVarints(nil, Field{Type: Bytes, Bytes: []byte{0x80}}); return err }()},
+		{"overlong varints", func() error {
+			_, err := DecodeVarints(nil, Field{Type: Bytes, Bytes: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}})
+			return err
+		}()},
+		{"float32s", func() error { _, err := DecodeFloat32s(nil, Field{Type: Bytes, Bytes: []byte{1, 2, 3}}); return err }()},
+		{"float64s", func() error { _, err := DecodeFloat64s(nil, Field{Type: Bytes, Bytes: []byte{1, 2, 3, 4}}); return err }()},
+	}
+	for _, e := range errs {
+		if e.err == nil {
+			t.Errorf("decoding malformed packed %s succeeded", e.name)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	buf := AppendString(AppendVarint(nil, 1, 1), 2, "x")
+	var numbers []int
+	err := Walk(buf, func(f Field) error {
+		numbers = append(numbers, f.Number)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(numbers, []int{1, 2}) {
+		t.Errorf("Walk() = %v, %v", numbers, err)
+	}
+
+	stop := errors.New("stop")
+	if err := Walk(buf, func(Field) error { return stop }); err != stop {
+		t.Errorf("Walk() error = %v, want the callback error", err)
+	}
+	if err := Walk(append(buf, 0x08), func(Field) error { return nil }); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Walk() error = %v, want ErrTruncated", err)
+	}
+}
@@ -4,79 +4,213 @@ package bert
 import (
 	"fmt"
 
-	ort "github.com/yalue/onnxruntime_go"
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/onnx"
 )
 
+var (
+	inputNames  = []string{"input_ids", "attention_mask"}
+	outputNames = []string{"logits"}
+)
+
+// tokenTypeInputName is the optional segment input of models with token type embeddings
+const tokenTypeInputName = "token_type_ids"
+
+// warmupSequenceLength is the sequence length used for synthetic warmup inputs
+const warmupSequenceLength = 128
+
 // Model represents a BERT model
 type Model struct {
-	session *ort.DynamicAdvancedSession
+	session    backend.Session
+	tokenTypes bool
+	observer   metrics.Observer
+	name       string
+}
+
+// Option configures a BERT model
+type Option func(*options)
+
+type options struct {
+	tokenTypes     bool
+	sessionOptions []onnx.SessionOption
+}
+
+// WithTokenTypeIds binds the token_type_ids input of models that use segment
+// embeddings, such as BERT models for sentence pairs. DistilBERT and RoBERTa
+// models do not have this input.
+func WithTokenTypeIds() Option {
+	return func(o *options) {
+		o.tokenTypes = true
+	}
+}
+
+// WithSessionOptions configures the ONNX Runtime session that New creates.
+// NewWithBackend ignores them, as its backend is already configured.
+func WithSessionOptions(opts ...onnx.SessionOption) Option {
+	return func(o *options) {
+		o.sessionOptions = append(o.sessionOptions, opts...)
+	}
 }
 
 // Input represents the input data for BERT inference
 type Input struct {
 	InputIds      []int64
 	AttentionMask []int64
+	// TokenTypeIds is optional. It is zero-filled when the model takes token type
+	// ids and ignored when it does not.
+	TokenTypeIds []int64
+	// BatchSize is the number of sequences in the row-major inputs, such as the
+	// output of EncodeBatch. Zero means a single sequence.
+	BatchSize int
 }
 
 // Output represents the output data from BERT inference
 type Output struct {
+	// Logits is row-major with the batch as its first dimension
 	Logits []float32
 }
 
-// New creates a new BERT model instance
-func New(modelPath string) (*Model, error) {
-	sessionOptions, err := ort.NewSessionOptions()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create session options: %w", err)
+// New creates a new BERT model instance running on ONNX Runtime
+func New(modelPath string, opts ...Option) (*Model, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return NewWithBackend(onnx.NewBackend(o.sessionOptions...), modelPath, opts...)
+}
+
+// NewWithBackend creates a new BERT model instance running on the given backend
+func NewWithBackend(b backend.Backend, modelPath string, opts ...Option) (*Model, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
 	}
-	defer sessionOptions.Destroy()
 
-	session, err := ort.NewDynamicAdvancedSession(
-		modelPath,
-		[]string{"input_ids", "attention_mask"},
-		[]string{"logits"},
-		sessionOptions,
-	)
+	names := inputNames
+	if o.tokenTypes {
+		names = append(append([]string(nil), inputNames...), tokenTypeInputName)
+	}
+	session, err := b.NewSession(modelPath, names, outputNames)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, err
 	}
+	return NewWithSession(session), nil
+}
 
-	return &Model{session: session}, nil
+// NewWithSession creates a new BERT model instance running on an existing session.
+// The session must take input_ids, attention_mask and optionally token_type_ids
+// and return logits.
+func NewWithSession(session backend.Session) *Model {
+	observer, name := metrics.ObserverOf(session)
+	return &Model{session: session, tokenTypes: len(session.Inputs()) > len(inputNames), observer: observer, name: name}
 }
 
 // Run performs inference on the input data
 func (m *Model) Run(input *Input) (*Output, error) {
-	inputIdsShape := ort.NewShape(1, int64(len(input.InputIds)))
-	inputIdsTensor, err := ort.NewTensor(inputIdsShape, input.InputIds)
+	batchSize := max(input.BatchSize, 1)
+	var tensors []*backend.Tensor
+	err := metrics.Track(m.observer, m.name, metrics.StagePreprocess, batchSize, func() (err error) {
+		tensors, err = m.tensors(input)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create input_ids tensor: %w", err)
+		return nil, err
 	}
-	defer inputIdsTensor.Destroy()
-
-	attentionMaskShape := ort.NewShape(1, int64(len(input.AttentionMask)))
-	attentionMaskTensor, err := ort.NewTensor(attentionMaskShape, input.AttentionMask)
+	outputs, err := m.session.Run(tensors)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create attention_mask tensor: %w", err)
+		return nil, err
 	}
-	defer attentionMaskTensor.Destroy()
 
-	inputs := []ort.Value{inputIdsTensor, attentionMaskTensor}
-	outputs := make([]ort.Value, 1)
+	var output *Output
+	err = metrics.Track(m.observer, m.name, metrics.StagePostprocess, batchSize, func() (err error) {
+		output, err = newOutput(outputs)
+		return err
+	})
+	return output, err
+}
 
-	err = m.session.Run(inputs, outputs)
+// RunProfiled performs inference with the ONNX Runtime profiler enabled
+func (m *Model) RunProfiled(input *Input) (*Output, *onnx.Profile, error) {
+	tensors, err := m.tensors(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputs, profile, err := onnx.RunProfiled(m.session, tensors)
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := newOutput(outputs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run inference: %w", err)
+		return nil, nil, err
+	}
+	return output, profile, nil
+}
+
+// ModelMetadata returns the metadata stored in the model
+func (m *Model) ModelMetadata() (*metadata.Metadata, error) {
+	return backend.Metadata(m.session)
+}
+
+// Session returns the session of the model, so that the text classification and
+// token classification pipelines can run on a loaded model. The session is
+// shared, closing either the model or the pipeline closes it.
+func (m *Model) Session() backend.Session {
+	return m.session
+}
+
+// Warmup runs n inferences on synthetic inputs
+func (m *Model) Warmup(n int) error {
+	return backend.Warmup(m.session, n, map[string][]int64{
+		"input_ids":        {1, warmupSequenceLength},
+		"attention_mask":   {1, warmupSequenceLength},
+		tokenTypeInputName: {1, warmupSequenceLength},
+	})
+}
+
+func (m *Model) tensors(input *Input) ([]*backend.Tensor, error) {
+	batchSize := max(input.BatchSize, 1)
+	if len(input.InputIds)%batchSize != 0 {
+		return nil, fmt.Errorf("input ids length (%d) is not a multiple of the batch size (%d)", len(input.InputIds), batchSize)
+	}
+	if len(input.AttentionMask) != len(input.InputIds) {
+		return nil, fmt.Errorf("attention mask length (%d) does not match input ids length (%d)", len(input.AttentionMask), len(input.InputIds))
+	}
+	shape := []int64{int64(batchSize), int64(len(input.InputIds) / batchSize)}
+	tensors := []*backend.Tensor{
+		backend.NewTensor(shape, input.InputIds),
+		backend.NewTensor(shape, input.AttentionMask),
+	}
+	if !m.tokenTypes {
+		return tensors, nil
 	}
-	defer outputs[0].Destroy()
 
-	outputTensor := outputs[0].(*ort.Tensor[float32])
-	return &Output{Logits: outputTensor.GetData()}, nil
+	tokenTypeIds := input.TokenTypeIds
+	if tokenTypeIds == nil {
+		tokenTypeIds = make([]int64, len(input.InputIds))
+	}
+	if len(tokenTypeIds) != len(input.InputIds) {
+		return nil, fmt.Errorf("token type ids length (%d) does not match input ids length (%d)", len(tokenTypeIds), len(input.InputIds))
+	}
+	return append(tensors, backend.NewTensor(shape, tokenTypeIds)), nil
+}
+
+func newOutput(outputs []*backend.Tensor) (*Output, error) {
+	if len(outputs) != len(outputNames) {
+		return nil, fmt.Errorf("expected %d outputs, got %d", len(outputNames), len(outputs))
+	}
+	logits, err := backend.TensorData[float32](outputs[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid logits output: %w", err)
+	}
+	return &Output{Logits: logits}, nil
 }
 
 // Close releases resources
 func (m *Model) Close() error {
 	if m.session != nil {
-		return m.session.Destroy()
+		return m.session.Close()
 	}
 	return nil
 }
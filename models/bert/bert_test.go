@@ -0,0 +1,276 @@
+package bert
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/infergotest"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+	"github.com/joeychilson/infergo/pkg/reference"
+)
+
+func newTestSession() *infergotest.Session {
+	return infergotest.NewSession(
+		[]backend.TensorInfo{
+			{Name: "input_ids", DataType: backend.DataTypeInt64, Shape: []int64{-1, -1}},
+			{Name: "attention_mask", DataType: backend.DataTypeInt64, Shape: []int64{-1, -1}},
+		},
+		[]backend.TensorInfo{
+			{Name: "logits", DataType: backend.DataTypeFloat32, Shape: []int64{-1, -1, -1}},
+		},
+	)
+}
+
+func TestRun(t *testing.T) {
+	logits := []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6}
+	session := newTestSession().Respond(backend.NewTensor([]int64{1, 3, 2}, logits))
+	model := NewWithSession(session)
+
+	output, err := model.Run(&Input{
+		InputIds:      []int64{101, 2023, 102},
+		AttentionMask: []int64{1, 1, 1},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(output.Logits, logits) {
+		t.Errorf("Logits = %v, want %v", output.Logits, logits)
+	}
+
+	if model.Session() != session {
+		t.Error("Session() does not return the session of the model")
+	}
+
+	calls := session.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(calls))
+	}
+	ids, _ := backend.TensorData[int64](calls[0][0])
+	if !reflect.DeepEqual(calls[0][0].Shape, []int64{1, 3}) || !reflect.DeepEqual(ids, []int64{101, 2023, 102}) {
+		t.Errorf("input_ids = %v %v", calls[0][0].Shape, ids)
+	}
+	mask, _ := backend.TensorData[int64](calls[0][1])
+	if !reflect.DeepEqual(mask, []int64{1, 1, 1}) {
+		t.Errorf("attention_mask = %v", mask)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	logits := []float32{0.1, 0.9, 0.8, 0.2}
+	session := newTestSession().Respond(backend.NewTensor([]int64{2, 2}, logits))
+	model := NewWithSession(session)
+
+	output, err := model.Run(&Input{
+		InputIds:      []int64{101, 7, 102, 101, 102, 0},
+		AttentionMask: []int64{1, 1, 1, 1, 1, 0},
+		BatchSize:     2,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(output.Logits, logits) {
+		t.Errorf("Logits = %v, want %v", output.Logits, logits)
+	}
+	calls := session.Calls()
+	for i, name := range inputNames {
+		if want := []int64{2, 3}; !reflect.DeepEqual(calls[0][i].Shape, want) {
+			t.Errorf("%s shape = %v, want %v", name, calls[0][i].Shape, want)
+		}
+	}
+
+	if _, err := model.Run(&Input{InputIds: []int64{101, 7, 102}, AttentionMask: []int64{1, 1, 1}, BatchSize: 2}); err == nil {
+		t.Error("Run with a ragged batch succeeded, want error")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	input := &Input{InputIds: []int64{101, 102}, AttentionMask: []int64{1, 1}}
+
+	failure := errors.New("boom")
+	model := NewWithSession(newTestSession().Fail(failure))
+	if _, err := model.Run(input); !errors.Is(err, failure) {
+		t.Errorf("Run error = %v, want %v", err, failure)
+	}
+
+	model = NewWithSession(newTestSession().Respond(backend.NewTensor([]int64{1, 2}, []int64{1, 2})))
+	if _, err := model.Run(input); err == nil {
+		t.Error("Run with int64 logits succeeded, want error")
+	}
+}
+
+func TestRunTokenTypeIds(t *testing.T) {
+	newSession := func() *infergotest.Session {
+		return infergotest.NewSession(
+			[]backend.TensorInfo{
+				{Name: "input_ids", DataType: backend.DataTypeInt64, Shape: []int64{-1, -1}},
+				{Name: "attention_mask", DataType: backend.DataTypeInt64, Shape: []int64{-1, -1}},
+				{Name: "token_type_ids", DataType: backend.DataTypeInt64, Shape: []int64{-1, -1}},
+			},
+			[]backend.TensorInfo{
+				{Name: "logits", DataType: backend.DataTypeFloat32, Shape: []int64{-1, -1}},
+			},
+		).Respond(backend.NewTensor([]int64{1, 2}, []float32{0.1, 0.9})).RepeatLast()
+	}
+
+	tests := []struct {
+		name         string
+		tokenTypeIds []int64
+		want         []int64
+	}{
+		{name: "given", tokenTypeIds: []int64{0, 0, 1, 1}, want: []int64{0, 0, 1, 1}},
+		{name: "zero-filled", want: []int64{0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newSession()
+			b := infergotest.NewBackend().Register("bert.onnx", session)
+			model, err := NewWithBackend(b, "bert.onnx", WithTokenTypeIds())
+			if err != nil {
+				t.Fatalf("NewWithBackend: %v", err)
+			}
+
+			_, err = model.Run(&Input{
+				InputIds:      []int64{101, 7, 102, 8},
+				AttentionMask: []int64{1, 1, 1, 1},
+				TokenTypeIds:  tt.tokenTypeIds,
+			})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			calls := session.Calls()
+			if len(calls) != 1 || len(calls[0]) != 3 {
+				t.Fatalf("got calls %v, want one call with 3 inputs", calls)
+			}
+			got, _ := backend.TensorData[int64](calls[0][2])
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("token_type_ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	model := NewWithSession(newSession())
+	_, err := model.Run(&Input{InputIds: []int64{101, 102}, AttentionMask: []int64{1, 1}, TokenTypeIds: []int64{0}})
+	if err == nil {
+		t.Error("Run with mismatched token type ids succeeded, want error")
+	}
+
+	// models without the input ignore token type ids
+	session := newTestSession().Respond(backend.NewTensor([]int64{1, 2}, []float32{0.1, 0.9}))
+	model = NewWithSession(session)
+	if _, err := model.Run(&Input{InputIds: []int64{101, 102}, AttentionMask: []int64{1, 1}, TokenTypeIds: []int64{0, 0}}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if calls := session.Calls(); len(calls[0]) != 2 {
+		t.Errorf("got %d inputs, want 2", len(calls[0]))
+	}
+}
+
+func TestNewWithBackend(t *testing.T) {
+	session := newTestSession()
+	b := infergotest.NewBackend().Register("bert.onnx", session)
+
+	model, err := NewWithBackend(b, "bert.onnx")
+	if err != nil {
+		t.Fatalf("NewWithBackend: %v", err)
+	}
+	if err := model.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !session.Closed() {
+		t.Error("session not closed")
+	}
+
+	if _, err := NewWithBackend(b, "missing.onnx"); err == nil {
+		t.Error("NewWithBackend with unknown model succeeded, want error")
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	session := newTestSession().Respond(backend.NewTensor([]int64{1, 1, 1}, []float32{0})).RepeatLast()
+	model := NewWithSession(session)
+
+	if err := model.Warmup(3); err != nil {
+		t.Fatalf("Warmup: %v", err)
+	}
+
+	calls := session.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d warmup calls, want 3", len(calls))
+	}
+	if want := []int64{1, warmupSequenceLength}; !reflect.DeepEqual(calls[0][0].Shape, want) {
+		t.Errorf("warmup input_ids shape = %v, want %v", calls[0][0].Shape, want)
+	}
+}
+
+func TestModelMetadata(t *testing.T) {
+	md := &metadata.Metadata{Producer: "pytorch"}
+	model := NewWithSession(newTestSession().WithMetadata(md))
+
+	got, err := model.ModelMetadata()
+	if err != nil {
+		t.Fatalf("ModelMetadata: %v", err)
+	}
+	if got != md {
+		t.Errorf("ModelMetadata = %v, want %v", got, md)
+	}
+}
+
+// writeTinyModel writes a token classifier that embeds input_ids, zeroes masked
+// positions and projects each token onto two classes
+func writeTinyModel(t *testing.T) string {
+	t.Helper()
+	tokens := []onnxproto.Dimension{{Value: 1}, {Param: "sequence"}}
+	model := &onnxproto.Model{
+		IRVersion:    8,
+		OpsetImports: []onnxproto.OperatorSet{{Version: 17}},
+		Graph: &onnxproto.Graph{
+			Nodes: []*onnxproto.Node{
+				{OpType: "Gather", Inputs: []string{"embeddings", "input_ids"}, Outputs: []string{"embedded"}},
+				{OpType: "Gather", Inputs: []string{"mask_values", "attention_mask"}, Outputs: []string{"mask"}},
+				{OpType: "Mul", Inputs: []string{"embedded", "mask"}, Outputs: []string{"masked"}},
+				{OpType: "MatMul", Inputs: []string{"masked", "classifier"}, Outputs: []string{"logits"}},
+			},
+			Initializers: []*onnxproto.Tensor{
+				{Name: "embeddings", Dims: []int64{4, 2}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{0, 0, 1, -1, 2, -2, 3, -3}},
+				{Name: "mask_values", Dims: []int64{2, 1}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{0, 1}},
+				{Name: "classifier", Dims: []int64{2, 2}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{2, 0, 0, 2}},
+			},
+			Inputs: []*onnxproto.ValueInfo{
+				{Name: "input_ids", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeInt64, HasShape: true, Shape: tokens},
+				{Name: "attention_mask", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeInt64, HasShape: true, Shape: tokens},
+			},
+			Outputs: []*onnxproto.ValueInfo{{Name: "logits", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat}},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "bert.onnx")
+	if err := onnxproto.WriteFile(path, model); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestReferenceBackend(t *testing.T) {
+	model, err := NewWithBackend(reference.NewBackend(), writeTinyModel(t))
+	if err != nil {
+		t.Fatalf("NewWithBackend: %v", err)
+	}
+	defer model.Close()
+
+	output, err := model.Run(&Input{InputIds: []int64{1, 2, 3}, AttentionMask: []int64{1, 1, 0}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := []float32{2, -2, 4, -4, 0, 0}; !reflect.DeepEqual(output.Logits, want) {
+		t.Errorf("Logits = %v, want %v", output.Logits, want)
+	}
+
+	if err := model.Warmup(2); err != nil {
+		t.Errorf("Warmup: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package resnet
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/infergotest"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+	"github.com/joeychilson/infergo/pkg/postprocess"
+	"github.com/joeychilson/infergo/pkg/reference"
+)
+
+func TestRun(t *testing.T) {
+	session := infergotest.NewSession(
+		[]backend.TensorInfo{{Name: "pixel_values", DataType: backend.DataTypeFloat32, Shape: []int64{-1, 3, 224, 224}}},
+		[]backend.TensorInfo{{Name: "logits", DataType: backend.DataTypeFloat32, Shape: []int64{-1, 1000}}},
+	)
+	logits := make([]float32, 1000)
+	logits[42] = 9
+	session.Respond(backend.NewTensor([]int64{1, 1000}, logits))
+
+	model := NewWithSession(session)
+	output, err := model.Run(&Input{Pixels: make([]float32, 3*224*224)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(output.Logits, logits) {
+		t.Error("Logits do not match the session output")
+	}
+
+	if _, err := model.Run(&Input{Pixels: make([]float32, 10)}); err == nil {
+		t.Error("Run with too few pixels succeeded, want error")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	newSession := func() *infergotest.Session {
+		session := infergotest.NewSession(
+			[]backend.TensorInfo{{Name: "pixel_values", DataType: backend.DataTypeFloat32, Shape: []int64{-1, 3, 224, 224}}},
+			[]backend.TensorInfo{{Name: "logits", DataType: backend.DataTypeFloat32, Shape: []int64{-1, 3}}},
+		)
+		return session.Respond(backend.NewTensor([]int64{1, 3}, []float32{0, 5, 1}))
+	}
+	input := &Input{Pixels: make([]float32, 3*224*224)}
+	opts := postprocess.ClassificationOptions{TopK: 1, Softmax: true}
+
+	md := &metadata.Metadata{ID2Label: map[int]string{0: "cat", 1: "dog", 2: "bird"}}
+	got, err := NewWithSession(newSession().WithMetadata(md)).Classify(input, opts)
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	if len(got) != 1 || got[0].Label != "dog" || got[0].Class != 1 {
+		t.Errorf("Classify with metadata labels = %+v, want dog", got)
+	}
+
+	opts.Labels = map[int]string{1: "puppy"}
+	got, err = NewWithSession(newSession().WithMetadata(md)).Classify(input, opts)
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	if len(got) != 1 || got[0].Label != "puppy" {
+		t.Errorf("Classify with explicit labels = %+v, want puppy", got)
+	}
+
+	opts.Labels = nil
+	if _, err := NewWithSession(newSession()).Classify(input, opts); err == nil {
+		t.Error("Classify without any labels succeeded, want error")
+	}
+}
+
+// writeTinyModel writes a classifier that downsamples the image with a strided
+// convolution and max pooling before a fully connected layer over three classes
+func writeTinyModel(t *testing.T) string {
+	t.Helper()
+	kernel := make([]float32, 3*4*4)
+	for i := range kernel {
+		kernel[i] = 1
+	}
+	// column j of the fully connected weight is j
+	dense := make([]float32, 49*3)
+	for i := range dense {
+		dense[i] = float32(i % 3)
+	}
+
+	model := &onnxproto.Model{
+		IRVersion:    8,
+		OpsetImports: []onnxproto.OperatorSet{{Version: 17}},
+		Graph: &onnxproto.Graph{
+			Nodes: []*onnxproto.Node{
+				{OpType: "Conv", Inputs: []string{"pixel_values", "kernel"}, Outputs: []string{"features"}, Attributes: []*onnxproto.Attribute{
+					{Name: "strides", Type: onnxproto.AttributeInts, Ints: []int64{4, 4}},
+				}},
+				{OpType: "Relu", Inputs: []string{"features"}, Outputs: []string{"activated"}},
+				{OpType: "MaxPool", Inputs: []string{"activated"}, Outputs: []string{"pooled"}, Attributes: []*onnxproto.Attribute{
+					{Name: "kernel_shape", Type: onnxproto.AttributeInts, Ints: []int64{8, 8}},
+					{Name: "strides", Type: onnxproto.AttributeInts, Ints: []int64{8, 8}},
+				}},
+				{OpType: "Flatten", Inputs: []string{"pooled"}, Outputs: []string{"flat"}},
+				{OpType: "Gemm", Inputs: []string{"flat", "dense", "bias"}, Outputs: []string{"logits"}},
+			},
+			Initializers: []*onnxproto.Tensor{
+				{Name: "kernel", Dims: []int64{1, 3, 4, 4}, DataType: onnxproto.DataTypeFloat, FloatData: kernel},
+				{Name: "dense", Dims: []int64{49, 3}, DataType: onnxproto.DataTypeFloat, FloatData: dense},
+				{Name: "bias", Dims: []int64{3}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{0.5, 0, -0.5}},
+			},
+			Inputs: []*onnxproto.ValueInfo{{
+				Name: "pixel_values", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat, HasShape: true,
+				Shape: []onnxproto.Dimension{{Param: "batch"}, {Value: 3}, {Value: 224}, {Value: 224}},
+			}},
+			Outputs: []*onnxproto.ValueInfo{{Name: "logits", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat}},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "resnet.onnx")
+	if err := onnxproto.WriteFile(path, model); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestReferenceBackend(t *testing.T) {
+	model, err := NewWithBackend(reference.NewBackend(), writeTinyModel(t))
+	if err != nil {
+		t.Fatalf("NewWithBackend: %v", err)
+	}
+	defer model.Close()
+
+	pixels := make([]float32, 3*224*224)
+	for i := range pixels {
+		pixels[i] = 0.5
+	}
+	output, err := model.Run(&Input{Pixels: pixels})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// every convolution window sums 48 pixels of 0.5, and 49 pooled features feed the dense layer
+	if want := []float32{0.5, 24 * 49, 24*49*2 - 0.5}; !reflect.DeepEqual(output.Logits, want) {
+		t.Errorf("Logits = %v, want %v", output.Logits, want)
+	}
+}
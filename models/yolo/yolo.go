@@ -1,14 +1,27 @@
 package yolo
 
 import (
+	"errors"
 	"fmt"
+	"image"
 
-	ort "github.com/yalue/onnxruntime_go"
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/onnx"
+	"github.com/joeychilson/infergo/pkg/postprocess"
+)
+
+var (
+	inputNames  = []string{"pixel_values"}
+	outputNames = []string{"logits", "pred_boxes"}
 )
 
 // Model represents a YOLO model
 type Model struct {
-	session *ort.DynamicAdvancedSession
+	session  backend.Session
+	observer metrics.Observer
+	name     string
 }
 
 // Input represents the input data for YOLO inference
@@ -30,52 +43,129 @@ type Output struct {
 }
 
 // New creates a new YOLO model instance
-func New(modelPath string) (*Model, error) {
-	sessionOptions, err := ort.NewSessionOptions()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create session options: %w", err)
-	}
-	defer sessionOptions.Destroy()
-
-	session, err := ort.NewDynamicAdvancedSession(
-		modelPath,
-		[]string{"pixel_values"},
-		[]string{"logits", "pred_boxes"},
-		sessionOptions,
-	)
+func New(modelPath string, opts ...onnx.SessionOption) (*Model, error) {
+	return NewWithBackend(onnx.NewBackend(opts...), modelPath)
+}
+
+// NewWithBackend creates a new YOLO model instance running on the given backend
+func NewWithBackend(b backend.Backend, modelPath string) (*Model, error) {
+	session, err := b.NewSession(modelPath, inputNames, outputNames)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, err
 	}
-	return &Model{session: session}, nil
+	return NewWithSession(session), nil
+}
+
+// NewWithSession creates a new YOLO model instance running on an existing session.
+// The session must take pixel_values and return logits and pred_boxes.
+func NewWithSession(session backend.Session) *Model {
+	observer, name := metrics.ObserverOf(session)
+	return &Model{session: session, observer: observer, name: name}
 }
 
 // Run performs inference on the input data
 func (m *Model) Run(input *Input) (*Output, error) {
-	inputTensor, err := ort.NewTensor(ort.NewShape(1, 3, int64(input.Height), int64(input.Width)), input.Pixels)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create input tensor: %w", err)
+	var output *Output
+	err := m.run(input, func(outputs []*backend.Tensor) (err error) {
+		output, err = newOutput(outputs)
+		return err
+	})
+	return output, err
+}
+
+// Detect performs inference and returns the detections in an image of the
+// given size. When opts has neither Labels nor Metadata, the id2label embedded
+// in the model names the classes.
+func (m *Model) Detect(input *Input, imageSize image.Point, opts postprocess.DetectionOptions) ([]postprocess.Detection, error) {
+	if opts.Labels == nil && opts.Metadata == nil {
+		md, err := m.ModelMetadata()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read labels from model metadata: %w", err)
+		}
+		if md.Labels() == nil {
+			return nil, errors.New("model metadata has no id2label, labels must be given")
+		}
+		opts.Metadata = md
 	}
-	defer inputTensor.Destroy()
 
-	outputs := make([]ort.Value, 2)
+	var detections []postprocess.Detection
+	err := m.run(input, func(outputs []*backend.Tensor) error {
+		output, err := newOutput(outputs)
+		if err != nil {
+			return err
+		}
+		detections, err = postprocess.ProcessDetections(output.Logits, output.Boxes, imageSize, opts)
+		return err
+	})
+	return detections, err
+}
 
-	err = m.session.Run([]ort.Value{inputTensor}, outputs)
+// run performs inference and passes the outputs to decode, reporting building
+// the inputs and decoding the outputs to the observer of the session
+func (m *Model) run(input *Input, decode func([]*backend.Tensor) error) error {
+	var tensors []*backend.Tensor
+	metrics.Track(m.observer, m.name, metrics.StagePreprocess, 1, func() error {
+		tensors = input.tensors()
+		return nil
+	})
+	outputs, err := m.session.Run(tensors)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run inference: %w", err)
+		return err
 	}
-	defer outputs[0].Destroy()
-	defer outputs[1].Destroy()
+	return metrics.Track(m.observer, m.name, metrics.StagePostprocess, 1, func() error {
+		return decode(outputs)
+	})
+}
 
-	logitsTensor := outputs[0].(*ort.Tensor[float32])
-	boxesTensor := outputs[1].(*ort.Tensor[float32])
+// RunProfiled performs inference with the ONNX Runtime profiler enabled
+func (m *Model) RunProfiled(input *Input) (*Output, *onnx.Profile, error) {
+	outputs, profile, err := onnx.RunProfiled(m.session, input.tensors())
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := newOutput(outputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, profile, nil
+}
+
+// ModelMetadata returns the metadata stored in the model
+func (m *Model) ModelMetadata() (*metadata.Metadata, error) {
+	return backend.Metadata(m.session)
+}
+
+// Warmup runs n inferences on synthetic inputs
+func (m *Model) Warmup(n int) error {
+	return backend.Warmup(m.session, n, map[string][]int64{
+		"pixel_values": {1, 3, 640, 640},
+	})
+}
 
-	return &Output{Logits: logitsTensor.GetData(), Boxes: boxesTensor.GetData()}, nil
+func (input *Input) tensors() []*backend.Tensor {
+	shape := []int64{1, 3, int64(input.Height), int64(input.Width)}
+	return []*backend.Tensor{backend.NewTensor(shape, input.Pixels)}
+}
+
+func newOutput(outputs []*backend.Tensor) (*Output, error) {
+	if len(outputs) != len(outputNames) {
+		return nil, fmt.Errorf("expected %d outputs, got %d", len(outputNames), len(outputs))
+	}
+	logits, err := backend.TensorData[float32](outputs[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid logits output: %w", err)
+	}
+	boxes, err := backend.TensorData[float32](outputs[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid pred_boxes output: %w", err)
+	}
+	return &Output{Logits: logits, Boxes: boxes}, nil
 }
 
 // Close releases resources
 func (m *Model) Close() error {
 	if m.session != nil {
-		return m.session.Destroy()
+		return m.session.Close()
 	}
 	return nil
 }
@@ -0,0 +1,145 @@
+package yolo
+
+import (
+	"image"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/infergotest"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+	"github.com/joeychilson/infergo/pkg/postprocess"
+	"github.com/joeychilson/infergo/pkg/reference"
+)
+
+func TestRun(t *testing.T) {
+	session := infergotest.NewSession(
+		[]backend.TensorInfo{{Name: "pixel_values", DataType: backend.DataTypeFloat32, Shape: []int64{-1, 3, -1, -1}}},
+		[]backend.TensorInfo{
+			{Name: "logits", DataType: backend.DataTypeFloat32},
+			{Name: "pred_boxes", DataType: backend.DataTypeFloat32},
+		},
+	)
+	logits := []float32{0.1, 0.9, 0.8, 0.2}
+	boxes := []float32{0.5, 0.5, 0.2, 0.2, 0.25, 0.25, 0.1, 0.1}
+	session.Respond(backend.NewTensor([]int64{1, 2, 2}, logits), backend.NewTensor([]int64{1, 2, 4}, boxes))
+
+	model := NewWithSession(session)
+	output, err := model.Run(&Input{Height: 4, Width: 6, Pixels: make([]float32, 3*4*6)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(output.Logits, logits) || !reflect.DeepEqual(output.Boxes, boxes) {
+		t.Errorf("Output = %+v", output)
+	}
+
+	if shape := session.Calls()[0][0].Shape; !reflect.DeepEqual(shape, []int64{1, 3, 4, 6}) {
+		t.Errorf("pixel_values shape = %v, want [1 3 4 6]", shape)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	session := infergotest.NewSession(
+		[]backend.TensorInfo{{Name: "pixel_values", DataType: backend.DataTypeFloat32, Shape: []int64{-1, 3, -1, -1}}},
+		[]backend.TensorInfo{
+			{Name: "logits", DataType: backend.DataTypeFloat32},
+			{Name: "pred_boxes", DataType: backend.DataTypeFloat32},
+		},
+	).WithMetadata(&metadata.Metadata{ID2Label: map[int]string{0: "person", 1: "car"}})
+	session.Respond(
+		backend.NewTensor([]int64{1, 2, 2}, []float32{0, 4, 4, 0}),
+		backend.NewTensor([]int64{1, 2, 4}, []float32{0.5, 0.5, 0.2, 0.2, 0.25, 0.25, 0.1, 0.1}),
+	)
+
+	model := NewWithSession(session)
+	detections, err := model.Detect(&Input{Height: 4, Width: 6, Pixels: make([]float32, 3*4*6)}, image.Pt(100, 100),
+		postprocess.DetectionOptions{ConfThreshold: 0.5, IoUThreshold: 0.5, MaxDetections: 10})
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	var got []string
+	for _, d := range detections {
+		got = append(got, d.Label)
+	}
+	if !reflect.DeepEqual(got, []string{"car", "person"}) {
+		t.Errorf("Detect labels = %v, want [car person]", got)
+	}
+	if box := detections[0].Box; math.Abs(float64(box.X1-40)) > 1e-3 || math.Abs(float64(box.X2-60)) > 1e-3 {
+		t.Errorf("Detect box = %+v, want x from 40 to 60", box)
+	}
+}
+
+// writeTinyModel writes a detector that predicts one box per 2x2 patch from a
+// strided convolution and scores two classes from the box coordinates
+func writeTinyModel(t *testing.T) string {
+	t.Helper()
+	ln3 := float32(math.Log(3))
+	model := &onnxproto.Model{
+		IRVersion:    8,
+		OpsetImports: []onnxproto.OperatorSet{{Version: 17}},
+		Graph: &onnxproto.Graph{
+			Nodes: []*onnxproto.Node{
+				{OpType: "Conv", Inputs: []string{"pixel_values", "kernel", "kernel_bias"}, Outputs: []string{"features"}, Attributes: []*onnxproto.Attribute{
+					{Name: "strides", Type: onnxproto.AttributeInts, Ints: []int64{2, 2}},
+				}},
+				{OpType: "Reshape", Inputs: []string{"features", "box_shape"}, Outputs: []string{"channels_first"}},
+				{OpType: "Transpose", Inputs: []string{"channels_first"}, Outputs: []string{"raw_boxes"}, Attributes: []*onnxproto.Attribute{
+					{Name: "perm", Type: onnxproto.AttributeInts, Ints: []int64{0, 2, 1}},
+				}},
+				{OpType: "Sigmoid", Inputs: []string{"raw_boxes"}, Outputs: []string{"pred_boxes"}},
+				{OpType: "MatMul", Inputs: []string{"pred_boxes", "classes"}, Outputs: []string{"logits"}},
+			},
+			Initializers: []*onnxproto.Tensor{
+				{Name: "kernel", Dims: []int64{4, 3, 2, 2}, DataType: onnxproto.DataTypeFloat, FloatData: make([]float32, 4*3*2*2)},
+				{Name: "kernel_bias", Dims: []int64{4}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{0, ln3, -ln3, 0}},
+				{Name: "box_shape", Dims: []int64{3}, DataType: onnxproto.DataTypeInt64, Int64Data: []int64{0, 4, -1}},
+				{Name: "classes", Dims: []int64{4, 2}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{1, 0, 0, 1, 0, 0, 0, 0}},
+			},
+			Inputs: []*onnxproto.ValueInfo{{
+				Name: "pixel_values", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat, HasShape: true,
+				Shape: []onnxproto.Dimension{{Param: "batch"}, {Value: 3}, {Param: "height"}, {Param: "width"}},
+			}},
+			Outputs: []*onnxproto.ValueInfo{
+				{Name: "logits", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat},
+				{Name: "pred_boxes", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat},
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "yolo.onnx")
+	if err := onnxproto.WriteFile(path, model); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestReferenceBackend(t *testing.T) {
+	model, err := NewWithBackend(reference.NewBackend(), writeTinyModel(t))
+	if err != nil {
+		t.Fatalf("NewWithBackend: %v", err)
+	}
+	defer model.Close()
+
+	output, err := model.Run(&Input{Height: 4, Width: 4, Pixels: make([]float32, 3*4*4)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(output.Boxes) != 4*4 || len(output.Logits) != 4*2 {
+		t.Fatalf("got %d box values and %d logits, want 16 and 8", len(output.Boxes), len(output.Logits))
+	}
+
+	box := []float32{0.5, 0.75, 0.25, 0.5}
+	for i, v := range output.Boxes {
+		if math.Abs(float64(v-box[i%4])) > 1e-6 {
+			t.Errorf("Boxes[%d] = %v, want %v", i, v, box[i%4])
+		}
+	}
+	for i, v := range output.Logits {
+		if math.Abs(float64(v-box[i%2])) > 1e-6 {
+			t.Errorf("Logits[%d] = %v, want %v", i, v, box[i%2])
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/joeychilson/infergo/pkg/metadata"
+)
+
+// TensorInfo describes a model input or output
+type TensorInfo struct {
+	Name     string
+	DataType DataType
+	// Shape holds the declared dimensions, with -1 for dynamic dimensions
+	Shape []int64
+}
+
+// Session runs a loaded model bound to a fixed set of inputs and outputs
+type Session interface {
+	// Inputs describes the inputs in the order Run expects them
+	Inputs() []TensorInfo
+	// Outputs describes the outputs in the order Run returns them
+	Outputs() []TensorInfo
+	// Run performs inference
+	Run(inputs []*Tensor) ([]*Tensor, error)
+	// Close releases resources
+	Close() error
+}
+
+// Backend creates sessions for models
+type Backend interface {
+	NewSession(modelPath string, inputNames, outputNames []string) (Session, error)
+}
+
+// MetadataProvider is implemented by sessions that can read the metadata of their model
+type MetadataProvider interface {
+	Metadata() (*metadata.Metadata, error)
+}
+
+// Metadata returns the metadata of the session's model if the session provides it
+func Metadata(s Session) (*metadata.Metadata, error) {
+	provider, ok := s.(MetadataProvider)
+	if !ok {
+		return nil, fmt.Errorf("session %T does not provide model metadata", s)
+	}
+	return provider.Metadata()
+}
+
+// Warmer is implemented by sessions that provide their own warmup
+type Warmer interface {
+	Warmup(n int, dims map[string][]int64) error
+}
+
+// Warmup runs n inferences with synthetic inputs derived from the session signature.
+// Dynamic dimensions are taken from dims when present for the input and default to 1.
+// Floats are zero and integers are one so that token ids and attention masks remain valid.
+func Warmup(s Session, n int, dims map[string][]int64) error {
+	if w, ok := s.(Warmer); ok {
+		return w.Warmup(n, dims)
+	}
+
+	infos := s.Inputs()
+	inputs := make([]*Tensor, len(infos))
+	for i, info := range infos {
+		input, err := syntheticTensor(info, warmupShape(info.Shape, dims[info.Name]))
+		if err != nil {
+			return err
+		}
+		inputs[i] = input
+	}
+
+	for i := 0; i < n; i++ {
+		if _, err := s.Run(inputs); err != nil {
+			return fmt.Errorf("warmup run %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// warmupShape resolves the dynamic dimensions of a model input
+func warmupShape(declared, override []int64) []int64 {
+	shape := append([]int64(nil), declared...)
+	for i, dim := range shape {
+		if dim > 0 {
+			continue
+		}
+		shape[i] = 1
+		if i < len(override) && override[i] > 0 {
+			shape[i] = override[i]
+		}
+	}
+	return shape
+}
+
+func syntheticTensor(info TensorInfo, shape []int64) (*Tensor, error) {
+	tensor, err := Zeros(info.DataType, shape)
+	if err != nil {
+		return nil, fmt.Errorf("input %q: %w", info.Name, err)
+	}
+
+	switch data := tensor.Data.(type) {
+	case []int64:
+		fill(data, 1)
+	case []int32:
+		fill(data, 1)
+	case []int8:
+		fill(data, 1)
+	case []uint8:
+		fill(data, 1)
+	case []bool:
+		fill(data, true)
+	}
+	return tensor, nil
+}
+
+func fill[T Element](data []T, value T) {
+	for i := range data {
+		data[i] = value
+	}
+}
@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"fmt"
+)
+
+// DataType is the element type of a tensor
+type DataType int
+
+const (
+	DataTypeUndefined DataType = iota
+	DataTypeFloat32
+	DataTypeFloat64
+	DataTypeInt64
+	DataTypeInt32
+	DataTypeInt8
+	DataTypeUint8
+	DataTypeBool
+)
+
+// String returns the name of the data type
+func (t DataType) String() string {
+	switch t {
+	case DataTypeFloat32:
+		return "float32"
+	case DataTypeFloat64:
+		return "float64"
+	case DataTypeInt64:
+		return "int64"
+	case DataTypeInt32:
+		return "int32"
+	case DataTypeInt8:
+		return "int8"
+	case DataTypeUint8:
+		return "uint8"
+	case DataTypeBool:
+		return "bool"
+	}
+	return "undefined"
+}
+
+// Element is the set of Go types that can be stored in a Tensor
+type Element interface {
+	float32 | float64 | int64 | int32 | int8 | uint8 | bool
+}
+
+// Tensor is a dense, row-major tensor exchanged with a backend
+type Tensor struct {
+	Shape []int64
+	// Data holds a slice of one of the Element types
+	Data any
+}
+
+// NewTensor creates a new tensor with the given shape and data
+func NewTensor[T Element](shape []int64, data []T) *Tensor {
+	return &Tensor{Shape: shape, Data: data}
+}
+
+// TensorData returns the data of t as a []T
+func TensorData[T Element](t *Tensor) ([]T, error) {
+	if t == nil {
+		return nil, fmt.Errorf("nil tensor")
+	}
+	data, ok := t.Data.([]T)
+	if !ok {
+		var zero T
+		return nil, fmt.Errorf("tensor has %s data, not %T", t.DataType(), zero)
+	}
+	return data, nil
+}
+
+// DataType returns the element type of the tensor data
+func (t *Tensor) DataType() DataType {
+	switch t.Data.(type) {
+	case []float32:
+		return DataTypeFloat32
+	case []float64:
+		return DataTypeFloat64
+	case []int64:
+		return DataTypeInt64
+	case []int32:
+		return DataTypeInt32
+	case []int8:
+		return DataTypeInt8
+	case []uint8:
+		return DataTypeUint8
+	case []bool:
+		return DataTypeBool
+	}
+	return DataTypeUndefined
+}
+
+// Len returns the number of elements described by the tensor shape
+func (t *Tensor) Len() int {
+	return int(NumElements(t.Shape))
+}
+
+// NumElements returns the number of elements in a tensor of the given shape
+func NumElements(shape []int64) int64 {
+	n := int64(1)
+	for _, dim := range shape {
+		n *= dim
+	}
+	return n
+}
+
+// Zeros creates a tensor of the given type and shape filled with zeros
+func Zeros(dataType DataType, shape []int64) (*Tensor, error) {
+	n := NumElements(shape)
+	switch dataType {
+	case DataTypeFloat32:
+		return NewTensor(shape, make([]float32, n)), nil
+	case DataTypeFloat64:
+		return NewTensor(shape, make([]float64, n)), nil
+	case DataTypeInt64:
+		return NewTensor(shape, make([]int64, n)), nil
+	case DataTypeInt32:
+		return NewTensor(shape, make([]int32, n)), nil
+	case DataTypeInt8:
+		return NewTensor(shape, make([]int8, n)), nil
+	case DataTypeUint8:
+		return NewTensor(shape, make([]uint8, n)), nil
+	case DataTypeBool:
+		return NewTensor(shape, make([]bool, n)), nil
+	}
+	return nil, fmt.Errorf("unsupported data type %s", dataType)
+}
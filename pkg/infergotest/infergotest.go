@@ -0,0 +1,246 @@
+package infergotest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metadata"
+)
+
+// ErrNoResponse is returned by Run when the session has no scripted response left
+var ErrNoResponse = errors.New("infergotest: no scripted response")
+
+// Response is a scripted result of a single Run call
+type Response struct {
+	Outputs []*backend.Tensor
+	Err     error
+}
+
+// Session is a fake backend.Session that returns scripted outputs and records its inputs
+type Session struct {
+	mu        sync.Mutex
+	inputs    []backend.TensorInfo
+	outputs   []backend.TensorInfo
+	responses []Response
+	repeat    bool
+	calls     [][]*backend.Tensor
+	closed    bool
+	metadata  *metadata.Metadata
+
+	// RunFunc, when set, computes outputs instead of the scripted responses
+	RunFunc func(inputs []*backend.Tensor) ([]*backend.Tensor, error)
+}
+
+// NewSession creates a new fake session with the given signature
+func NewSession(inputs, outputs []backend.TensorInfo) *Session {
+	return &Session{inputs: inputs, outputs: outputs}
+}
+
+// Respond queues outputs to be returned by the next unanswered Run call
+func (s *Session) Respond(outputs ...*backend.Tensor) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, Response{Outputs: outputs})
+	return s
+}
+
+// Fail queues an error to be returned by the next unanswered Run call
+func (s *Session) Fail(err error) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, Response{Err: err})
+	return s
+}
+
+// RepeatLast makes the last scripted response answer every remaining Run call
+func (s *Session) RepeatLast() *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repeat = true
+	return s
+}
+
+// WithMetadata sets the metadata returned by Metadata
+func (s *Session) WithMetadata(md *metadata.Metadata) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata = md
+	return s
+}
+
+// Inputs describes the session inputs
+func (s *Session) Inputs() []backend.TensorInfo {
+	return s.inputs
+}
+
+// Outputs describes the session outputs
+func (s *Session) Outputs() []backend.TensorInfo {
+	return s.outputs
+}
+
+// Run records the inputs and returns the next scripted response
+func (s *Session) Run(inputs []*backend.Tensor) ([]*backend.Tensor, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, errors.New("infergotest: run on closed session")
+	}
+	if err := s.checkInputs(inputs); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.calls = append(s.calls, inputs)
+
+	runFunc := s.RunFunc
+	var response Response
+	switch {
+	case runFunc != nil:
+	case len(s.responses) == 0:
+		s.mu.Unlock()
+		return nil, ErrNoResponse
+	case len(s.responses) == 1 && s.repeat:
+		response = s.responses[0]
+	default:
+		response, s.responses = s.responses[0], s.responses[1:]
+	}
+	s.mu.Unlock()
+
+	if runFunc != nil {
+		return runFunc(inputs)
+	}
+	return response.Outputs, response.Err
+}
+
+// checkInputs validates the inputs against the declared signature
+func (s *Session) checkInputs(inputs []*backend.Tensor) error {
+	if len(inputs) != len(s.inputs) {
+		return fmt.Errorf("infergotest: expected %d inputs, got %d", len(s.inputs), len(inputs))
+	}
+
+	for i, info := range s.inputs {
+		input := inputs[i]
+		if info.DataType != backend.DataTypeUndefined && input.DataType() != info.DataType {
+			return fmt.Errorf("infergotest: input %q has type %s, expected %s", info.Name, input.DataType(), info.DataType)
+		}
+		if dataLen(input.Data) != input.Len() {
+			return fmt.Errorf("infergotest: input %q has %d elements, shape %v requires %d",
+				info.Name, dataLen(input.Data), input.Shape, input.Len())
+		}
+		if info.Shape == nil {
+			continue
+		}
+		if len(info.Shape) != len(input.Shape) {
+			return fmt.Errorf("infergotest: input %q has rank %d, expected %d", info.Name, len(input.Shape), len(info.Shape))
+		}
+		for d, dim := range info.Shape {
+			if dim > 0 && input.Shape[d] != dim {
+				return fmt.Errorf("infergotest: input %q has shape %v, expected %v", info.Name, input.Shape, info.Shape)
+			}
+		}
+	}
+	return nil
+}
+
+// Calls returns the inputs of every Run call in order
+func (s *Session) Calls() [][]*backend.Tensor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]*backend.Tensor(nil), s.calls...)
+}
+
+// Metadata returns the metadata set with WithMetadata
+func (s *Session) Metadata() (*metadata.Metadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.metadata == nil {
+		return nil, errors.New("infergotest: no metadata")
+	}
+	return s.metadata, nil
+}
+
+// Closed reports whether Close has been called
+func (s *Session) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Close marks the session as closed
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Backend is a fake backend.Backend that hands out registered sessions by model path
+type Backend struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewBackend creates a new fake backend
+func NewBackend() *Backend {
+	return &Backend{sessions: make(map[string]*Session)}
+}
+
+// Register makes NewSession return session for modelPath
+func (b *Backend) Register(modelPath string, session *Session) *Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sessions[modelPath] = session
+	return b
+}
+
+// NewSession returns the session registered for modelPath after checking that the
+// requested input and output names match its signature
+func (b *Backend) NewSession(modelPath string, inputNames, outputNames []string) (backend.Session, error) {
+	b.mu.Lock()
+	session, ok := b.sessions[modelPath]
+	b.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("infergotest: no session registered for %q", modelPath)
+	}
+
+	if err := matchNames("input", inputNames, session.inputs); err != nil {
+		return nil, err
+	}
+	if err := matchNames("output", outputNames, session.outputs); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func matchNames(kind string, names []string, infos []backend.TensorInfo) error {
+	if len(names) != len(infos) {
+		return fmt.Errorf("infergotest: requested %d %ss, session has %d", len(names), kind, len(infos))
+	}
+	for i, name := range names {
+		if infos[i].Name != name {
+			return fmt.Errorf("infergotest: requested %s %q, session has %q", kind, name, infos[i].Name)
+		}
+	}
+	return nil
+}
+
+func dataLen(data any) int {
+	switch d := data.(type) {
+	case []float32:
+		return len(d)
+	case []float64:
+		return len(d)
+	case []int64:
+		return len(d)
+	case []int32:
+		return len(d)
+	case []int8:
+		return len(d)
+	case []uint8:
+		return len(d)
+	case []bool:
+		return len(d)
+	}
+	return -1
+}
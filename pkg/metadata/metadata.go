@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Metadata contains the metadata stored in an ONNX model
+type Metadata struct {
+	Producer    string
+	GraphName   string
+	Domain      string
+	Description string
+	Version     int64
+	// Custom contains the model's metadata_props
+	Custom map[string]string
+	// ID2Label is the label mapping embedded under the id2label key, if any
+	ID2Label map[int]string
+	// Preprocessing contains preprocessing hints embedded in the model, if any
+	Preprocessing Preprocessing
+}
+
+// Preprocessing contains image preprocessing hints commonly exported with vision models
+type Preprocessing struct {
+	Mean   []float32
+	StdDev []float32
+	Width  int
+	Height int
+}
+
+// New creates metadata from the model properties and parses well-known custom keys
+func New(producer, graphName, domain, description string, version int64, custom map[string]string) (*Metadata, error) {
+	m := &Metadata{
+		Producer:    producer,
+		GraphName:   graphName,
+		Domain:      domain,
+		Description: description,
+		Version:     version,
+		Custom:      custom,
+	}
+
+	if raw, ok := custom["id2label"]; ok {
+		labels, err := ParseID2Label(raw)
+		if err != nil {
+			return nil, err
+		}
+		m.ID2Label = labels
+	}
+
+	m.Preprocessing.Mean = parseFloats(custom["image_mean"])
+	m.Preprocessing.StdDev = parseFloats(custom["image_std"])
+	m.Preprocessing.Width, m.Preprocessing.Height = parseSize(custom["image_size"])
+	return m, nil
+}
+
+// Labels returns the embedded label mapping, or nil if the metadata has none
+func (m *Metadata) Labels() map[int]string {
+	if m == nil {
+		return nil
+	}
+	return m.ID2Label
+}
+
+// ParseID2Label parses a label mapping encoded as a JSON object keyed by class
+// index, as written in HuggingFace configs, or as a JSON array of labels
+func ParseID2Label(raw string) (map[int]string, error) {
+	raw = strings.TrimSpace(raw)
+
+	if strings.HasPrefix(raw, "[") {
+		var list []string
+		if err := json.Unmarshal([]byte(raw), &list); err != nil {
+			return nil, fmt.Errorf("failed to parse id2label: %w", err)
+		}
+		labels := make(map[int]string, len(list))
+		for i, label := range list {
+			labels[i] = label
+		}
+		return labels, nil
+	}
+
+	var object map[string]string
+	if err := json.Unmarshal([]byte(raw), &object); err != nil {
+		return nil, fmt.Errorf("failed to parse id2label: %w", err)
+	}
+
+	labels := make(map[int]string, len(object))
+	for key, label := range object {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id2label key %q: %w", key, err)
+		}
+		labels[id] = label
+	}
+	return labels, nil
+}
+
+// parseFloats parses a JSON array or comma separated list of numbers
+func parseFloats(raw string) []float32 {
+	raw = strings.Trim(strings.TrimSpace(raw), "[]")
+	if raw == "" {
+		return nil
+	}
+
+	var values []float32
+	for _, field := range strings.Split(raw, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return nil
+		}
+		values = append(values, float32(value))
+	}
+	return values
+}
+
+// parseSize parses a single edge size or a [height, width] pair
+func parseSize(raw string) (width, height int) {
+	values := parseFloats(raw)
+	switch len(values) {
+	case 1:
+		return int(values[0]), int(values[0])
+	case 2:
+		return int(values[1]), int(values[0])
+	}
+	return 0, 0
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import "time"
+
+// Stage identifies a phase of an inference pipeline
+type Stage string
+
+const (
+	// StageQueue is the time a request waits before it runs. infergo runs requests
+	// as soon as they are made, so applications that queue or serialize requests
+	// report the wait themselves with Track.
+	StageQueue Stage = "queue"
+	// StagePreprocess is the time spent preparing model inputs
+	StagePreprocess Stage = "preprocess"
+	// StageInference is the time spent running the model
+	StageInference Stage = "inference"
+	// StagePostprocess is the time spent turning model outputs into results
+	StagePostprocess Stage = "postprocess"
+)
+
+// Observation describes a single measured pipeline stage
+type Observation struct {
+	Model     string
+	Stage     Stage
+	BatchSize int
+	Duration  time.Duration
+	Err       error
+}
+
+// Observer receives observations from inference pipelines.
+// Implementations must be safe for concurrent use.
+type Observer interface {
+	Observe(Observation)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(Observation)
+
+// Observe calls f(o)
+func (f ObserverFunc) Observe(o Observation) {
+	f(o)
+}
+
+// Multi returns an observer that forwards observations to all observers
+func Multi(observers ...Observer) Observer {
+	return ObserverFunc(func(o Observation) {
+		for _, observer := range observers {
+			observer.Observe(o)
+		}
+	})
+}
+
+// Reporter is implemented by sessions that report observations, such as ONNX
+// Runtime sessions created with an observer
+type Reporter interface {
+	Name() string
+	Observer() Observer
+}
+
+// ObserverOf returns the observer and model name of a session that implements
+// Reporter, so that the code around the session can report its stages to the
+// same observer. It returns a nil observer for other sessions.
+func ObserverOf(session any) (Observer, string) {
+	r, ok := session.(Reporter)
+	if !ok {
+		return nil, ""
+	}
+	return r.Observer(), r.Name()
+}
+
+// Track runs fn and reports its duration and error for the given stage.
+// A nil observer runs fn without reporting.
+func Track(observer Observer, model string, stage Stage, batchSize int, fn func() error) error {
+	if observer == nil {
+		return fn()
+	}
+
+	start := time.Now()
+	err := fn()
+	observer.Observe(Observation{
+		Model:     model,
+		Stage:     stage,
+		BatchSize: batchSize,
+		Duration:  time.Since(start),
+		Err:       err,
+	})
+	return err
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus(0.5, 0.01, 0.1)
+	p.Observe(Observation{Model: "bert", Stage: StageInference, BatchSize: 4, Duration: 5 * time.Millisecond})
+	p.Observe(Observation{Model: "bert", Stage: StageInference, BatchSize: 2, Duration: 50 * time.Millisecond})
+	p.Observe(Observation{Model: "bert", Stage: StageInference, BatchSize: 1, Duration: 2 * time.Second, Err: errors.New("failed")})
+	p.Observe(Observation{Model: "a \"quoted\"\\model\n", Stage: StagePreprocess, Duration: 100 * time.Millisecond})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	want := `# HELP infergo_stage_duration_seconds Duration of inference pipeline stages.
+# TYPE infergo_stage_duration_seconds histogram
+infergo_stage_duration_seconds_bucket{model="a \"quoted\"\\model\n",stage="preprocess",le="0.01"} 0
+infergo_stage_duration_seconds_bucket{model="a \"quoted\"\\model\n",stage="preprocess",le="0.1"} 1
+infergo_stage_duration_seconds_bucket{model="a \"quoted\"\\model\n",stage="preprocess",le="0.5"} 1
+infergo_stage_duration_seconds_bucket{model="a \"quoted\"\\model\n",stage="preprocess",le="+Inf"} 1
+infergo_stage_duration_seconds_sum{model="a \"quoted\"\\model\n",stage="preprocess"} 0.1
+infergo_stage_duration_seconds_count{model="a \"quoted\"\\model\n",stage="preprocess"} 1
+infergo_stage_duration_seconds_bucket{model="bert",stage="inference",le="0.01"} 1
+infergo_stage_duration_seconds_bucket{model="bert",stage="inference",le="0.1"} 2
+infergo_stage_duration_seconds_bucket{model="bert",stage="inference",le="0.5"} 2
+infergo_stage_duration_seconds_bucket{model="bert",stage="inference",le="+Inf"} 3
+infergo_stage_duration_seconds_sum{model="bert",stage="inference"} 2.055
+infergo_stage_duration_seconds_count{model="bert",stage="inference"} 3
+# HELP infergo_stage_errors_total Number of failed inference pipeline stages.
+# TYPE infergo_stage_errors_total counter
+infergo_stage_errors_total{model="a \"quoted\"\\model\n",stage="preprocess"} 0
+infergo_stage_errors_total{model="bert",stage="inference"} 1
+# HELP infergo_stage_items_total Number of batch items processed by inference pipeline stages.
+# TYPE infergo_stage_items_total counter
+infergo_stage_items_total{model="a \"quoted\"\\model\n",stage="preprocess"} 0
+infergo_stage_items_total{model="bert",stage="inference"} 7
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("metrics =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// reporter is a session that reports to an observer
+type reporter struct {
+	observer Observer
+}
+
+func (r reporter) Name() string       { return "model" }
+func (r reporter) Observer() Observer { return r.observer }
+
+func TestTrack(t *testing.T) {
+	var got []Observation
+	observer := ObserverFunc(func(o Observation) { got = append(got, o) })
+
+	failure := errors.New("failed")
+	if err := Track(observer, "model", StagePostprocess, 3, func() error { return failure }); err != failure {
+		t.Errorf("Track() error = %v, want %v", err, failure)
+	}
+	if len(got) != 1 || got[0].Model != "model" || got[0].Stage != StagePostprocess || got[0].BatchSize != 3 || got[0].Err != failure {
+		t.Errorf("observations = %+v", got)
+	}
+	// applications report the time requests wait with the queue stage
+	got = nil
+	if err := Track(observer, "model", StageQueue, 1, func() error { return nil }); err != nil {
+		t.Errorf("Track() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Stage != "queue" {
+		t.Errorf("queue observations = %+v", got)
+	}
+	if err := Track(nil, "model", StagePostprocess, 1, func() error { return nil }); err != nil {
+		t.Errorf("Track() without observer error = %v", err)
+	}
+
+	if o, name := ObserverOf(reporter{observer}); o == nil || name != "model" {
+		t.Errorf("ObserverOf(reporter) = %v, %q", o, name)
+	}
+	if o, name := ObserverOf(struct{}{}); o != nil || name != "" {
+		t.Errorf("ObserverOf(struct{}) = %v, %q, want no observer", o, name)
+	}
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets in seconds used when none are given
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Prometheus is an Observer that exposes observations in the Prometheus text format
+type Prometheus struct {
+	mu      sync.Mutex
+	buckets []float64
+	series  map[seriesKey]*series
+}
+
+type seriesKey struct {
+	model string
+	stage Stage
+}
+
+type series struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+	errors uint64
+	items  uint64
+}
+
+// NewPrometheus creates a new Prometheus observer with the given histogram buckets
+func NewPrometheus(buckets ...float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &Prometheus{
+		buckets: sorted,
+		series:  make(map[seriesKey]*series),
+	}
+}
+
+// Observe records an observation
+func (p *Prometheus) Observe(o Observation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := seriesKey{model: o.Model, stage: o.Stage}
+	s, ok := p.series[key]
+	if !ok {
+		s = &series{counts: make([]uint64, len(p.buckets))}
+		p.series[key] = s
+	}
+
+	seconds := o.Duration.Seconds()
+	for i, bound := range p.buckets {
+		if seconds <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += seconds
+	if o.BatchSize > 0 {
+		s.items += uint64(o.BatchSize)
+	}
+	if o.Err != nil {
+		s.errors++
+	}
+}
+
+// ServeHTTP writes the current metrics in the Prometheus text exposition format
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	p.write(bw)
+	bw.Flush()
+}
+
+func (p *Prometheus) write(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := make([]seriesKey, 0, len(p.series))
+	for key := range p.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].model != keys[j].model {
+			return keys[i].model < keys[j].model
+		}
+		return keys[i].stage < keys[j].stage
+	})
+
+	fmt.Fprintln(w, "# HELP infergo_stage_duration_seconds Duration of inference pipeline stages.")
+	fmt.Fprintln(w, "# TYPE infergo_stage_duration_seconds histogram")
+	for _, key := range keys {
+		s := p.series[key]
+		labels := formatLabels(key)
+		for i, bound := range p.buckets {
+			fmt.Fprintf(w, "infergo_stage_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(bound), s.counts[i])
+		}
+		fmt.Fprintf(w, "infergo_stage_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, s.count)
+		fmt.Fprintf(w, "infergo_stage_duration_seconds_sum{%s} %s\n", labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "infergo_stage_duration_seconds_count{%s} %d\n", labels, s.count)
+	}
+
+	fmt.Fprintln(w, "# HELP infergo_stage_errors_total Number of failed inference pipeline stages.")
+	fmt.Fprintln(w, "# TYPE infergo_stage_errors_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "infergo_stage_errors_total{%s} %d\n", formatLabels(key), p.series[key].errors)
+	}
+
+	fmt.Fprintln(w, "# HELP infergo_stage_items_total Number of batch items processed by inference pipeline stages.")
+	fmt.Fprintln(w, "# TYPE infergo_stage_items_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "infergo_stage_items_total{%s} %d\n", formatLabels(key), p.series[key].items)
+	}
+}
+
+func formatLabels(key seriesKey) string {
+	return fmt.Sprintf("model=\"%s\",stage=\"%s\"", escapeLabel(key.model), escapeLabel(string(key.stage)))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
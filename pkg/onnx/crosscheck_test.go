@@ -0,0 +1,97 @@
+package onnx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+	"github.com/joeychilson/infergo/pkg/reference"
+)
+
+// TestCrossCheckReference compares ONNX Runtime against the pure-Go reference executor.
+// It needs the ONNX Runtime shared library, given by INFERGO_ORT_LIBRARY.
+func TestCrossCheckReference(t *testing.T) {
+	library := os.Getenv("INFERGO_ORT_LIBRARY")
+	if library == "" {
+		t.Skip("INFERGO_ORT_LIBRARY is not set")
+	}
+	runtime, err := New(context.Background(), WithLibraryPath(library))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer runtime.Close()
+
+	kernel := make([]float32, 4*3*3*3)
+	for i := range kernel {
+		kernel[i] = float32(i%7)/7 - 0.5
+	}
+	dense := make([]float32, 4*4*4*5)
+	for i := range dense {
+		dense[i] = float32(i%11)/11 - 0.5
+	}
+	ints := func(name string, values ...int64) *onnxproto.Attribute {
+		return &onnxproto.Attribute{Name: name, Type: onnxproto.AttributeInts, Ints: values}
+	}
+
+	model := &onnxproto.Model{
+		IRVersion:    8,
+		OpsetImports: []onnxproto.OperatorSet{{Version: 17}},
+		Graph: &onnxproto.Graph{
+			Name: "crosscheck",
+			Nodes: []*onnxproto.Node{
+				{OpType: "Conv", Inputs: []string{"x", "kernel", "kernel_bias"}, Outputs: []string{"conv"},
+					Attributes: []*onnxproto.Attribute{ints("pads", 1, 1, 1, 1), ints("strides", 2, 2)}},
+				{OpType: "Relu", Inputs: []string{"conv"}, Outputs: []string{"relu"}},
+				{OpType: "MaxPool", Inputs: []string{"relu"}, Outputs: []string{"pool"},
+					Attributes: []*onnxproto.Attribute{ints("kernel_shape", 2, 2), ints("strides", 2, 2)}},
+				{OpType: "Flatten", Inputs: []string{"pool"}, Outputs: []string{"flat"}},
+				{OpType: "Gemm", Inputs: []string{"flat", "dense", "dense_bias"}, Outputs: []string{"logits"}},
+				{OpType: "Softmax", Inputs: []string{"logits"}, Outputs: []string{"probs"}},
+			},
+			Initializers: []*onnxproto.Tensor{
+				{Name: "kernel", Dims: []int64{4, 3, 3, 3}, DataType: onnxproto.DataTypeFloat, FloatData: kernel},
+				{Name: "kernel_bias", Dims: []int64{4}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{0.1, -0.1, 0.2, 0}},
+				{Name: "dense", Dims: []int64{64, 5}, DataType: onnxproto.DataTypeFloat, FloatData: dense},
+				{Name: "dense_bias", Dims: []int64{5}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{0, 1, 2, 3, 4}},
+			},
+			Inputs: []*onnxproto.ValueInfo{{
+				Name: "x", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat, HasShape: true,
+				Shape: []onnxproto.Dimension{{Value: 1}, {Value: 3}, {Value: 16}, {Value: 16}},
+			}},
+			Outputs: []*onnxproto.ValueInfo{{
+				Name: "probs", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat, HasShape: true,
+				Shape: []onnxproto.Dimension{{Value: 1}, {Value: 5}},
+			}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "crosscheck.onnx")
+	if err := onnxproto.WriteFile(path, model); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	pixels := make([]float32, 3*16*16)
+	for i := range pixels {
+		pixels[i] = float32(i%13) / 13
+	}
+	inputs := []*backend.Tensor{backend.NewTensor([]int64{1, 3, 16, 16}, pixels)}
+
+	var outputs [2][]*backend.Tensor
+	for i, b := range []backend.Backend{NewBackend(), reference.NewBackend()} {
+		session, err := b.NewSession(path, []string{"x"}, []string{"probs"})
+		if err != nil {
+			t.Fatalf("NewSession: %v", err)
+		}
+		outputs[i], err = session.Run(inputs)
+		session.Close()
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+
+	if err := reference.CrossCheck(outputs[0], outputs[1], 1e-5, 1e-4); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,54 @@
+package onnx
+
+import (
+	"fmt"
+
+	"github.com/joeychilson/infergo/pkg/metadata"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// ReadMetadata reads the metadata stored in the model at modelPath
+func ReadMetadata(modelPath string) (*metadata.Metadata, error) {
+	m, err := ort.GetModelMetadata(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model metadata: %w", err)
+	}
+	defer m.Destroy()
+
+	producer, err := m.GetProducerName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read producer name: %w", err)
+	}
+	graphName, err := m.GetGraphName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read graph name: %w", err)
+	}
+	domain, err := m.GetDomain()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domain: %w", err)
+	}
+	description, err := m.GetDescription()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read description: %w", err)
+	}
+	version, err := m.GetVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version: %w", err)
+	}
+
+	keys, err := m.GetCustomMetadataMapKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custom metadata keys: %w", err)
+	}
+
+	custom := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, _, err := m.LookupCustomMetadataMap(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom metadata %q: %w", key, err)
+		}
+		custom[key] = value
+	}
+
+	return metadata.New(producer, graphName, domain, description, version, custom)
+}
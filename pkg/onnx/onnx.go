@@ -13,7 +13,7 @@ import (
 	ort "github.com/yalue/onnxruntime_go"
 )
 
-const currentVersion = "1.20.0"
+const currentVersion = "1.24.1"
 
 // Runtime manages ONNX Runtime initialization and configuration
 type Runtime struct {
@@ -0,0 +1,145 @@
+package onnx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+)
+
+// Profile summarizes an ONNX Runtime profiler trace
+type Profile struct {
+	// Run is the wall time spent in model_run events
+	Run time.Duration
+	// Kernel is the total time spent executing operator kernels
+	Kernel time.Duration
+	// Operators contains per-operator timings sorted by total time, descending
+	Operators []OperatorTiming
+}
+
+// OperatorTiming contains the aggregated kernel timings of one operator type
+type OperatorTiming struct {
+	OpType   string
+	Provider string
+	Calls    int
+	Total    time.Duration
+	Average  time.Duration
+	Max      time.Duration
+	// Percent is the share of the profile's kernel time spent in this operator
+	Percent float64
+}
+
+// Profiler is implemented by sessions that can profile a single inference
+type Profiler interface {
+	RunProfiled(inputs []*backend.Tensor) ([]*backend.Tensor, *Profile, error)
+}
+
+// RunProfiled performs inference with profiling if the session supports it
+func RunProfiled(s backend.Session, inputs []*backend.Tensor) ([]*backend.Tensor, *Profile, error) {
+	profiler, ok := s.(Profiler)
+	if !ok {
+		return nil, nil, errors.New("session does not support profiling")
+	}
+	return profiler.RunProfiled(inputs)
+}
+
+// traceEvent is a single event in the Chrome trace format written by ONNX Runtime
+type traceEvent struct {
+	Category string `json:"cat"`
+	Name     string `json:"name"`
+	Phase    string `json:"ph"`
+	Time     int64  `json:"ts"`
+	Duration int64  `json:"dur"`
+	Args     struct {
+		OpName   string `json:"op_name"`
+		Provider string `json:"provider"`
+	} `json:"args"`
+}
+
+// ParseProfile parses an ONNX Runtime profiler trace into per-operator timings
+func ParseProfile(r io.Reader) (*Profile, error) {
+	return parseProfile(r, 0)
+}
+
+// parseProfile parses a trace without the events of its first skipRuns model
+// runs, such as warmup runs
+func parseProfile(r io.Reader, skipRuns int) (*Profile, error) {
+	var events []traceEvent
+	if err := json.NewDecoder(r).Decode(&events); err != nil {
+		return nil, fmt.Errorf("failed to decode profile trace: %w", err)
+	}
+
+	// node events of a run come between its model_run event's start and end, so
+	// the start of the first measured run separates warmup events from the others
+	var runs []int64
+	for _, event := range events {
+		if event.Category == "Session" && event.Name == "model_run" {
+			runs = append(runs, event.Time)
+		}
+	}
+	if skipRuns > 0 && skipRuns >= len(runs) {
+		return nil, fmt.Errorf("profile trace has %d runs, expected more than %d", len(runs), skipRuns)
+	}
+	var since int64
+	if skipRuns > 0 {
+		slices.Sort(runs)
+		since = runs[skipRuns]
+	}
+
+	profile := &Profile{}
+	byOp := make(map[string]*OperatorTiming)
+
+	for _, event := range events {
+		if event.Time < since {
+			continue
+		}
+		duration := time.Duration(event.Duration) * time.Microsecond
+
+		switch event.Category {
+		case "Session":
+			if event.Name == "model_run" {
+				profile.Run += duration
+			}
+		case "Node":
+			// Each node emits fence_before, kernel_time and fence_after events
+			if !strings.HasSuffix(event.Name, "_kernel_time") || event.Args.OpName == "" {
+				continue
+			}
+
+			timing, ok := byOp[event.Args.OpName]
+			if !ok {
+				timing = &OperatorTiming{OpType: event.Args.OpName, Provider: event.Args.Provider}
+				byOp[event.Args.OpName] = timing
+			}
+			timing.Calls++
+			timing.Total += duration
+			if duration > timing.Max {
+				timing.Max = duration
+			}
+			profile.Kernel += duration
+		}
+	}
+
+	profile.Operators = make([]OperatorTiming, 0, len(byOp))
+	for _, timing := range byOp {
+		timing.Average = timing.Total / time.Duration(timing.Calls)
+		if profile.Kernel > 0 {
+			timing.Percent = 100 * float64(timing.Total) / float64(profile.Kernel)
+		}
+		profile.Operators = append(profile.Operators, *timing)
+	}
+
+	sort.Slice(profile.Operators, func(i, j int) bool {
+		if profile.Operators[i].Total != profile.Operators[j].Total {
+			return profile.Operators[i].Total > profile.Operators[j].Total
+		}
+		return profile.Operators[i].OpType < profile.Operators[j].OpType
+	})
+	return profile, nil
+}
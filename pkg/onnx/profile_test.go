@@ -0,0 +1,62 @@
+package onnx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTrace has two runs of a model with a Conv and a Relu node, the first of
+// them slow as a cold run is
+const testTrace = `[
+	{"cat": "Session", "name": "session_initialization", "ph": "X", "ts": 0, "dur": 500},
+	{"cat": "Session", "name": "model_run", "ph": "X", "ts": 1000, "dur": 900},
+	{"cat": "Node", "name": "conv_fence_before", "ph": "X", "ts": 1010, "dur": 0, "args": {"op_name": "Conv"}},
+	{"cat": "Node", "name": "conv_kernel_time", "ph": "X", "ts": 1020, "dur": 600, "args": {"op_name": "Conv", "provider": "CPUExecutionProvider"}},
+	{"cat": "Node", "name": "relu_kernel_time", "ph": "X", "ts": 1700, "dur": 200, "args": {"op_name": "Relu", "provider": "CPUExecutionProvider"}},
+	{"cat": "Session", "name": "model_run", "ph": "X", "ts": 3000, "dur": 120},
+	{"cat": "Node", "name": "conv_kernel_time", "ph": "X", "ts": 3010, "dur": 60, "args": {"op_name": "Conv", "provider": "CPUExecutionProvider"}},
+	{"cat": "Node", "name": "relu_kernel_time", "ph": "X", "ts": 3080, "dur": 20, "args": {"op_name": "Relu", "provider": "CPUExecutionProvider"}}
+]`
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		skipRuns int
+		run      time.Duration
+		kernel   time.Duration
+		conv     OperatorTiming
+	}{
+		{
+			name:   "all runs",
+			run:    1020 * time.Microsecond,
+			kernel: 880 * time.Microsecond,
+			conv:   OperatorTiming{OpType: "Conv", Provider: "CPUExecutionProvider", Calls: 2, Total: 660 * time.Microsecond, Average: 330 * time.Microsecond, Max: 600 * time.Microsecond, Percent: 75},
+		},
+		{
+			name:     "without warmup",
+			skipRuns: 1,
+			run:      120 * time.Microsecond,
+			kernel:   80 * time.Microsecond,
+			conv:     OperatorTiming{OpType: "Conv", Provider: "CPUExecutionProvider", Calls: 1, Total: 60 * time.Microsecond, Average: 60 * time.Microsecond, Max: 60 * time.Microsecond, Percent: 75},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := parseProfile(strings.NewReader(testTrace), tt.skipRuns)
+			if err != nil {
+				t.Fatalf("parseProfile() error = %v", err)
+			}
+			if profile.Run != tt.run || profile.Kernel != tt.kernel {
+				t.Errorf("run %v, kernel %v, want %v, %v", profile.Run, profile.Kernel, tt.run, tt.kernel)
+			}
+			if len(profile.Operators) != 2 || profile.Operators[0] != tt.conv {
+				t.Errorf("operators = %+v, want %+v first", profile.Operators, tt.conv)
+			}
+		})
+	}
+
+	if _, err := parseProfile(strings.NewReader(testTrace), 2); err == nil {
+		t.Error("parseProfile() skipping every run succeeded")
+	}
+}
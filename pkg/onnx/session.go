@@ -0,0 +1,318 @@
+package onnx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Session wraps an ONNX Runtime session bound to a fixed set of inputs and outputs
+type Session struct {
+	session     *ort.DynamicAdvancedSession
+	modelPath   string
+	inputNames  []string
+	outputNames []string
+	name        string
+	observer    metrics.Observer
+
+	signatureOnce sync.Once
+	inputs        []backend.TensorInfo
+	outputs       []backend.TensorInfo
+	signatureErr  error
+
+	metadataOnce sync.Once
+	metadata     *metadata.Metadata
+	metadataErr  error
+}
+
+// SessionOption is a functional option for configuring Session
+type SessionOption func(*Session)
+
+// WithModelName sets the model name reported to observers
+func WithModelName(name string) SessionOption {
+	return func(s *Session) {
+		s.name = name
+	}
+}
+
+// WithObserver sets the observer notified about every inference. Models and
+// pipelines running on the session report their preprocessing and
+// postprocessing to it too.
+func WithObserver(observer metrics.Observer) SessionOption {
+	return func(s *Session) {
+		s.observer = observer
+	}
+}
+
+// NewSession creates a new session for the model at modelPath
+func NewSession(modelPath string, inputNames, outputNames []string, opts ...SessionOption) (*Session, error) {
+	s := &Session{
+		modelPath:   modelPath,
+		inputNames:  inputNames,
+		outputNames: outputNames,
+		name:        strings.TrimSuffix(filepath.Base(modelPath), filepath.Ext(modelPath)),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	session, err := newDynamicSession(modelPath, inputNames, outputNames, "")
+	if err != nil {
+		return nil, err
+	}
+	s.session = session
+	return s, nil
+}
+
+// Backend is the ONNX Runtime implementation of backend.Backend
+type Backend struct {
+	opts []SessionOption
+}
+
+// NewBackend creates a backend whose sessions are configured with opts
+func NewBackend(opts ...SessionOption) *Backend {
+	return &Backend{opts: opts}
+}
+
+// NewSession creates a new ONNX Runtime session for the model at modelPath
+func (b *Backend) NewSession(modelPath string, inputNames, outputNames []string) (backend.Session, error) {
+	return NewSession(modelPath, inputNames, outputNames, b.opts...)
+}
+
+// Name returns the model name reported to observers
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Observer returns the observer configured for the session, if any
+func (s *Session) Observer() metrics.Observer {
+	return s.observer
+}
+
+// Metadata returns the metadata stored in the session's model
+func (s *Session) Metadata() (*metadata.Metadata, error) {
+	s.metadataOnce.Do(func() {
+		s.metadata, s.metadataErr = ReadMetadata(s.modelPath)
+	})
+	return s.metadata, s.metadataErr
+}
+
+// Inputs describes the session inputs. Inputs missing from the model signature
+// are reported with an undefined data type.
+func (s *Session) Inputs() []backend.TensorInfo {
+	s.loadSignature()
+	return s.inputs
+}
+
+// Outputs describes the session outputs
+func (s *Session) Outputs() []backend.TensorInfo {
+	s.loadSignature()
+	return s.outputs
+}
+
+func (s *Session) loadSignature() {
+	s.signatureOnce.Do(func() {
+		inputInfo, outputInfo, err := ort.GetInputOutputInfo(s.modelPath)
+		if err != nil {
+			s.signatureErr = fmt.Errorf("failed to read model signature: %w", err)
+		}
+		s.inputs = selectTensorInfo(s.inputNames, inputInfo)
+		s.outputs = selectTensorInfo(s.outputNames, outputInfo)
+	})
+}
+
+func selectTensorInfo(names []string, infos []ort.InputOutputInfo) []backend.TensorInfo {
+	byName := make(map[string]ort.InputOutputInfo, len(infos))
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+
+	selected := make([]backend.TensorInfo, len(names))
+	for i, name := range names {
+		selected[i] = backend.TensorInfo{Name: name}
+		if info, ok := byName[name]; ok {
+			selected[i].DataType = fromORTDataType(info.DataType)
+			selected[i].Shape = info.Dimensions
+		}
+	}
+	return selected
+}
+
+func newDynamicSession(modelPath string, inputNames, outputNames []string, profilePrefix string) (*ort.DynamicAdvancedSession, error) {
+	sessionOptions, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session options: %w", err)
+	}
+	defer sessionOptions.Destroy()
+
+	if profilePrefix != "" {
+		if err := sessionOptions.EnableProfiling(profilePrefix); err != nil {
+			return nil, fmt.Errorf("failed to enable profiling: %w", err)
+		}
+	}
+
+	session, err := ort.NewDynamicAdvancedSession(modelPath, inputNames, outputNames, sessionOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, nil
+}
+
+// Run performs inference, returning outputs in the order of the session's output names
+func (s *Session) Run(inputs []*backend.Tensor) ([]*backend.Tensor, error) {
+	var outputs []*backend.Tensor
+	err := metrics.Track(s.observer, s.name, metrics.StageInference, batchSize(inputs), func() (err error) {
+		outputs, err = runSession(s.session, inputs, len(s.outputNames))
+		return err
+	})
+	return outputs, err
+}
+
+// batchSize returns the leading dimension of the first input
+func batchSize(inputs []*backend.Tensor) int {
+	if len(inputs) == 0 {
+		return 0
+	}
+	if len(inputs[0].Shape) == 0 {
+		return 1
+	}
+	return int(inputs[0].Shape[0])
+}
+
+func runSession(session *ort.DynamicAdvancedSession, inputs []*backend.Tensor, numOutputs int) ([]*backend.Tensor, error) {
+	values := make([]ort.Value, 0, len(inputs))
+	defer func() {
+		for _, value := range values {
+			value.Destroy()
+		}
+	}()
+
+	for i, input := range inputs {
+		value, err := toORTValue(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create input tensor %d: %w", i, err)
+		}
+		values = append(values, value)
+	}
+
+	outputValues := make([]ort.Value, numOutputs)
+	if err := session.Run(values, outputValues); err != nil {
+		return nil, fmt.Errorf("failed to run inference: %w", err)
+	}
+	defer func() {
+		for _, value := range outputValues {
+			if value != nil {
+				value.Destroy()
+			}
+		}
+	}()
+
+	outputs := make([]*backend.Tensor, numOutputs)
+	for i, value := range outputValues {
+		output, err := fromORTValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output tensor %d: %w", i, err)
+		}
+		outputs[i] = output
+	}
+	return outputs, nil
+}
+
+// profileWarmupRuns is the number of runs of a profiling session before the run
+// that is measured, so that the profile leaves out one-time allocation and
+// kernel initialization
+const profileWarmupRuns = 2
+
+// RunProfiled performs inference on a separate session with the ONNX Runtime
+// profiler enabled and returns the per-operator timings of that run. The
+// profiling session is warmed with the same inputs first and the warmup runs
+// are left out of the profile, so the timings are those of a warm session.
+func (s *Session) RunProfiled(inputs []*backend.Tensor) ([]*backend.Tensor, *Profile, error) {
+	dir, err := os.MkdirTemp("", "infergo-profile-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	session, err := newDynamicSession(s.modelPath, s.inputNames, s.outputNames, filepath.Join(dir, "profile"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var outputs []*backend.Tensor
+	for range profileWarmupRuns + 1 {
+		if outputs, err = runSession(session, inputs, len(s.outputNames)); err != nil {
+			session.Destroy()
+			return nil, nil, err
+		}
+	}
+
+	// ONNX Runtime only writes the trace once the session is released
+	if err := session.Destroy(); err != nil {
+		return nil, nil, fmt.Errorf("failed to destroy profiling session: %w", err)
+	}
+
+	traces, err := filepath.Glob(filepath.Join(dir, "profile*.json"))
+	if err != nil || len(traces) == 0 {
+		return nil, nil, fmt.Errorf("profile trace not found in %s", dir)
+	}
+
+	file, err := os.Open(traces[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open profile trace: %w", err)
+	}
+	defer file.Close()
+
+	profile, err := parseProfile(file, profileWarmupRuns)
+	if err != nil {
+		return nil, nil, err
+	}
+	return outputs, profile, nil
+}
+
+// Warmup runs n inferences with synthetic inputs derived from the model signature.
+// Warmup runs are not reported to the session's observer.
+func (s *Session) Warmup(n int, dims map[string][]int64) error {
+	s.loadSignature()
+	if s.signatureErr != nil {
+		return s.signatureErr
+	}
+	return backend.Warmup(unobservedSession{s}, n, dims)
+}
+
+// unobservedSession runs a session without reporting to its observer
+type unobservedSession struct {
+	s *Session
+}
+
+func (u unobservedSession) Inputs() []backend.TensorInfo {
+	return u.s.Inputs()
+}
+
+func (u unobservedSession) Outputs() []backend.TensorInfo {
+	return u.s.Outputs()
+}
+
+func (u unobservedSession) Close() error {
+	return nil
+}
+
+func (u unobservedSession) Run(inputs []*backend.Tensor) ([]*backend.Tensor, error) {
+	return runSession(u.s.session, inputs, len(u.s.outputNames))
+}
+
+// Close releases resources
+func (s *Session) Close() error {
+	if s.session != nil {
+		return s.session.Destroy()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package onnx
+
+import (
+	"fmt"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// toORTValue creates an ONNX Runtime tensor sharing the data of t
+func toORTValue(t *backend.Tensor) (ort.Value, error) {
+	shape := ort.NewShape(t.Shape...)
+	switch data := t.Data.(type) {
+	case []float32:
+		return ort.NewTensor(shape, data)
+	case []float64:
+		return ort.NewTensor(shape, data)
+	case []int64:
+		return ort.NewTensor(shape, data)
+	case []int32:
+		return ort.NewTensor(shape, data)
+	case []int8:
+		return ort.NewTensor(shape, data)
+	case []uint8:
+		return ort.NewTensor(shape, data)
+	case []bool:
+		return ort.NewTensor(shape, data)
+	}
+	return nil, fmt.Errorf("unsupported tensor data %T", t.Data)
+}
+
+// fromORTValue copies an ONNX Runtime tensor into a backend tensor
+func fromORTValue(value ort.Value) (*backend.Tensor, error) {
+	shape := []int64(value.GetShape().Clone())
+	switch v := value.(type) {
+	case *ort.Tensor[float32]:
+		return backend.NewTensor(shape, v.GetData()), nil
+	case *ort.Tensor[float64]:
+		return backend.NewTensor(shape, v.GetData()), nil
+	case *ort.Tensor[int64]:
+		return backend.NewTensor(shape, v.GetData()), nil
+	case *ort.Tensor[int32]:
+		return backend.NewTensor(shape, v.GetData()), nil
+	case *ort.Tensor[int8]:
+		return backend.NewTensor(shape, v.GetData()), nil
+	case *ort.Tensor[uint8]:
+		return backend.NewTensor(shape, v.GetData()), nil
+	case *ort.Tensor[bool]:
+		return backend.NewTensor(shape, v.GetData()), nil
+	}
+	return nil, fmt.Errorf("unsupported output value %T", value)
+}
+
+func fromORTDataType(t ort.TensorElementDataType) backend.DataType {
+	switch t {
+	case ort.TensorElementDataTypeFloat:
+		return backend.DataTypeFloat32
+	case ort.TensorElementDataTypeDouble:
+		return backend.DataTypeFloat64
+	case ort.TensorElementDataTypeInt64:
+		return backend.DataTypeInt64
+	case ort.TensorElementDataTypeInt32:
+		return backend.DataTypeInt32
+	case ort.TensorElementDataTypeInt8:
+		return backend.DataTypeInt8
+	case ort.TensorElementDataTypeUint8:
+		return backend.DataTypeUint8
+	case ort.TensorElementDataTypeBool:
+		return backend.DataTypeBool
+	}
+	return backend.DataTypeUndefined
+}
@@ -0,0 +1,254 @@
+package onnxproto
+
+import (
+	"math"
+	"os"
+	"sort"
+
+	"github.com/joeychilson/infergo/internal/protowire"
+)
+
+// Encode serializes the model as an ONNX ModelProto
+func Encode(m *Model) []byte {
+	var b []byte
+	if m.IRVersion != 0 {
+		b = protowire.AppendVarint(b, 1, uint64(m.IRVersion))
+	}
+	if m.ProducerName != "" {
+		b = protowire.AppendString(b, 2, m.ProducerName)
+	}
+	if m.ProducerVersion != "" {
+		b = protowire.AppendString(b, 3, m.ProducerVersion)
+	}
+	if m.Domain != "" {
+		b = protowire.AppendString(b, 4, m.Domain)
+	}
+	if m.ModelVersion != 0 {
+		b = protowire.AppendVarint(b, 5, uint64(m.ModelVersion))
+	}
+	if m.DocString != "" {
+		b = protowire.AppendString(b, 6, m.DocString)
+	}
+	if m.Graph != nil {
+		b = protowire.AppendBytes(b, 7, encodeGraph(m.Graph))
+	}
+	for _, opset := range m.OpsetImports {
+		var o []byte
+		if opset.Domain != "" {
+			o = protowire.AppendString(o, 1, opset.Domain)
+		}
+		o = protowire.AppendVarint(o, 2, uint64(opset.Version))
+		b = protowire.AppendBytes(b, 8, o)
+	}
+
+	keys := make([]string, 0, len(m.MetadataProps))
+	for key := range m.MetadataProps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b = protowire.AppendBytes(b, 14, encodeStringEntry(key, m.MetadataProps[key]))
+	}
+	return b
+}
+
+// WriteFile serializes the model to path
+func WriteFile(path string, m *Model) error {
+	return os.WriteFile(path, Encode(m), 0644)
+}
+
+func encodeStringEntry(key, value string) []byte {
+	b := protowire.AppendString(nil, 1, key)
+	return protowire.AppendString(b, 2, value)
+}
+
+func encodeGraph(g *Graph) []byte {
+	var b []byte
+	for _, node := range g.Nodes {
+		b = protowire.AppendBytes(b, 1, encodeNode(node))
+	}
+	if g.Name != "" {
+		b = protowire.AppendString(b, 2, g.Name)
+	}
+	for _, init := range g.Initializers {
+		b = protowire.AppendBytes(b, 5, encodeTensor(init))
+	}
+	if g.DocString != "" {
+		b = protowire.AppendString(b, 10, g.DocString)
+	}
+	for _, input := range g.Inputs {
+		b = protowire.AppendBytes(b, 11, encodeValueInfo(input))
+	}
+	for _, output := range g.Outputs {
+		b = protowire.AppendBytes(b, 12, encodeValueInfo(output))
+	}
+	for _, info := range g.ValueInfo {
+		b = protowire.AppendBytes(b, 13, encodeValueInfo(info))
+	}
+	return b
+}
+
+func encodeNode(n *Node) []byte {
+	var b []byte
+	for _, input := range n.Inputs {
+		b = protowire.AppendString(b, 1, input)
+	}
+	for _, output := range n.Outputs {
+		b = protowire.AppendString(b, 2, output)
+	}
+	if n.Name != "" {
+		b = protowire.AppendString(b, 3, n.Name)
+	}
+	b = protowire.AppendString(b, 4, n.OpType)
+	for _, attr := range n.Attributes {
+		b = protowire.AppendBytes(b, 5, encodeAttribute(attr))
+	}
+	if n.Domain != "" {
+		b = protowire.AppendString(b, 7, n.Domain)
+	}
+	return b
+}
+
+func encodeAttribute(a *Attribute) []byte {
+	b := protowire.AppendString(nil, 1, a.Name)
+	switch a.Type {
+	case AttributeFloat:
+		b = protowire.AppendFixed32(b, 2, math.Float32bits(a.Float))
+	case AttributeInt:
+		b = protowire.AppendVarint(b, 3, uint64(a.Int))
+	case AttributeString:
+		b = protowire.AppendString(b, 4, a.String)
+	case AttributeTensor:
+		b = protowire.AppendBytes(b, 5, encodeTensor(a.Tensor))
+	case AttributeGraph:
+		b = protowire.AppendBytes(b, 6, encodeGraph(a.Graph))
+	case AttributeFloats:
+		b = protowire.AppendPackedFixed32(b, 7, float32Bits(a.Floats))
+	case AttributeInts:
+		b = protowire.AppendPackedVarints(b, 8, int64Bits(a.Ints))
+	case AttributeStrings:
+		for _, s := range a.Strings {
+			b = protowire.AppendString(b, 9, s)
+		}
+	case AttributeTensors:
+		for _, t := range a.Tensors {
+			b = protowire.AppendBytes(b, 10, encodeTensor(t))
+		}
+	case AttributeGraphs:
+		for _, g := range a.Graphs {
+			b = protowire.AppendBytes(b, 11, encodeGraph(g))
+		}
+	}
+	return protowire.AppendVarint(b, 20, uint64(a.Type))
+}
+
+func encodeTensor(t *Tensor) []byte {
+	var b []byte
+	if len(t.Dims) > 0 {
+		b = protowire.AppendPackedVarints(b, 1, int64Bits(t.Dims))
+	}
+	b = protowire.AppendVarint(b, 2, uint64(t.DataType))
+	if len(t.FloatData) > 0 {
+		b = protowire.AppendPackedFixed32(b, 4, float32Bits(t.FloatData))
+	}
+	if len(t.Int32Data) > 0 {
+		values := make([]uint64, len(t.Int32Data))
+		for i, v := range t.Int32Data {
+			values[i] = uint64(int64(v))
+		}
+		b = protowire.AppendPackedVarints(b, 5, values)
+	}
+	for _, s := range t.StringData {
+		b = protowire.AppendBytes(b, 6, s)
+	}
+	if len(t.Int64Data) > 0 {
+		b = protowire.AppendPackedVarints(b, 7, int64Bits(t.Int64Data))
+	}
+	if t.Name != "" {
+		b = protowire.AppendString(b, 8, t.Name)
+	}
+	if t.RawData != nil {
+		b = protowire.AppendBytes(b, 9, t.RawData)
+	}
+	if len(t.DoubleData) > 0 {
+		values := make([]uint64, len(t.DoubleData))
+		for i, v := range t.DoubleData {
+			values[i] = math.Float64bits(v)
+		}
+		b = protowire.AppendPackedFixed64(b, 10, values)
+	}
+	if len(t.Uint64Data) > 0 {
+		b = protowire.AppendPackedVarints(b, 11, t.Uint64Data)
+	}
+
+	keys := make([]string, 0, len(t.External))
+	for key := range t.External {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b = protowire.AppendBytes(b, 13, encodeStringEntry(key, t.External[key]))
+	}
+	if t.External != nil {
+		b = protowire.AppendVarint(b, 14, 1)
+	}
+	return b
+}
+
+func encodeValueInfo(v *ValueInfo) []byte {
+	b := protowire.AppendString(nil, 1, v.Name)
+
+	var typ []byte
+	switch v.Kind {
+	case KindTensor, KindSparseTensor:
+		tensorType := protowire.AppendVarint(nil, 1, uint64(v.ElemType))
+		if v.HasShape || len(v.Shape) > 0 {
+			var shape []byte
+			for _, dim := range v.Shape {
+				var d []byte
+				if dim.Param != "" {
+					d = protowire.AppendString(d, 2, dim.Param)
+				} else if dim.Value > 0 {
+					d = protowire.AppendVarint(d, 1, uint64(dim.Value))
+				}
+				shape = protowire.AppendBytes(shape, 1, d)
+			}
+			tensorType = protowire.AppendBytes(tensorType, 2, shape)
+		}
+		field := 1
+		if v.Kind == KindSparseTensor {
+			field = 8
+		}
+		typ = protowire.AppendBytes(typ, field, tensorType)
+	case KindSequence:
+		typ = protowire.AppendBytes(typ, 4, nil)
+	case KindMap:
+		typ = protowire.AppendBytes(typ, 5, nil)
+	case KindOptional:
+		typ = protowire.AppendBytes(typ, 9, nil)
+	}
+	if typ != nil {
+		b = protowire.AppendBytes(b, 2, typ)
+	}
+
+	if v.DocString != "" {
+		b = protowire.AppendString(b, 3, v.DocString)
+	}
+	return b
+}
+
+func float32Bits(values []float32) []uint32 {
+	bits := make([]uint32, len(values))
+	for i, v := range values {
+		bits[i] = math.Float32bits(v)
+	}
+	return bits
+}
+
+func int64Bits(values []int64) []uint64 {
+	bits := make([]uint64, len(values))
+	for i, v := range values {
+		bits[i] = uint64(v)
+	}
+	return bits
+}
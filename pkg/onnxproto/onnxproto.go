@@ -0,0 +1,548 @@
+package onnxproto
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/joeychilson/infergo/internal/protowire"
+)
+
+// Model is an ONNX ModelProto
+type Model struct {
+	IRVersion       int64
+	OpsetImports    []OperatorSet
+	ProducerName    string
+	ProducerVersion string
+	Domain          string
+	ModelVersion    int64
+	DocString       string
+	Graph           *Graph
+	MetadataProps   map[string]string
+}
+
+// OperatorSet is an operator set import of a model
+type OperatorSet struct {
+	Domain  string
+	Version int64
+}
+
+// Graph is an ONNX GraphProto
+type Graph struct {
+	Name         string
+	DocString    string
+	Nodes        []*Node
+	Initializers []*Tensor
+	Inputs       []*ValueInfo
+	Outputs      []*ValueInfo
+	ValueInfo    []*ValueInfo
+}
+
+// Node is a single operator invocation in a graph
+type Node struct {
+	Name       string
+	OpType     string
+	Domain     string
+	Inputs     []string
+	Outputs    []string
+	Attributes []*Attribute
+}
+
+// AttributeType is the type of a node attribute
+type AttributeType int32
+
+const (
+	AttributeUndefined AttributeType = 0
+	AttributeFloat     AttributeType = 1
+	AttributeInt       AttributeType = 2
+	AttributeString    AttributeType = 3
+	AttributeTensor    AttributeType = 4
+	AttributeGraph     AttributeType = 5
+	AttributeFloats    AttributeType = 6
+	AttributeInts      AttributeType = 7
+	AttributeStrings   AttributeType = 8
+	AttributeTensors   AttributeType = 9
+	AttributeGraphs    AttributeType = 10
+)
+
+// Attribute is a named attribute of a node
+type Attribute struct {
+	Name    string
+	Type    AttributeType
+	Float   float32
+	Int     int64
+	String  string
+	Tensor  *Tensor
+	Graph   *Graph
+	Floats  []float32
+	Ints    []int64
+	Strings []string
+	Tensors []*Tensor
+	Graphs  []*Graph
+}
+
+// ValueKind is the kind of value described by a ValueInfo
+type ValueKind int
+
+const (
+	KindUnknown ValueKind = iota
+	KindTensor
+	KindSequence
+	KindMap
+	KindOptional
+	KindSparseTensor
+)
+
+// String returns the name of the value kind
+func (k ValueKind) String() string {
+	switch k {
+	case KindTensor:
+		return "tensor"
+	case KindSequence:
+		return "sequence"
+	case KindMap:
+		return "map"
+	case KindOptional:
+		return "optional"
+	case KindSparseTensor:
+		return "sparse_tensor"
+	}
+	return "unknown"
+}
+
+// ValueInfo describes a graph input, output or intermediate value
+type ValueInfo struct {
+	Name      string
+	DocString string
+	Kind      ValueKind
+	ElemType  DataType
+	Shape     []Dimension
+	// HasShape is false when the value's rank is unknown
+	HasShape bool
+}
+
+// Dimension is a tensor dimension, either a fixed size or a symbolic parameter
+type Dimension struct {
+	Value int64
+	Param string
+}
+
+// String returns the size of the dimension, its parameter name, or "?" if unknown
+func (d Dimension) String() string {
+	if d.Param != "" {
+		return d.Param
+	}
+	if d.Value > 0 {
+		return strconv.FormatInt(d.Value, 10)
+	}
+	return "?"
+}
+
+// ReadFile decodes the ONNX model at path
+func ReadFile(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+	return Decode(data)
+}
+
+// Read decodes an ONNX model from r
+func Read(r io.Reader) (*Model, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+	return Decode(data)
+}
+
+// Decode decodes a serialized ONNX ModelProto. The returned model references data.
+func Decode(data []byte) (*Model, error) {
+	model := &Model{}
+	err := protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			model.IRVersion = f.Int64()
+		case 2:
+			model.ProducerName = f.String()
+		case 3:
+			model.ProducerVersion = f.String()
+		case 4:
+			model.Domain = f.String()
+		case 5:
+			model.ModelVersion = f.Int64()
+		case 6:
+			model.DocString = f.String()
+		case 7:
+			graph, err := decodeGraph(f.Bytes, 0)
+			if err != nil {
+				return err
+			}
+			model.Graph = graph
+		case 8:
+			opset, err := decodeOperatorSet(f.Bytes)
+			if err != nil {
+				return err
+			}
+			model.OpsetImports = append(model.OpsetImports, opset)
+		case 14:
+			key, value, err := decodeStringEntry(f.Bytes)
+			if err != nil {
+				return err
+			}
+			if model.MetadataProps == nil {
+				model.MetadataProps = make(map[string]string)
+			}
+			model.MetadataProps[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode model: %w", err)
+	}
+	if model.Graph == nil {
+		return nil, fmt.Errorf("failed to decode model: missing graph")
+	}
+	return model, nil
+}
+
+// Opset returns the imported version of the given operator set domain, or 0 if
+// it is not imported. The default domain may be given as "" or "ai.onnx".
+func (m *Model) Opset(domain string) int64 {
+	if domain == "ai.onnx" {
+		domain = ""
+	}
+	for _, opset := range m.OpsetImports {
+		d := opset.Domain
+		if d == "ai.onnx" {
+			d = ""
+		}
+		if d == domain {
+			return opset.Version
+		}
+	}
+	return 0
+}
+
+// Initializer returns the initializer with the given name, or nil if none exists
+func (g *Graph) Initializer(name string) *Tensor {
+	for _, init := range g.Initializers {
+		if init.Name == name {
+			return init
+		}
+	}
+	return nil
+}
+
+// RuntimeInputs returns the graph inputs that are not backed by initializers
+func (g *Graph) RuntimeInputs() []*ValueInfo {
+	initializers := make(map[string]bool, len(g.Initializers))
+	for _, init := range g.Initializers {
+		initializers[init.Name] = true
+	}
+
+	var inputs []*ValueInfo
+	for _, input := range g.Inputs {
+		if !initializers[input.Name] {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+// OpHistogram counts the nodes of each operator type, including nodes in subgraphs
+func (g *Graph) OpHistogram() map[string]int {
+	histogram := make(map[string]int)
+	g.countOps(histogram)
+	return histogram
+}
+
+func (g *Graph) countOps(histogram map[string]int) {
+	for _, node := range g.Nodes {
+		opType := node.OpType
+		if node.Domain != "" && node.Domain != "ai.onnx" {
+			opType = node.Domain + "." + opType
+		}
+		histogram[opType]++
+
+		for _, attr := range node.Attributes {
+			if attr.Graph != nil {
+				attr.Graph.countOps(histogram)
+			}
+			for _, graph := range attr.Graphs {
+				graph.countOps(histogram)
+			}
+		}
+	}
+}
+
+// Attribute returns the attribute with the given name, or nil if none exists
+func (n *Node) Attribute(name string) *Attribute {
+	for _, attr := range n.Attributes {
+		if attr.Name == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+func decodeOperatorSet(data []byte) (OperatorSet, error) {
+	var opset OperatorSet
+	err := protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			opset.Domain = f.String()
+		case 2:
+			opset.Version = f.Int64()
+		}
+		return nil
+	})
+	return opset, err
+}
+
+func decodeStringEntry(data []byte) (key, value string, err error) {
+	err = protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			key = f.String()
+		case 2:
+			value = f.String()
+		}
+		return nil
+	})
+	return key, value, err
+}
+
+// maxGraphDepth bounds the nesting of subgraphs in attributes, so that a
+// malformed model cannot exhaust the stack of the recursive decoder
+const maxGraphDepth = 64
+
+// decodeGraph decodes a graph nested in depth subgraphs
+func decodeGraph(data []byte, depth int) (*Graph, error) {
+	if depth > maxGraphDepth {
+		return nil, fmt.Errorf("graph: subgraphs nested deeper than %d", maxGraphDepth)
+	}
+	graph := &Graph{}
+	err := protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			node, err := decodeNode(f.Bytes, depth)
+			if err != nil {
+				return err
+			}
+			graph.Nodes = append(graph.Nodes, node)
+		case 2:
+			graph.Name = f.String()
+		case 5:
+			tensor, err := decodeTensor(f.Bytes)
+			if err != nil {
+				return err
+			}
+			graph.Initializers = append(graph.Initializers, tensor)
+		case 10:
+			graph.DocString = f.String()
+		case 11, 12, 13:
+			info, err := decodeValueInfo(f.Bytes)
+			if err != nil {
+				return err
+			}
+			switch f.Number {
+			case 11:
+				graph.Inputs = append(graph.Inputs, info)
+			case 12:
+				graph.Outputs = append(graph.Outputs, info)
+			default:
+				graph.ValueInfo = append(graph.ValueInfo, info)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("graph: %w", err)
+	}
+	return graph, nil
+}
+
+func decodeNode(data []byte, depth int) (*Node, error) {
+	node := &Node{}
+	err := protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			node.Inputs = append(node.Inputs, f.String())
+		case 2:
+			node.Outputs = append(node.Outputs, f.String())
+		case 3:
+			node.Name = f.String()
+		case 4:
+			node.OpType = f.String()
+		case 5:
+			attr, err := decodeAttribute(f.Bytes, depth)
+			if err != nil {
+				return err
+			}
+			node.Attributes = append(node.Attributes, attr)
+		case 7:
+			node.Domain = f.String()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("node %q: %w", node.Name, err)
+	}
+	return node, nil
+}
+
+func decodeAttribute(data []byte, depth int) (*Attribute, error) {
+	attr := &Attribute{}
+	err := protowire.Walk(data, func(f protowire.Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			attr.Name = f.String()
+		case 20:
+			attr.Type = AttributeType(f.Int32())
+		case 2:
+			attr.Float = f.Float32()
+		case 3:
+			attr.Int = f.Int64()
+		case 4:
+			attr.String = f.String()
+		case 5:
+			attr.Tensor, err = decodeTensor(f.Bytes)
+		case 6:
+			attr.Graph, err = decodeGraph(f.Bytes, depth+1)
+		case 7:
+			attr.Floats, err = protowire.DecodeFloat32s(attr.Floats, f)
+		case 8:
+			attr.Ints, err = protowire.DecodeInt64s(attr.Ints, f)
+		case 9:
+			attr.Strings = append(attr.Strings, f.String())
+		case 10:
+			var tensor *Tensor
+			if tensor, err = decodeTensor(f.Bytes); err == nil {
+				attr.Tensors = append(attr.Tensors, tensor)
+			}
+		case 11:
+			var graph *Graph
+			if graph, err = decodeGraph(f.Bytes, depth+1); err == nil {
+				attr.Graphs = append(attr.Graphs, graph)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attribute %q: %w", attr.Name, err)
+	}
+	return attr, nil
+}
+
+func decodeTensor(data []byte) (*Tensor, error) {
+	tensor := &Tensor{}
+	err := protowire.Walk(data, func(f protowire.Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			tensor.Dims, err = protowire.DecodeInt64s(tensor.Dims, f)
+		case 2:
+			tensor.DataType = DataType(f.Int32())
+		case 4:
+			tensor.FloatData, err = protowire.DecodeFloat32s(tensor.FloatData, f)
+		case 5:
+			tensor.Int32Data, err = protowire.DecodeInt32s(tensor.Int32Data, f)
+		case 6:
+			tensor.StringData = append(tensor.StringData, f.Bytes)
+		case 7:
+			tensor.Int64Data, err = protowire.DecodeInt64s(tensor.Int64Data, f)
+		case 8:
+			tensor.Name = f.String()
+		case 9:
+			tensor.RawData = f.Bytes
+		case 10:
+			tensor.DoubleData, err = protowire.DecodeFloat64s(tensor.DoubleData, f)
+		case 11:
+			tensor.Uint64Data, err = protowire.DecodeVarints(tensor.Uint64Data, f)
+		case 13:
+			var key, value string
+			if key, value, err = decodeStringEntry(f.Bytes); err == nil {
+				if tensor.External == nil {
+					tensor.External = make(map[string]string)
+				}
+				tensor.External[key] = value
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tensor %q: %w", tensor.Name, err)
+	}
+	return tensor, nil
+}
+
+func decodeValueInfo(data []byte) (*ValueInfo, error) {
+	info := &ValueInfo{}
+	err := protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			info.Name = f.String()
+		case 2:
+			return decodeType(f.Bytes, info)
+		case 3:
+			info.DocString = f.String()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("value %q: %w", info.Name, err)
+	}
+	return info, nil
+}
+
+func decodeType(data []byte, info *ValueInfo) error {
+	return protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			info.Kind = KindTensor
+			return decodeTensorType(f.Bytes, info)
+		case 8:
+			info.Kind = KindSparseTensor
+			return decodeTensorType(f.Bytes, info)
+		case 4:
+			info.Kind = KindSequence
+		case 5:
+			info.Kind = KindMap
+		case 9:
+			info.Kind = KindOptional
+		}
+		return nil
+	})
+}
+
+func decodeTensorType(data []byte, info *ValueInfo) error {
+	return protowire.Walk(data, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			info.ElemType = DataType(f.Int32())
+		case 2:
+			info.HasShape = true
+			return protowire.Walk(f.Bytes, func(f protowire.Field) error {
+				if f.Number != 1 {
+					return nil
+				}
+				var dim Dimension
+				err := protowire.Walk(f.Bytes, func(f protowire.Field) error {
+					switch f.Number {
+					case 1:
+						dim.Value = f.Int64()
+					case 2:
+						dim.Param = f.String()
+					}
+					return nil
+				})
+				info.Shape = append(info.Shape, dim)
+				return err
+			})
+		}
+		return nil
+	})
+}
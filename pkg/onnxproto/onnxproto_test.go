@@ -0,0 +1,145 @@
+package onnxproto
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/joeychilson/infergo/internal/protowire"
+)
+
+// testModel uses every part of the format the decoder reads
+func testModel() *Model {
+	return &Model{
+		IRVersion:       8,
+		OpsetImports:    []OperatorSet{{Version: 17}, {Domain: "com.microsoft", Version: 1}},
+		ProducerName:    "pytorch",
+		ProducerVersion: "2.1.0",
+		Domain:          "ai.infergo",
+		ModelVersion:    3,
+		DocString:       "a test model",
+		MetadataProps:   map[string]string{"id2label": `{"0": "cat", "1": "dog"}`},
+		Graph: &Graph{
+			Name: "main",
+			Nodes: []*Node{
+				{Name: "gemm", OpType: "Gemm", Inputs: []string{"x", "w", "b"}, Outputs: []string{"y"}, Attributes: []*Attribute{
+					{Name: "alpha", Type: AttributeFloat, Float: 0.5},
+					{Name: "transB", Type: AttributeInt, Int: 1},
+					{Name: "ints", Type: AttributeInts, Ints: []int64{-1, 0, math.MaxInt64}},
+					{Name: "floats", Type: AttributeFloats, Floats: []float32{1.5, -2}},
+					{Name: "mode", Type: AttributeString, String: "constant"},
+				}},
+				{Name: "if", OpType: "If", Domain: "ai.onnx", Inputs: []string{"cond"}, Outputs: []string{"z"}, Attributes: []*Attribute{
+					{Name: "then_branch", Type: AttributeGraph, Graph: &Graph{
+						Nodes:   []*Node{{OpType: "Relu", Inputs: []string{"y"}, Outputs: []string{"z"}}},
+						Outputs: []*ValueInfo{{Name: "z", Kind: KindTensor, ElemType: DataTypeFloat}},
+					}},
+					{Name: "value", Type: AttributeTensor, Tensor: &Tensor{Dims: []int64{1}, DataType: DataTypeInt64, Int64Data: []int64{-7}}},
+				}},
+			},
+			Initializers: []*Tensor{
+				{Name: "w", Dims: []int64{2, 2}, DataType: DataTypeFloat, FloatData: []float32{1, 2, 3, 4}},
+				{Name: "b", Dims: []int64{2}, DataType: DataTypeFloat, RawData: []byte{0, 0, 128, 63, 0, 0, 0, 64}},
+			},
+			Inputs: []*ValueInfo{{
+				Name: "x", Kind: KindTensor, ElemType: DataTypeFloat, HasShape: true,
+				Shape: []Dimension{{Param: "batch"}, {Value: 2}},
+			}},
+			Outputs: []*ValueInfo{{Name: "z", Kind: KindTensor, ElemType: DataTypeFloat}},
+		},
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	want := testModel()
+	got, err := Decode(Encode(want))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode(Encode(m)) = %+v, want %+v", got, want)
+	}
+
+	bias, err := got.Graph.Initializer("b").Float32s()
+	if err != nil || !reflect.DeepEqual(bias, []float32{1, 2}) {
+		t.Errorf("raw initializer = %v, %v, want [1 2]", bias, err)
+	}
+	if got.Opset("ai.onnx") != 17 || got.Opset("com.microsoft") != 1 {
+		t.Errorf("Opset() = %d, %d", got.Opset(""), got.Opset("com.microsoft"))
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data := Encode(testModel())
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no graph", protowire.AppendVarint(nil, 1, 8)},
+		{"truncated", data[:len(data)-3]},
+		{"truncated graph", protowire.AppendBytes(nil, 7, []byte{0x0a, 0x05, 0x01})},
+		{"overlong varint", append(protowire.AppendTag(nil, 1, protowire.Varint), bytes.Repeat([]byte{0xff}, 11)...)},
+		{"malformed packed floats", protowire.AppendBytes(nil, 7, protowire.AppendBytes(nil, 5, protowire.AppendBytes(nil, 4, []byte{1, 2, 3})))},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(tt.data); err == nil {
+			t.Errorf("%s: Decode() succeeded, want error", tt.name)
+		}
+	}
+}
+
+// nestedModel returns a model whose graph holds depth levels of If subgraphs
+func nestedModel(depth int) *Model {
+	graph := &Graph{}
+	for range depth {
+		graph = &Graph{Nodes: []*Node{{OpType: "If", Attributes: []*Attribute{
+			{Name: "then_branch", Type: AttributeGraph, Graph: graph},
+		}}}}
+	}
+	return &Model{Graph: graph}
+}
+
+func TestDecodeDepth(t *testing.T) {
+	if _, err := Decode(Encode(nestedModel(maxGraphDepth))); err != nil {
+		t.Errorf("Decode() of %d nested subgraphs error = %v", maxGraphDepth, err)
+	}
+	if _, err := Decode(Encode(nestedModel(maxGraphDepth + 1))); err == nil {
+		t.Errorf("Decode() of %d nested subgraphs succeeded, want error", maxGraphDepth+1)
+	}
+}
+
+func FuzzDecodeModel(f *testing.F) {
+	data := Encode(testModel())
+	f.Add(data)
+	for _, n := range []int{1, 10, len(data) / 2, len(data) - 1} {
+		f.Add(data[:n])
+	}
+	f.Add(Encode(&Model{Graph: &Graph{}}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		model, err := Decode(data)
+		if err != nil {
+			return
+		}
+		// everything a decoded model offers must work without panicking on any input
+		model.Opset("")
+		model.Graph.OpHistogram()
+		model.Graph.RuntimeInputs()
+		for _, tensor := range model.Graph.Initializers {
+			tensor.NumElements()
+			tensor.ByteSize()
+			tensor.Float32s()
+			tensor.Int64s()
+		}
+		for _, info := range model.Graph.Inputs {
+			for _, dim := range info.Shape {
+				_ = dim.String()
+			}
+		}
+		if _, err := Decode(Encode(model)); err != nil {
+			t.Errorf("decoding a re-encoded model failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,247 @@
+package onnxproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// DataType is the element type of an ONNX tensor
+type DataType int32
+
+const (
+	DataTypeUndefined DataType = iota
+	DataTypeFloat
+	DataTypeUint8
+	DataTypeInt8
+	DataTypeUint16
+	DataTypeInt16
+	DataTypeInt32
+	DataTypeInt64
+	DataTypeString
+	DataTypeBool
+	DataTypeFloat16
+	DataTypeDouble
+	DataTypeUint32
+	DataTypeUint64
+	DataTypeComplex64
+	DataTypeComplex128
+	DataTypeBFloat16
+	DataTypeFloat8E4M3FN
+	DataTypeFloat8E4M3FNUZ
+	DataTypeFloat8E5M2
+	DataTypeFloat8E5M2FNUZ
+	DataTypeUint4
+	DataTypeInt4
+)
+
+var dataTypeNames = map[DataType]string{
+	DataTypeUndefined:      "undefined",
+	DataTypeFloat:          "float32",
+	DataTypeUint8:          "uint8",
+	DataTypeInt8:           "int8",
+	DataTypeUint16:         "uint16",
+	DataTypeInt16:          "int16",
+	DataTypeInt32:          "int32",
+	DataTypeInt64:          "int64",
+	DataTypeString:         "string",
+	DataTypeBool:           "bool",
+	DataTypeFloat16:        "float16",
+	DataTypeDouble:         "float64",
+	DataTypeUint32:         "uint32",
+	DataTypeUint64:         "uint64",
+	DataTypeComplex64:      "complex64",
+	DataTypeComplex128:     "complex128",
+	DataTypeBFloat16:       "bfloat16",
+	DataTypeFloat8E4M3FN:   "float8e4m3fn",
+	DataTypeFloat8E4M3FNUZ: "float8e4m3fnuz",
+	DataTypeFloat8E5M2:     "float8e5m2",
+	DataTypeFloat8E5M2FNUZ: "float8e5m2fnuz",
+	DataTypeUint4:          "uint4",
+	DataTypeInt4:           "int4",
+}
+
+// String returns the name of the data type
+func (t DataType) String() string {
+	if name, ok := dataTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("DataType(%d)", int32(t))
+}
+
+// Bits returns the storage size of one element in bits, or 0 for variable-size types
+func (t DataType) Bits() int {
+	switch t {
+	case DataTypeUint4, DataTypeInt4:
+		return 4
+	case DataTypeUint8, DataTypeInt8, DataTypeBool,
+		DataTypeFloat8E4M3FN, DataTypeFloat8E4M3FNUZ, DataTypeFloat8E5M2, DataTypeFloat8E5M2FNUZ:
+		return 8
+	case DataTypeUint16, DataTypeInt16, DataTypeFloat16, DataTypeBFloat16:
+		return 16
+	case DataTypeFloat, DataTypeInt32, DataTypeUint32:
+		return 32
+	case DataTypeInt64, DataTypeUint64, DataTypeDouble, DataTypeComplex64:
+		return 64
+	case DataTypeComplex128:
+		return 128
+	}
+	return 0
+}
+
+// isInteger reports whether the data type is an integer or bool type
+func (t DataType) isInteger() bool {
+	switch t {
+	case DataTypeUint8, DataTypeInt8, DataTypeUint16, DataTypeInt16, DataTypeInt32, DataTypeInt64,
+		DataTypeUint32, DataTypeUint64, DataTypeBool, DataTypeUint4, DataTypeInt4:
+		return true
+	}
+	return false
+}
+
+// Tensor is an ONNX TensorProto, used for initializers and constant attributes
+type Tensor struct {
+	Name       string
+	Dims       []int64
+	DataType   DataType
+	RawData    []byte
+	FloatData  []float32
+	Int32Data  []int32
+	Int64Data  []int64
+	DoubleData []float64
+	Uint64Data []uint64
+	StringData [][]byte
+	// External holds the external_data entries of tensors stored outside the model file
+	External map[string]string
+}
+
+// IsExternal reports whether the tensor data is stored outside the model file
+func (t *Tensor) IsExternal() bool {
+	return t.External != nil
+}
+
+// NumElements returns the number of elements described by the tensor dims
+func (t *Tensor) NumElements() int64 {
+	n := int64(1)
+	for _, dim := range t.Dims {
+		n *= dim
+	}
+	return n
+}
+
+// ByteSize returns the size of the tensor data in bytes
+func (t *Tensor) ByteSize() int64 {
+	if t.DataType == DataTypeString {
+		var size int64
+		for _, s := range t.StringData {
+			size += int64(len(s))
+		}
+		return size
+	}
+	return (t.NumElements()*int64(t.DataType.Bits()) + 7) / 8
+}
+
+// Float32s returns the tensor data as float32 values
+func (t *Tensor) Float32s() ([]float32, error) {
+	if t.IsExternal() {
+		return nil, fmt.Errorf("tensor %q: external data is not supported", t.Name)
+	}
+
+	switch t.DataType {
+	case DataTypeFloat:
+		if t.RawData == nil {
+			return t.FloatData, nil
+		}
+		if len(t.RawData)%4 != 0 {
+			return nil, fmt.Errorf("tensor %q: raw data length %d is not a multiple of 4", t.Name, len(t.RawData))
+		}
+		values := make([]float32, len(t.RawData)/4)
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(t.RawData[i*4:]))
+		}
+		return values, nil
+	case DataTypeDouble:
+		doubles := t.DoubleData
+		if t.RawData != nil {
+			if len(t.RawData)%8 != 0 {
+				return nil, fmt.Errorf("tensor %q: raw data length %d is not a multiple of 8", t.Name, len(t.RawData))
+			}
+			doubles = make([]float64, len(t.RawData)/8)
+			for i := range doubles {
+				doubles[i] = math.Float64frombits(binary.LittleEndian.Uint64(t.RawData[i*8:]))
+			}
+		}
+		values := make([]float32, len(doubles))
+		for i, v := range doubles {
+			values[i] = float32(v)
+		}
+		return values, nil
+	}
+
+	ints, err := t.Int64s()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float32, len(ints))
+	for i, v := range ints {
+		values[i] = float32(v)
+	}
+	return values, nil
+}
+
+// Int64s returns the tensor data of an integer or bool tensor as int64 values
+func (t *Tensor) Int64s() ([]int64, error) {
+	if t.IsExternal() {
+		return nil, fmt.Errorf("tensor %q: external data is not supported", t.Name)
+	}
+
+	if t.RawData == nil {
+		switch t.DataType {
+		case DataTypeInt64:
+			return t.Int64Data, nil
+		case DataTypeUint32, DataTypeUint64:
+			values := make([]int64, len(t.Uint64Data))
+			for i, v := range t.Uint64Data {
+				values[i] = int64(v)
+			}
+			return values, nil
+		case DataTypeInt32, DataTypeInt16, DataTypeInt8, DataTypeUint16, DataTypeUint8, DataTypeBool:
+			values := make([]int64, len(t.Int32Data))
+			for i, v := range t.Int32Data {
+				values[i] = int64(v)
+			}
+			return values, nil
+		}
+		return nil, fmt.Errorf("tensor %q: cannot read %s data as integers", t.Name, t.DataType)
+	}
+
+	size := t.DataType.Bits() / 8
+	if !t.DataType.isInteger() || size == 0 {
+		return nil, fmt.Errorf("tensor %q: cannot read %s data as integers", t.Name, t.DataType)
+	}
+	if len(t.RawData)%size != 0 {
+		return nil, fmt.Errorf("tensor %q: raw data length %d is not a multiple of %d", t.Name, len(t.RawData), size)
+	}
+
+	values := make([]int64, len(t.RawData)/size)
+	for i := range values {
+		b := t.RawData[i*size:]
+		switch t.DataType {
+		case DataTypeInt8:
+			values[i] = int64(int8(b[0]))
+		case DataTypeUint8, DataTypeBool:
+			values[i] = int64(b[0])
+		case DataTypeInt16:
+			values[i] = int64(int16(binary.LittleEndian.Uint16(b)))
+		case DataTypeUint16:
+			values[i] = int64(binary.LittleEndian.Uint16(b))
+		case DataTypeInt32:
+			values[i] = int64(int32(binary.LittleEndian.Uint32(b)))
+		case DataTypeUint32:
+			values[i] = int64(binary.LittleEndian.Uint32(b))
+		default:
+			values[i] = int64(binary.LittleEndian.Uint64(b))
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/postprocess"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+// logitsName is the output of classification heads
+const logitsName = "logits"
+
+// ClassificationFunction turns classifier logits into scores
+type ClassificationFunction int
+
+const (
+	// FunctionAuto applies sigmoid to multi-label models and models with a single
+	// output, no function to regression models and softmax to other models
+	FunctionAuto ClassificationFunction = iota
+	// FunctionSoftmax makes the scores of all labels sum to one
+	FunctionSoftmax
+	// FunctionSigmoid scores every label independently
+	FunctionSigmoid
+	// FunctionNone returns the logits unchanged
+	FunctionNone
+)
+
+// TextClassifier classifies texts with a sequence classification model, such as a
+// sentiment or topic classifier
+type TextClassifier struct {
+	encoder   *encoder
+	tokenizer tokenizer.Tokenizer
+	labels    map[int]string
+	logits    int
+	options   options
+}
+
+// NewTextClassifier creates a text classifier from a session that returns logits
+// of shape [batch, labels]
+func NewTextClassifier(session backend.Session, tok tokenizer.Tokenizer, opts ...Option) (*TextClassifier, error) {
+	enc, err := newEncoder(session)
+	if err != nil {
+		return nil, err
+	}
+	logits := enc.output(logitsName)
+	if logits < 0 {
+		return nil, fmt.Errorf("model has no %s output", logitsName)
+	}
+
+	o := newOptions(opts)
+	return &TextClassifier{
+		encoder:   enc,
+		tokenizer: tok,
+		labels:    resolveLabels(o.labels, o.config, session),
+		logits:    logits,
+		options:   o,
+	}, nil
+}
+
+// Classify returns the labels of a text from the best to the worst score
+func (c *TextClassifier) Classify(text string) ([]postprocess.Classification, error) {
+	results, err := c.ClassifyBatch([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// ClassifyBatch classifies texts in batches and returns the labels of every text
+// from the best to the worst score
+func (c *TextClassifier) ClassifyBatch(texts []string) ([][]postprocess.Classification, error) {
+	results := make([][]postprocess.Classification, 0, len(texts))
+	err := batches(len(texts), c.options.batchSize, func(start, end int) error {
+		batch, err := c.encoder.encode(c.tokenizer, texts[start:end], c.options.maxLength)
+		if err != nil {
+			return err
+		}
+		outputs, err := c.encoder.run(batch)
+		if err != nil {
+			return err
+		}
+		logits, numLabels, err := floatOutput(outputs[c.logits], logitsName, batch.BatchSize)
+		if err != nil {
+			return err
+		}
+		rows, err := textRows(batch, end-start)
+		if err != nil {
+			return err
+		}
+
+		return c.encoder.track(metrics.StagePostprocess, batch.BatchSize, func() error {
+			opts := c.classificationOptions(numLabels)
+			for _, row := range rows {
+				classifications, err := postprocess.ProcessClassification(logits[row*numLabels:(row+1)*numLabels], opts)
+				if err != nil {
+					return err
+				}
+				results = append(results, classifications)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// classificationOptions resolves the function, labels and number of results for
+// a model with numLabels outputs
+func (c *TextClassifier) classificationOptions(numLabels int) postprocess.ClassificationOptions {
+	function := c.options.function
+	if function == FunctionAuto {
+		problem := ""
+		if c.options.config != nil {
+			problem = c.options.config.ProblemType
+		}
+		switch {
+		case problem == "regression":
+			function = FunctionNone
+		case problem == "multi_label_classification" || numLabels == 1:
+			function = FunctionSigmoid
+		default:
+			function = FunctionSoftmax
+		}
+	}
+
+	topK := numLabels
+	if c.options.topK > 0 {
+		topK = min(c.options.topK, numLabels)
+	}
+	opts := postprocess.ClassificationOptions{
+		Labels:  defaultLabels(c.labels, numLabels),
+		TopK:    topK,
+		Softmax: function == FunctionSoftmax,
+		Sigmoid: function == FunctionSigmoid,
+	}
+	if function == FunctionNone {
+		// raw logits can be negative and must not fall below the minimum score
+		opts.MinScore = float32(math.Inf(-1))
+	}
+	return opts
+}
+
+// Labels returns the labels of the classes, or nil when the model has none and
+// classes are named LABEL_0, LABEL_1 and so on
+func (c *TextClassifier) Labels() map[int]string {
+	return c.labels
+}
+
+// Close releases the session
+func (c *TextClassifier) Close() error {
+	return c.encoder.session.Close()
+}
+
+// defaultLabels fills in the LABEL_n names that Hugging Face gives classes
+// without a label
+func defaultLabels(labels map[int]string, n int) map[int]string {
+	out := make(map[int]string, n)
+	for i := range n {
+		if label, ok := labels[i]; ok {
+			out[i] = label
+		} else {
+			out[i] = fmt.Sprintf("LABEL_%d", i)
+		}
+	}
+	return out
+}
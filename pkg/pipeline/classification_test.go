@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joeychilson/infergo/models/bert"
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/infergotest"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+func newTestTokenizer(t *testing.T) *tokenizer.BERTTokenizer {
+	t.Helper()
+	tok, err := tokenizer.NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+	return tok
+}
+
+// newTestSession creates an encoder session whose outputs are computed by fn
+func newTestSession(outputs []string, fn func(ids, mask []int64, shape []int64) []*backend.Tensor) *infergotest.Session {
+	var infos []backend.TensorInfo
+	for _, name := range outputs {
+		infos = append(infos, backend.TensorInfo{Name: name, DataType: backend.DataTypeFloat32})
+	}
+	session := infergotest.NewSession(
+		[]backend.TensorInfo{
+			{Name: "input_ids", DataType: backend.DataTypeInt64, Shape: []int64{-1, -1}},
+			{Name: "attention_mask", DataType: backend.DataTypeInt64, Shape: []int64{-1, -1}},
+		},
+		infos,
+	)
+	session.RunFunc = func(inputs []*backend.Tensor) ([]*backend.Tensor, error) {
+		ids, _ := backend.TensorData[int64](inputs[0])
+		mask, _ := backend.TensorData[int64](inputs[1])
+		return fn(ids, mask, inputs[0].Shape), nil
+	}
+	return session
+}
+
+// sentimentSession scores texts with the word "good" as positive
+func sentimentSession(t *testing.T, tok *tokenizer.BERTTokenizer, numLabels int) *infergotest.Session {
+	good, _ := tok.TokenToID("good")
+	return newTestSession([]string{"logits"}, func(ids, mask []int64, shape []int64) []*backend.Tensor {
+		batch, length := int(shape[0]), int(shape[1])
+		logits := make([]float32, batch*numLabels)
+		for row := range batch {
+			score := float32(-2)
+			for _, id := range ids[row*length : (row+1)*length] {
+				if id == good {
+					score = 2
+				}
+			}
+			logits[row*numLabels] = -score
+			logits[row*numLabels+numLabels-1] = score
+		}
+		return []*backend.Tensor{backend.NewTensor([]int64{int64(batch), int64(numLabels)}, logits)}
+	})
+}
+
+func TestTextClassifier(t *testing.T) {
+	tok := newTestTokenizer(t)
+	config, err := ParseModelConfig(strings.NewReader(`{"id2label": {"0": "NEGATIVE", "1": "POSITIVE"}}`))
+	if err != nil {
+		t.Fatalf("ParseModelConfig() error = %v", err)
+	}
+
+	classifier, err := NewTextClassifier(sentimentSession(t, tok, 2), tok, WithModelConfig(config), WithBatchSize(2))
+	if err != nil {
+		t.Fatalf("NewTextClassifier() error = %v", err)
+	}
+	texts := []string{"a good movie", "a dull movie that went on and on", "good"}
+	results, err := classifier.ClassifyBatch(texts)
+	if err != nil {
+		t.Fatalf("ClassifyBatch() error = %v", err)
+	}
+	want := []string{"POSITIVE", "NEGATIVE", "POSITIVE"}
+	for i, result := range results {
+		if len(result) != 2 || result[0].Label != want[i] {
+			t.Errorf("ClassifyBatch()[%d] = %+v, want %s first", i, result, want[i])
+			continue
+		}
+		if sum := result[0].Confidence + result[1].Confidence; math.Abs(float64(sum)-1) > 1e-6 {
+			t.Errorf("softmax scores of %q sum to %f", texts[i], sum)
+		}
+	}
+
+	single, err := classifier.Classify("good")
+	if err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	if single[0].Label != "POSITIVE" || single[0].Confidence != results[2][0].Confidence {
+		t.Errorf("Classify() = %+v, want %+v", single, results[2])
+	}
+}
+
+func TestTextClassifierBERTModel(t *testing.T) {
+	tok := newTestTokenizer(t)
+	model := bert.NewWithSession(sentimentSession(t, tok, 2))
+	classifier, err := NewTextClassifier(model.Session(), tok, WithLabels(map[int]string{0: "NEGATIVE", 1: "POSITIVE"}))
+	if err != nil {
+		t.Fatalf("NewTextClassifier() error = %v", err)
+	}
+	results, err := classifier.Classify("good")
+	if err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	if results[0].Label != "POSITIVE" {
+		t.Errorf("Classify() = %+v, want POSITIVE first", results)
+	}
+}
+
+// overflowTokenizer returns the overflowing windows of long texts as extra rows
+type overflowTokenizer struct {
+	tokenizer.Tokenizer
+}
+
+func (o overflowTokenizer) EncodeBatch(texts []string, maxLength int, opts ...tokenizer.EncodeOption) (*tokenizer.BatchOutput, error) {
+	return o.Tokenizer.EncodeBatch(texts, maxLength, append(opts, tokenizer.WithOverflow(0))...)
+}
+
+func TestTextClassifierOverflowRows(t *testing.T) {
+	tok := newTestTokenizer(t)
+	classifier, err := NewTextClassifier(sentimentSession(t, tok, 2), overflowTokenizer{tok}, WithMaxLength(8), WithLabels(map[int]string{0: "NEGATIVE", 1: "POSITIVE"}))
+	if err != nil {
+		t.Fatalf("NewTextClassifier() error = %v", err)
+	}
+	// the window of the first text that holds "good" is truncated away and must
+	// not be read as the result of the second text
+	results, err := classifier.ClassifyBatch([]string{"a dull movie that went on and on until it was good", "dull"})
+	if err != nil {
+		t.Fatalf("ClassifyBatch() error = %v", err)
+	}
+	if len(results) != 2 || results[0][0].Label != "NEGATIVE" || results[1][0].Label != "NEGATIVE" {
+		t.Errorf("ClassifyBatch() = %+v, want two NEGATIVE results", results)
+	}
+}
+
+func TestTextClassifierLabels(t *testing.T) {
+	tok := newTestTokenizer(t)
+	md := &metadata.Metadata{ID2Label: map[int]string{0: "bad", 1: "meh", 2: "fine"}}
+
+	tests := []struct {
+		name       string
+		numLabels  int
+		metadata   *metadata.Metadata
+		opts       []Option
+		wantLabels []string
+		wantScore  float32
+	}{
+		{
+			name:       "metadata labels",
+			numLabels:  3,
+			metadata:   md,
+			opts:       []Option{WithTopK(1)},
+			wantLabels: []string{"fine"},
+			wantScore:  0.8668,
+		},
+		{
+			name:       "explicit labels win",
+			numLabels:  3,
+			metadata:   md,
+			opts:       []Option{WithLabels(map[int]string{2: "great"}), WithTopK(2)},
+			wantLabels: []string{"great", "LABEL_1"},
+			wantScore:  0.8668,
+		},
+		{
+			name:       "multi-label",
+			numLabels:  3,
+			opts:       []Option{WithModelConfig(&ModelConfig{ProblemType: "multi_label_classification"})},
+			wantLabels: []string{"LABEL_2", "LABEL_1", "LABEL_0"},
+			wantScore:  0.8808,
+		},
+		{
+			name:       "single output",
+			numLabels:  1,
+			wantLabels: []string{"LABEL_0"},
+			wantScore:  0.8808,
+		},
+		{
+			name:       "raw logits",
+			numLabels:  2,
+			opts:       []Option{WithFunction(FunctionNone)},
+			wantLabels: []string{"LABEL_1", "LABEL_0"},
+			wantScore:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := sentimentSession(t, tok, tt.numLabels)
+			if tt.metadata != nil {
+				session.WithMetadata(tt.metadata)
+			}
+			classifier, err := NewTextClassifier(session, tok, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewTextClassifier() error = %v", err)
+			}
+			got, err := classifier.Classify("so good")
+			if err != nil {
+				t.Fatalf("Classify() error = %v", err)
+			}
+			var labels []string
+			for _, c := range got {
+				labels = append(labels, c.Label)
+			}
+			if strings.Join(labels, ",") != strings.Join(tt.wantLabels, ",") {
+				t.Errorf("labels = %q, want %q", labels, tt.wantLabels)
+			}
+			if math.Abs(float64(got[0].Confidence-tt.wantScore)) > 1e-3 {
+				t.Errorf("best score = %f, want %f", got[0].Confidence, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestNewTextClassifierErrors(t *testing.T) {
+	tok := newTestTokenizer(t)
+	if _, err := NewTextClassifier(newTestSession([]string{"last_hidden_state"}, nil), tok); err == nil {
+		t.Error("NewTextClassifier() without a logits output succeeded")
+	}
+	session := infergotest.NewSession([]backend.TensorInfo{{Name: "pixel_values"}}, []backend.TensorInfo{{Name: "logits"}})
+	if _, err := NewTextClassifier(session, tok); err == nil {
+		t.Error("NewTextClassifier() with image inputs succeeded")
+	}
+}
+
+// observedSession is a session that reports to an observer, as ONNX Runtime
+// sessions do
+type observedSession struct {
+	*infergotest.Session
+	observer metrics.Observer
+}
+
+func (s observedSession) Name() string               { return "sentiment" }
+func (s observedSession) Observer() metrics.Observer { return s.observer }
+
+func TestTextClassifierMetrics(t *testing.T) {
+	tok := newTestTokenizer(t)
+	var stages []metrics.Stage
+	observer := metrics.ObserverFunc(func(o metrics.Observation) {
+		if o.Model != "sentiment" || o.Err != nil {
+			t.Errorf("observation = %+v", o)
+		}
+		stages = append(stages, o.Stage)
+	})
+
+	session := observedSession{sentimentSession(t, tok, 2), observer}
+	classifier, err := NewTextClassifier(session, tok, WithBatchSize(2))
+	if err != nil {
+		t.Fatalf("NewTextClassifier() error = %v", err)
+	}
+	if _, err := classifier.ClassifyBatch([]string{"good", "bad", "fine"}); err != nil {
+		t.Fatalf("ClassifyBatch() error = %v", err)
+	}
+	want := []metrics.Stage{metrics.StagePreprocess, metrics.StagePostprocess, metrics.StagePreprocess, metrics.StagePostprocess}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("stages = %v, want %v", stages, want)
+	}
+}
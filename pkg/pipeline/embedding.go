@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/ml"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+const (
+	lastHiddenStateName   = "last_hidden_state"
+	sentenceEmbeddingName = "sentence_embedding"
+)
+
+// Pooling is how the token states of a text are combined into one embedding
+type Pooling int
+
+const (
+	// PoolingMean averages the states of the tokens of the attention mask, as most
+	// sentence-transformers models do
+	PoolingMean Pooling = iota
+	// PoolingCLS takes the state of the first token
+	PoolingCLS
+	// PoolingMax takes the largest value of every dimension over the tokens of the
+	// attention mask
+	PoolingMax
+)
+
+// Embedder turns texts into embedding vectors with a sentence embedding model,
+// such as a sentence-transformers model exported to ONNX
+type Embedder struct {
+	encoder   *encoder
+	tokenizer tokenizer.Tokenizer
+	output    int
+	pooled    bool
+	options   options
+}
+
+// NewEmbedder creates an embedder from a session that returns the pooled
+// sentence_embedding of shape [batch, dim], or the last_hidden_state of shape
+// [batch, sequence, dim] that is pooled by the embedder. sentence_embedding is
+// used when the session returns both.
+func NewEmbedder(session backend.Session, tok tokenizer.Tokenizer, opts ...Option) (*Embedder, error) {
+	enc, err := newEncoder(session)
+	if err != nil {
+		return nil, err
+	}
+	output, pooled := enc.output(sentenceEmbeddingName), true
+	if output < 0 {
+		output, pooled = enc.output(lastHiddenStateName), false
+	}
+	if output < 0 {
+		return nil, fmt.Errorf("model has no %s or %s output", sentenceEmbeddingName, lastHiddenStateName)
+	}
+
+	o := newOptions(opts)
+	if o.pooling < PoolingMean || o.pooling > PoolingMax {
+		return nil, fmt.Errorf("unknown pooling %d", o.pooling)
+	}
+	return &Embedder{encoder: enc, tokenizer: tok, output: output, pooled: pooled, options: o}, nil
+}
+
+// Embed returns the embedding of a text
+func (e *Embedder) Embed(text string) ([]float32, error) {
+	embeddings, err := e.EmbedBatch([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch embeds texts in batches and returns one vector per text. Texts
+// longer than the maximum length are truncated.
+func (e *Embedder) EmbedBatch(texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	err := batches(len(texts), e.options.batchSize, func(start, end int) error {
+		batch, err := e.encoder.encode(e.tokenizer, texts[start:end], e.options.maxLength)
+		if err != nil {
+			return err
+		}
+		outputs, err := e.encoder.run(batch)
+		if err != nil {
+			return err
+		}
+		rows, err := textRows(batch, end-start)
+		if err != nil {
+			return err
+		}
+
+		return e.encoder.track(metrics.StagePostprocess, batch.BatchSize, func() error {
+			if e.pooled {
+				data, dim, err := floatOutput(outputs[e.output], sentenceEmbeddingName, batch.BatchSize)
+				if err != nil {
+					return err
+				}
+				for _, row := range rows {
+					embeddings = append(embeddings, e.normalize(append([]float32(nil), data[row*dim:(row+1)*dim]...)))
+				}
+				return nil
+			}
+
+			states, dim, err := floatOutput(outputs[e.output], lastHiddenStateName, batch.BatchSize, batch.SequenceLength)
+			if err != nil {
+				return err
+			}
+			n := batch.SequenceLength
+			for _, row := range rows {
+				embedding := pool(states[row*n*dim:(row+1)*n*dim], batch.AttentionMask[row*n:(row+1)*n], dim, e.options.pooling)
+				embeddings = append(embeddings, e.normalize(embedding))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+func (e *Embedder) normalize(embedding []float32) []float32 {
+	if !e.options.normalize {
+		return embedding
+	}
+	return ml.L2Normalize(embedding)
+}
+
+// pool combines the [sequence, dim] states of one text into a vector. Padding
+// tokens are skipped, whichever side the text was padded on.
+func pool(states []float32, mask []int64, dim int, pooling Pooling) []float32 {
+	out := make([]float32, dim)
+	switch pooling {
+	case PoolingCLS:
+		for i, m := range mask {
+			if m != 0 {
+				copy(out, states[i*dim:(i+1)*dim])
+				break
+			}
+		}
+	case PoolingMax:
+		first := true
+		for i, m := range mask {
+			if m == 0 {
+				continue
+			}
+			for j, v := range states[i*dim : (i+1)*dim] {
+				if first || v > out[j] {
+					out[j] = v
+				}
+			}
+			first = false
+		}
+	default:
+		var count float32
+		for i, m := range mask {
+			if m == 0 {
+				continue
+			}
+			for j, v := range states[i*dim : (i+1)*dim] {
+				out[j] += v
+			}
+			count++
+		}
+		if count > 0 {
+			for j := range out {
+				out[j] /= count
+			}
+		}
+	}
+	return out
+}
+
+// Close releases the session
+func (e *Embedder) Close() error {
+	return e.encoder.session.Close()
+}
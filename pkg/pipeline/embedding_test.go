@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+func TestPool(t *testing.T) {
+	// three tokens of two dimensions, the first one padding
+	states := []float32{9, 9, 1, -2, 3, 4}
+	mask := []int64{0, 1, 1}
+
+	tests := []struct {
+		pooling Pooling
+		want    []float32
+	}{
+		{PoolingMean, []float32{2, 1}},
+		{PoolingCLS, []float32{1, -2}},
+		{PoolingMax, []float32{3, 4}},
+	}
+	for _, tt := range tests {
+		if got := pool(states, mask, 2, tt.pooling); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pool(%d) = %v, want %v", tt.pooling, got, tt.want)
+		}
+	}
+}
+
+// hiddenStateSession returns the state [id, position] for every token
+func hiddenStateSession() backend.Session {
+	return newTestSession([]string{"last_hidden_state"}, func(ids, mask []int64, shape []int64) []*backend.Tensor {
+		states := make([]float32, 0, len(ids)*2)
+		for i, id := range ids {
+			states = append(states, float32(id), float32(i%int(shape[1])))
+		}
+		return []*backend.Tensor{backend.NewTensor([]int64{shape[0], shape[1], 2}, states)}
+	})
+}
+
+func mustEmbedder(t *testing.T, tok tokenizer.Tokenizer) *Embedder {
+	t.Helper()
+	embedder, err := NewEmbedder(hiddenStateSession(), tok, WithMaxLength(6))
+	if err != nil {
+		t.Fatalf("NewEmbedder() error = %v", err)
+	}
+	return embedder
+}
+
+func TestEmbedder(t *testing.T) {
+	tok := newTestTokenizer(t)
+	texts := []string{"a short text", "a somewhat longer text that needs padding for the others", "hi"}
+
+	for _, pooling := range []Pooling{PoolingMean, PoolingCLS, PoolingMax} {
+		embedder, err := NewEmbedder(hiddenStateSession(), tok, WithPooling(pooling), WithBatchSize(2))
+		if err != nil {
+			t.Fatalf("NewEmbedder() error = %v", err)
+		}
+		batch, err := embedder.EmbedBatch(texts)
+		if err != nil {
+			t.Fatalf("EmbedBatch() error = %v", err)
+		}
+		if len(batch) != len(texts) {
+			t.Fatalf("EmbedBatch() returned %d embeddings, want %d", len(batch), len(texts))
+		}
+		// padding must not change the embedding of a text
+		for i, text := range texts {
+			single, err := embedder.Embed(text)
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+			if !reflect.DeepEqual(single, batch[i]) {
+				t.Errorf("pooling %d: Embed(%q) = %v, batched %v", pooling, text, single, batch[i])
+			}
+		}
+	}
+
+	// overflowing windows of long texts are not embedded
+	long := []string{"a text that is much longer than the maximum length", "hi"}
+	truncated, err := mustEmbedder(t, tok).EmbedBatch(long)
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	overflowed, err := mustEmbedder(t, overflowTokenizer{tok}).EmbedBatch(long)
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	if !reflect.DeepEqual(overflowed, truncated) {
+		t.Errorf("EmbedBatch() with overflow rows = %v, want %v", overflowed, truncated)
+	}
+
+	embedder, err := NewEmbedder(hiddenStateSession(), tok, WithPooling(PoolingCLS))
+	if err != nil {
+		t.Fatalf("NewEmbedder() error = %v", err)
+	}
+	got, err := embedder.Embed("hi")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if cls, _ := tok.TokenToID("[CLS]"); !reflect.DeepEqual(got, []float32{float32(cls), 0}) {
+		t.Errorf("CLS embedding = %v, want the state of [CLS]", got)
+	}
+}
+
+func TestEmbedderSentenceEmbedding(t *testing.T) {
+	tok := newTestTokenizer(t)
+	session := newTestSession([]string{"last_hidden_state", "sentence_embedding"}, func(ids, mask []int64, shape []int64) []*backend.Tensor {
+		batch := int(shape[0])
+		embeddings := make([]float32, 0, batch*2)
+		for range batch {
+			embeddings = append(embeddings, 3, 4)
+		}
+		return []*backend.Tensor{
+			backend.NewTensor([]int64{shape[0], shape[1], 1}, make([]float32, len(ids))),
+			backend.NewTensor([]int64{shape[0], 2}, embeddings),
+		}
+	})
+
+	embedder, err := NewEmbedder(session, tok, WithNormalize(true))
+	if err != nil {
+		t.Fatalf("NewEmbedder() error = %v", err)
+	}
+	got, err := embedder.EmbedBatch([]string{"one", "two"})
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	for _, embedding := range got {
+		if math.Abs(float64(embedding[0]-0.6)) > 1e-6 || math.Abs(float64(embedding[1]-0.8)) > 1e-6 {
+			t.Errorf("embedding = %v, want the normalized sentence_embedding [0.6 0.8]", embedding)
+		}
+	}
+}
+
+func TestNewEmbedderErrors(t *testing.T) {
+	tok := newTestTokenizer(t)
+	if _, err := NewEmbedder(newTestSession([]string{"logits"}, nil), tok); err == nil {
+		t.Error("NewEmbedder() without an embedding output succeeded")
+	}
+	if _, err := NewEmbedder(hiddenStateSession(), tok, WithPooling(Pooling(7))); err == nil {
+		t.Error("NewEmbedder() with an unknown pooling succeeded")
+	}
+}
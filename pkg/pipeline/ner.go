@@ -0,0 +1,277 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/ml"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+// outsideLabel is the tag of tokens that are not part of an entity
+const outsideLabel = "O"
+
+// AggregationStrategy is how token predictions are combined into entities
+type AggregationStrategy int
+
+const (
+	// AggregationNone, the default, returns every token that is not tagged O as an
+	// entity of its own, with the full tag, such as B-PER, as its type
+	AggregationNone AggregationStrategy = iota
+	// AggregationSimple groups consecutive tokens with the same entity type, split
+	// at B- and S- tags, and scores an entity with the mean score of its tokens
+	AggregationSimple
+	// AggregationFirst tags every word with the prediction of its first token
+	// before grouping words like AggregationSimple
+	AggregationFirst
+	// AggregationAverage tags every word with the best label of the scores averaged
+	// over its tokens before grouping words like AggregationSimple
+	AggregationAverage
+	// AggregationMax tags every word with the prediction of its token with the
+	// highest score before grouping words like AggregationSimple
+	AggregationMax
+)
+
+// Entity is a span of text that a token classification model tagged
+type Entity struct {
+	// Type is the entity type without the B-, I-, E- or S- prefix, such as PER, or
+	// the full tag of the token with AggregationNone
+	Type string
+	// Text is the text of the span, Offset.Start to Offset.End of the input
+	Text string
+	// Offset locates the entity in the input text in bytes and in runes
+	Offset tokenizer.Offset
+	// Score is the mean score of the tokens or words of the entity
+	Score float32
+}
+
+// TokenClassifier tags the tokens of texts with a token classification model,
+// such as a named-entity recognition model
+type TokenClassifier struct {
+	encoder   *encoder
+	tokenizer tokenizer.Tokenizer
+	labels    map[int]string
+	logits    int
+	options   options
+}
+
+// NewTokenClassifier creates a token classifier from a session that returns
+// logits of shape [batch, sequence, labels], such as the session of a BERT model
+// for token classification
+func NewTokenClassifier(session backend.Session, tok tokenizer.Tokenizer, opts ...Option) (*TokenClassifier, error) {
+	enc, err := newEncoder(session)
+	if err != nil {
+		return nil, err
+	}
+	logits := enc.output(logitsName)
+	if logits < 0 {
+		return nil, fmt.Errorf("model has no %s output", logitsName)
+	}
+
+	o := newOptions(opts)
+	if o.aggregation < AggregationNone || o.aggregation > AggregationMax {
+		return nil, fmt.Errorf("unknown aggregation strategy %d", o.aggregation)
+	}
+	return &TokenClassifier{
+		encoder:   enc,
+		tokenizer: tok,
+		labels:    resolveLabels(o.labels, o.config, session),
+		logits:    logits,
+		options:   o,
+	}, nil
+}
+
+// Recognize returns the entities of a text in the order they appear. Texts longer
+// than the maximum length are truncated.
+func (c *TokenClassifier) Recognize(text string) ([]Entity, error) {
+	results, err := c.RecognizeBatch([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// RecognizeBatch recognizes the entities of texts in batches
+func (c *TokenClassifier) RecognizeBatch(texts []string) ([][]Entity, error) {
+	results := make([][]Entity, 0, len(texts))
+	err := batches(len(texts), c.options.batchSize, func(start, end int) error {
+		batch, err := c.encoder.encode(c.tokenizer, texts[start:end], c.options.maxLength)
+		if err != nil {
+			return err
+		}
+		outputs, err := c.encoder.run(batch)
+		if err != nil {
+			return err
+		}
+		logits, numLabels, err := floatOutput(outputs[c.logits], logitsName, batch.BatchSize, batch.SequenceLength)
+		if err != nil {
+			return err
+		}
+		rows, err := textRows(batch, end-start)
+		if err != nil {
+			return err
+		}
+		if len(batch.Encodings) != len(rows) {
+			return fmt.Errorf("tokenizer returned %d encodings for %d texts", len(batch.Encodings), len(rows))
+		}
+
+		return c.encoder.track(metrics.StagePostprocess, batch.BatchSize, func() error {
+			labels := defaultLabels(c.labels, numLabels)
+			rowSize := batch.SequenceLength * numLabels
+			for i, row := range rows {
+				tokens := c.tokens(batch.Encodings[i], logits[row*rowSize:(row+1)*rowSize], numLabels)
+				results = append(results, c.entities(texts[start+i], tokens, labels))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// taggedToken is a token, or with word aggregation a word, of the input text with
+// the scores of every label
+type taggedToken struct {
+	offset tokenizer.Offset
+	word   int
+	scores []float32
+	label  int
+}
+
+func (t taggedToken) score() float32 {
+	return t.scores[t.label]
+}
+
+// tokens returns the tokens of the text of an encoding with their scores
+func (c *TokenClassifier) tokens(enc *tokenizer.TokenizerOutput, logits []float32, numLabels int) []taggedToken {
+	var tokens []taggedToken
+	for i, sequence := range enc.SequenceIds {
+		if sequence != 0 {
+			continue
+		}
+		scores := ml.Softmax(logits[i*numLabels : (i+1)*numLabels])
+		tokens = append(tokens, taggedToken{offset: enc.Offsets[i], word: enc.WordIds[i], scores: scores, label: argmax(scores)})
+	}
+	return tokens
+}
+
+// entities aggregates tagged tokens into the entities of text
+func (c *TokenClassifier) entities(text string, tokens []taggedToken, labels map[int]string) []Entity {
+	if c.options.aggregation == AggregationNone {
+		var entities []Entity
+		for _, t := range tokens {
+			if label := labels[t.label]; label != outsideLabel {
+				entities = append(entities, newEntity(text, label, t.offset, t.offset, t.score()))
+			}
+		}
+		return entities
+	}
+	if c.options.aggregation != AggregationSimple {
+		tokens = c.words(tokens)
+	}
+	return groupEntities(text, tokens, labels)
+}
+
+// words merges the tokens of every word into one with the label chosen by the
+// aggregation strategy
+func (c *TokenClassifier) words(tokens []taggedToken) []taggedToken {
+	var words []taggedToken
+	for start := 0; start < len(tokens); {
+		end := start + 1
+		for end < len(tokens) && tokens[start].word >= 0 && tokens[end].word == tokens[start].word {
+			end++
+		}
+		word := tokens[start]
+		word.offset.End = tokens[end-1].offset.End
+		word.offset.RuneEnd = tokens[end-1].offset.RuneEnd
+
+		switch c.options.aggregation {
+		case AggregationAverage:
+			word.scores = make([]float32, len(tokens[start].scores))
+			for _, t := range tokens[start:end] {
+				for i, s := range t.scores {
+					word.scores[i] += s / float32(end-start)
+				}
+			}
+			word.label = argmax(word.scores)
+		case AggregationMax:
+			for _, t := range tokens[start+1 : end] {
+				if t.score() > word.score() {
+					word.scores, word.label = t.scores, t.label
+				}
+			}
+		}
+		words = append(words, word)
+		start = end
+	}
+	return words
+}
+
+// groupEntities groups consecutive tokens of the same entity type into entities.
+// A B- or S- tag starts a new entity and an E- or S- tag ends one, so BIO and
+// BIOES tags are both supported. Tokens tagged O are dropped.
+func groupEntities(text string, tokens []taggedToken, labels map[int]string) []Entity {
+	var entities []Entity
+	for start := 0; start < len(tokens); {
+		prefix, entityType := splitTag(labels[tokens[start].label])
+		end := start + 1
+		for end < len(tokens) && prefix != "E" && prefix != "S" {
+			nextPrefix, nextType := splitTag(labels[tokens[end].label])
+			if nextType != entityType || nextPrefix == "B" || nextPrefix == "S" {
+				break
+			}
+			prefix = nextPrefix
+			end++
+		}
+
+		if entityType != outsideLabel {
+			var score float32
+			for _, t := range tokens[start:end] {
+				score += t.score()
+			}
+			entities = append(entities, newEntity(text, entityType, tokens[start].offset, tokens[end-1].offset, score/float32(end-start)))
+		}
+		start = end
+	}
+	return entities
+}
+
+// splitTag splits a tag such as B-PER into its prefix and entity type. Tags
+// without a B-, I-, E- or S- prefix are treated as inside tags.
+func splitTag(label string) (string, string) {
+	if len(label) > 2 && label[1] == '-' && strings.ContainsRune("BIES", rune(label[0])) {
+		return label[:1], label[2:]
+	}
+	return "I", label
+}
+
+func newEntity(text, entityType string, first, last tokenizer.Offset, score float32) Entity {
+	offset := tokenizer.Offset{Start: first.Start, End: last.End, RuneStart: first.RuneStart, RuneEnd: last.RuneEnd}
+	return Entity{Type: entityType, Text: text[offset.Start:offset.End], Offset: offset, Score: score}
+}
+
+// argmax returns the index of the largest value
+func argmax(values []float32) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// Labels returns the labels of the tags, or nil when the model has none and tags
+// are named LABEL_0, LABEL_1 and so on
+func (c *TokenClassifier) Labels() map[int]string {
+	return c.labels
+}
+
+// Close releases the session
+func (c *TokenClassifier) Close() error {
+	return c.encoder.session.Close()
+}
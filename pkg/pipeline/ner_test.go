@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"math"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+// taggingSession returns the log of the given label probabilities for every token,
+// and certainty of the first label for tokens missing from probs
+func taggingSession(tok *tokenizer.BERTTokenizer, numLabels int, probs map[string][]float64) backend.Session {
+	return newTestSession([]string{"logits"}, func(ids, mask []int64, shape []int64) []*backend.Tensor {
+		logits := make([]float32, 0, len(ids)*numLabels)
+		for _, id := range ids {
+			token, _ := tok.IDToToken(id)
+			p, ok := probs[token]
+			if !ok {
+				p = []float64{1}
+			}
+			for i := range numLabels {
+				var v float64
+				if i < len(p) {
+					v = p[i]
+				}
+				logits = append(logits, float32(math.Log(v+1e-9)))
+			}
+		}
+		return []*backend.Tensor{backend.NewTensor([]int64{shape[0], shape[1], int64(numLabels)}, logits)}
+	})
+}
+
+func TestTokenClassifier(t *testing.T) {
+	tok := newTestTokenizer(t)
+	labels := map[int]string{0: "O", 1: "B-PER", 2: "I-PER", 3: "B-LOC", 4: "I-LOC"}
+	// "pic" leans to B-LOC but "##ard" is more certain of I-PER, so the word
+	// strategies disagree on "picard"
+	probs := map[string][]float64{
+		"jean":  {0, 1},
+		"-":     {0, 0, 1},
+		"luc":   {0, 0, 1},
+		"pic":   {0.1, 0, 0.4, 0.5},
+		"##ard": {0.3, 0, 0.7},
+		"new":   {0.1, 0, 0, 0.9},
+		"york":  {0.2, 0, 0, 0, 0.8},
+	}
+	text := "Jean-Luc Picard visits New York"
+
+	type entity struct {
+		Type, Text string
+		Score      float32
+	}
+	tests := []struct {
+		name        string
+		aggregation AggregationStrategy
+		want        []entity
+	}{
+		{
+			name:        "none",
+			aggregation: AggregationNone,
+			want: []entity{
+				{"B-PER", "Jean", 1}, {"I-PER", "-", 1}, {"I-PER", "Luc", 1}, {"B-LOC", "Pic", 0.5},
+				{"I-PER", "ard", 0.7}, {"B-LOC", "New", 0.9}, {"I-LOC", "York", 0.8},
+			},
+		},
+		{
+			name:        "simple",
+			aggregation: AggregationSimple,
+			want:        []entity{{"PER", "Jean-Luc", 1}, {"LOC", "Pic", 0.5}, {"PER", "ard", 0.7}, {"LOC", "New York", 0.85}},
+		},
+		{
+			name:        "first",
+			aggregation: AggregationFirst,
+			want:        []entity{{"PER", "Jean-Luc", 1}, {"LOC", "Picard", 0.5}, {"LOC", "New York", 0.85}},
+		},
+		{
+			name:        "average",
+			aggregation: AggregationAverage,
+			want:        []entity{{"PER", "Jean-Luc Picard", 0.8875}, {"LOC", "New York", 0.85}},
+		},
+		{
+			name:        "max",
+			aggregation: AggregationMax,
+			want:        []entity{{"PER", "Jean-Luc Picard", 0.925}, {"LOC", "New York", 0.85}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classifier, err := NewTokenClassifier(taggingSession(tok, len(labels), probs), tok,
+				WithLabels(labels), WithAggregation(tt.aggregation))
+			if err != nil {
+				t.Fatalf("NewTokenClassifier() error = %v", err)
+			}
+			got, err := classifier.Recognize(text)
+			if err != nil {
+				t.Fatalf("Recognize() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Recognize() = %+v, want %+v", got, tt.want)
+			}
+			for i, e := range got {
+				w := tt.want[i]
+				if e.Type != w.Type || e.Text != w.Text || math.Abs(float64(e.Score-w.Score)) > 1e-3 {
+					t.Errorf("entity %d = %+v, want %+v", i, e, w)
+				}
+				if text[e.Offset.Start:e.Offset.End] != e.Text || e.Offset.RuneEnd-e.Offset.RuneStart != len(e.Text) {
+					t.Errorf("entity %d offset %+v does not match %q", i, e.Offset, e.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenClassifierBIOES(t *testing.T) {
+	tok := newTestTokenizer(t)
+	labels := map[int]string{0: "O", 1: "B-PER", 2: "I-PER", 3: "E-PER", 4: "S-PER", 5: "S-LOC"}
+	probs := map[string][]float64{
+		"jean":  {0, 1},
+		"luc":   {0, 0, 0, 1},
+		"anna":  {0, 0, 0, 0, 1},
+		"paris": {0, 0, 0, 0, 0, 1},
+	}
+	classifier, err := NewTokenClassifier(taggingSession(tok, len(labels), probs), tok,
+		WithLabels(labels), WithAggregation(AggregationSimple), WithBatchSize(1))
+	if err != nil {
+		t.Fatalf("NewTokenClassifier() error = %v", err)
+	}
+
+	texts := []string{"Jean Luc Anna visit Paris", "Nobody here", "¡Anna!"}
+	got, err := classifier.RecognizeBatch(texts)
+	if err != nil {
+		t.Fatalf("RecognizeBatch() error = %v", err)
+	}
+	want := [][]Entity{
+		{
+			{Type: "PER", Text: "Jean Luc", Offset: tokenizer.Offset{Start: 0, End: 8, RuneStart: 0, RuneEnd: 8}, Score: 1},
+			{Type: "PER", Text: "Anna", Offset: tokenizer.Offset{Start: 9, End: 13, RuneStart: 9, RuneEnd: 13}, Score: 1},
+			{Type: "LOC", Text: "Paris", Offset: tokenizer.Offset{Start: 20, End: 25, RuneStart: 20, RuneEnd: 25}, Score: 1},
+		},
+		nil,
+		{
+			{Type: "PER", Text: "Anna", Offset: tokenizer.Offset{Start: 2, End: 6, RuneStart: 1, RuneEnd: 5}, Score: 1},
+		},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("RecognizeBatch() returned %d results, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Errorf("RecognizeBatch()[%d] = %+v, want %+v", i, got[i], want[i])
+			continue
+		}
+		for j, e := range got[i] {
+			w := want[i][j]
+			if e.Type != w.Type || e.Text != w.Text || e.Offset != w.Offset || math.Abs(float64(e.Score-w.Score)) > 1e-6 {
+				t.Errorf("RecognizeBatch()[%d][%d] = %+v, want %+v", i, j, e, w)
+			}
+		}
+	}
+}
+
+func TestSplitTag(t *testing.T) {
+	tests := []struct {
+		label, prefix, entityType string
+	}{
+		{"B-PER", "B", "PER"},
+		{"I-ORG", "I", "ORG"},
+		{"E-LOC", "E", "LOC"},
+		{"S-MISC", "S", "MISC"},
+		{"O", "I", "O"},
+		{"LABEL_3", "I", "LABEL_3"},
+		{"X-PER", "I", "X-PER"},
+	}
+	for _, tt := range tests {
+		prefix, entityType := splitTag(tt.label)
+		if prefix != tt.prefix || entityType != tt.entityType {
+			t.Errorf("splitTag(%q) = %q, %q, want %q, %q", tt.label, prefix, entityType, tt.prefix, tt.entityType)
+		}
+	}
+}
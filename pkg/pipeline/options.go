@@ -0,0 +1,114 @@
+package pipeline
+
+// Option configures a pipeline. Options that do not apply to a pipeline are
+// ignored.
+type Option func(*options)
+
+type options struct {
+	labels    map[int]string
+	config    *ModelConfig
+	maxLength int
+	batchSize int
+	function  ClassificationFunction
+	topK      int
+
+	aggregation AggregationStrategy
+
+	stride          int
+	maxAnswerLength int
+
+	pooling   Pooling
+	normalize bool
+}
+
+func newOptions(opts []Option) options {
+	o := options{maxLength: defaultMaxLength, batchSize: 32, stride: 128, maxAnswerLength: 15}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithLabels sets the labels of the classes, which otherwise come from the model
+// config or the model metadata
+func WithLabels(labels map[int]string) Option {
+	return func(o *options) {
+		o.labels = labels
+	}
+}
+
+// WithModelConfig uses the labels and problem type of a model's config.json
+func WithModelConfig(config *ModelConfig) Option {
+	return func(o *options) {
+		o.config = config
+	}
+}
+
+// WithMaxLength sets the number of tokens texts are truncated to, 512 by default
+func WithMaxLength(n int) Option {
+	return func(o *options) {
+		o.maxLength = n
+	}
+}
+
+// WithBatchSize sets how many texts are run through the model at once, 32 by
+// default
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithFunction sets the function that turns classifier logits into scores
+func WithFunction(f ClassificationFunction) Option {
+	return func(o *options) {
+		o.function = f
+	}
+}
+
+// WithTopK limits classifications to the k best labels, all by default, and sets
+// the number of answers of a question answerer, one by default
+func WithTopK(k int) Option {
+	return func(o *options) {
+		o.topK = k
+	}
+}
+
+// WithAggregation sets how token classifiers combine token predictions into
+// entities
+func WithAggregation(strategy AggregationStrategy) Option {
+	return func(o *options) {
+		o.aggregation = strategy
+	}
+}
+
+// WithStride sets the number of context tokens that consecutive windows of a long
+// context share, 128 by default
+func WithStride(tokens int) Option {
+	return func(o *options) {
+		o.stride = tokens
+	}
+}
+
+// WithMaxAnswerLength sets the most tokens an answer can have, 15 by default
+func WithMaxAnswerLength(tokens int) Option {
+	return func(o *options) {
+		o.maxAnswerLength = tokens
+	}
+}
+
+// WithPooling sets how an embedder combines token states, PoolingMean by default.
+// It has no effect on models that return a sentence_embedding.
+func WithPooling(pooling Pooling) Option {
+	return func(o *options) {
+		o.pooling = pooling
+	}
+}
+
+// WithNormalize scales embeddings to unit length, so that their dot product is
+// their cosine similarity
+func WithNormalize(enabled bool) Option {
+	return func(o *options) {
+		o.normalize = enabled
+	}
+}
@@ -0,0 +1,261 @@
+// Package pipeline combines tokenizers, transformer encoder sessions and
+// post-processing into end-to-end text tasks.
+//
+// Pipelines take a backend.Session rather than a model of the models packages,
+// because every task reads different outputs: classification reads logits,
+// question answering start_logits and end_logits and embedding
+// last_hidden_state or sentence_embedding. Open creates a session with the
+// outputs a task needs. A loaded bert.Model, whose session returns logits, runs
+// the classification pipelines through its Session method:
+//
+//	model, err := bert.New("model.onnx")
+//	classifier, err := pipeline.NewTextClassifier(model.Session(), tok)
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+const (
+	inputIDsName      = "input_ids"
+	attentionMaskName = "attention_mask"
+	tokenTypeIDsName  = "token_type_ids"
+)
+
+// defaultMaxLength is the sequence length of BERT style encoders
+const defaultMaxLength = 512
+
+// Open creates a session for a transformer encoder that takes input_ids,
+// attention_mask and, when tokenTypes is set, token_type_ids, and returns the
+// given outputs
+func Open(b backend.Backend, modelPath string, tokenTypes bool, outputs ...string) (backend.Session, error) {
+	inputs := []string{inputIDsName, attentionMaskName}
+	if tokenTypes {
+		inputs = append(inputs, tokenTypeIDsName)
+	}
+	return b.NewSession(modelPath, inputs, outputs)
+}
+
+// encoder runs a transformer encoder session on batches of tokenized text
+type encoder struct {
+	session  backend.Session
+	inputs   []string
+	observer metrics.Observer
+	name     string
+}
+
+func newEncoder(session backend.Session) (*encoder, error) {
+	var inputs []string
+	for _, info := range session.Inputs() {
+		switch info.Name {
+		case inputIDsName, attentionMaskName, tokenTypeIDsName:
+			inputs = append(inputs, info.Name)
+		default:
+			return nil, fmt.Errorf("unsupported model input %q", info.Name)
+		}
+	}
+	for _, name := range []string{inputIDsName, attentionMaskName} {
+		if !slices.Contains(inputs, name) {
+			return nil, fmt.Errorf("model has no %s input", name)
+		}
+	}
+	observer, name := metrics.ObserverOf(session)
+	return &encoder{session: session, inputs: inputs, observer: observer, name: name}, nil
+}
+
+// track reports the duration of fn as a stage of the model to the observer of
+// the session, if it has one
+func (e *encoder) track(stage metrics.Stage, batchSize int, fn func() error) error {
+	return metrics.Track(e.observer, e.name, stage, batchSize, fn)
+}
+
+// encode tokenizes a batch of texts, reported as preprocessing
+func (e *encoder) encode(tok tokenizer.Tokenizer, texts []string, maxLength int) (*tokenizer.BatchOutput, error) {
+	var batch *tokenizer.BatchOutput
+	err := e.track(metrics.StagePreprocess, len(texts), func() (err error) {
+		batch, err = tok.EncodeBatch(texts, maxLength)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to tokenize texts: %w", err)
+	}
+	return batch, nil
+}
+
+// textRows returns the row of the batch that holds every text. A tokenizer that
+// returns the overflowing windows of long texts adds rows after the first window
+// of a text, which pipelines that truncate texts leave out.
+func textRows(batch *tokenizer.BatchOutput, texts int) ([]int, error) {
+	if batch.Samples == nil {
+		if batch.BatchSize != texts {
+			return nil, fmt.Errorf("tokenizer returned %d rows for %d texts", batch.BatchSize, texts)
+		}
+		rows := make([]int, texts)
+		for i := range rows {
+			rows[i] = i
+		}
+		return rows, nil
+	}
+
+	rows := make([]int, texts)
+	for i := range rows {
+		rows[i] = -1
+	}
+	for row, sample := range batch.Samples {
+		if sample < 0 || sample >= texts {
+			return nil, fmt.Errorf("row %d belongs to text %d of %d", row, sample, texts)
+		}
+		if rows[sample] < 0 {
+			rows[sample] = row
+		}
+	}
+	if i := slices.Index(rows, -1); i >= 0 {
+		return nil, fmt.Errorf("tokenizer returned no row for text %d", i)
+	}
+	return rows, nil
+}
+
+// output returns the index of the named output of the session, or -1
+func (e *encoder) output(name string) int {
+	return slices.IndexFunc(e.session.Outputs(), func(info backend.TensorInfo) bool {
+		return info.Name == name
+	})
+}
+
+// run runs the session on a batch and returns its outputs
+func (e *encoder) run(batch *tokenizer.BatchOutput) ([]*backend.Tensor, error) {
+	shape := []int64{int64(batch.BatchSize), int64(batch.SequenceLength)}
+	tensors := make([]*backend.Tensor, len(e.inputs))
+	for i, name := range e.inputs {
+		switch name {
+		case inputIDsName:
+			tensors[i] = backend.NewTensor(shape, batch.InputIds)
+		case attentionMaskName:
+			tensors[i] = backend.NewTensor(shape, batch.AttentionMask)
+		case tokenTypeIDsName:
+			tensors[i] = backend.NewTensor(shape, batch.TokenTypeIds)
+		}
+	}
+	outputs, err := e.session.Run(tensors)
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) != len(e.session.Outputs()) {
+		return nil, fmt.Errorf("expected %d outputs, got %d", len(e.session.Outputs()), len(outputs))
+	}
+	return outputs, nil
+}
+
+// floatOutput returns the data of a float32 output whose shape starts with the
+// given dimensions, and the size of the rest of every row
+func floatOutput(t *backend.Tensor, name string, dims ...int) ([]float32, int, error) {
+	data, err := backend.TensorData[float32](t)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid %s output: %w", name, err)
+	}
+	rows := 1
+	for _, d := range dims {
+		rows *= d
+	}
+	if rows == 0 || len(data)%rows != 0 || len(data) == 0 {
+		return nil, 0, fmt.Errorf("%s output has %d values, which does not split into %v rows", name, len(data), dims)
+	}
+	return data, len(data) / rows, nil
+}
+
+// ModelConfig holds the settings of a Hugging Face config.json that pipelines use
+type ModelConfig struct {
+	// ID2Label maps class indices to labels
+	ID2Label map[int]string
+	// ProblemType is single_label_classification, multi_label_classification or
+	// regression when the model declares it
+	ProblemType string
+}
+
+// LoadModelConfig reads a config.json file
+func LoadModelConfig(path string) (*ModelConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+	return ParseModelConfig(f)
+}
+
+// ParseModelConfig reads the contents of a config.json file
+func ParseModelConfig(r io.Reader) (*ModelConfig, error) {
+	var raw struct {
+		ID2Label    json.RawMessage `json:"id2label"`
+		ProblemType string          `json:"problem_type"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	config := &ModelConfig{ProblemType: raw.ProblemType}
+	if len(raw.ID2Label) > 0 && string(raw.ID2Label) != "null" {
+		labels, err := metadata.ParseID2Label(string(raw.ID2Label))
+		if err != nil {
+			return nil, err
+		}
+		config.ID2Label = labels
+	}
+	return config, nil
+}
+
+// resolveLabels picks the labels of a model: explicit labels first, then those of
+// its config.json and then those embedded in its metadata
+func resolveLabels(labels map[int]string, config *ModelConfig, session backend.Session) map[int]string {
+	if labels != nil {
+		return labels
+	}
+	if config != nil && config.ID2Label != nil {
+		return config.ID2Label
+	}
+	if md, err := backend.Metadata(session); err == nil {
+		return md.Labels()
+	}
+	return nil
+}
+
+// stack combines encodings of the same length, such as the overflowing windows
+// of one text, into a batch
+func stack(rows []*tokenizer.TokenizerOutput) *tokenizer.BatchOutput {
+	length := len(rows[0].InputIds)
+	batch := &tokenizer.BatchOutput{
+		InputIds:       make([]int64, 0, len(rows)*length),
+		AttentionMask:  make([]int64, 0, len(rows)*length),
+		TokenTypeIds:   make([]int64, 0, len(rows)*length),
+		BatchSize:      len(rows),
+		SequenceLength: length,
+		Encodings:      rows,
+	}
+	for _, row := range rows {
+		batch.InputIds = append(batch.InputIds, row.InputIds...)
+		batch.AttentionMask = append(batch.AttentionMask, row.AttentionMask...)
+		batch.TokenTypeIds = append(batch.TokenTypeIds, row.TokenTypeIds...)
+	}
+	return batch
+}
+
+// batches calls fn with consecutive ranges of at most size items
+func batches(n, size int, fn func(start, end int) error) error {
+	if size <= 0 {
+		size = n
+	}
+	for start := 0; start < n; start += size {
+		if err := fn(start, min(start+size, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
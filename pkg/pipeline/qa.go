@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metrics"
+	"github.com/joeychilson/infergo/pkg/ml"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+const (
+	startLogitsName = "start_logits"
+	endLogitsName   = "end_logits"
+)
+
+// Answer is a span of the context that answers a question
+type Answer struct {
+	// Text is the text of the span, Offset.Start to Offset.End of the context
+	Text string
+	// Offset locates the answer in the context in bytes and in runes
+	Offset tokenizer.Offset
+	// Score is the probability of the start token times the probability of the
+	// end token of the answer within its window
+	Score float32
+}
+
+// QuestionAnswerer extracts answers to questions from a context with an
+// extractive question answering model, such as a BERT model fine-tuned on SQuAD
+type QuestionAnswerer struct {
+	encoder     *encoder
+	tokenizer   tokenizer.Tokenizer
+	startLogits int
+	endLogits   int
+	options     options
+}
+
+// NewQuestionAnswerer creates a question answerer from a session that returns
+// start_logits and end_logits of shape [batch, sequence]
+func NewQuestionAnswerer(session backend.Session, tok tokenizer.Tokenizer, opts ...Option) (*QuestionAnswerer, error) {
+	enc, err := newEncoder(session)
+	if err != nil {
+		return nil, err
+	}
+	startLogits, endLogits := enc.output(startLogitsName), enc.output(endLogitsName)
+	if startLogits < 0 || endLogits < 0 {
+		return nil, fmt.Errorf("model needs %s and %s outputs", startLogitsName, endLogitsName)
+	}
+
+	o := newOptions(opts)
+	if o.maxAnswerLength <= 0 {
+		return nil, fmt.Errorf("max answer length must be positive, got %d", o.maxAnswerLength)
+	}
+	return &QuestionAnswerer{
+		encoder:     enc,
+		tokenizer:   tok,
+		startLogits: startLogits,
+		endLogits:   endLogits,
+		options:     o,
+	}, nil
+}
+
+// Answer returns the best answers to a question from the best to the worst
+// score, one by default. A context that does not fit the maximum length with the
+// question is split into windows that overlap by the stride, and the answers of
+// all windows are ranked together.
+func (q *QuestionAnswerer) Answer(question, context string) ([]Answer, error) {
+	var enc *tokenizer.TokenizerOutput
+	err := q.encoder.track(metrics.StagePreprocess, 1, func() (err error) {
+		enc, err = q.tokenizer.EncodePair(question, context, q.options.maxLength,
+			tokenizer.WithTruncation(tokenizer.OnlySecond),
+			tokenizer.WithOverflow(q.options.stride),
+			tokenizer.WithPadding(tokenizer.PadLongest))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to tokenize question and context: %w", err)
+	}
+	windows := append([]*tokenizer.TokenizerOutput{enc}, enc.Overflowing...)
+
+	startLogits := make([][]float32, 0, len(windows))
+	endLogits := make([][]float32, 0, len(windows))
+	err = batches(len(windows), q.options.batchSize, func(start, end int) error {
+		batch := stack(windows[start:end])
+		outputs, err := q.encoder.run(batch)
+		if err != nil {
+			return err
+		}
+		starts, _, err := floatOutput(outputs[q.startLogits], startLogitsName, batch.BatchSize, batch.SequenceLength)
+		if err != nil {
+			return err
+		}
+		ends, _, err := floatOutput(outputs[q.endLogits], endLogitsName, batch.BatchSize, batch.SequenceLength)
+		if err != nil {
+			return err
+		}
+		n := batch.SequenceLength
+		for row := range batch.BatchSize {
+			startLogits = append(startLogits, starts[row*n:(row+1)*n])
+			endLogits = append(endLogits, ends[row*n:(row+1)*n])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var answers []Answer
+	err = q.encoder.track(metrics.StagePostprocess, len(windows), func() error {
+		var candidates []Answer
+		for i, window := range windows {
+			candidates = q.spans(candidates, context, window, startLogits[i], endLogits[i])
+		}
+		answers = q.best(candidates)
+		return nil
+	})
+	return answers, err
+}
+
+// spans appends the valid answer spans of a window to candidates. Spans start and
+// end in the context, end at or after their start and have at most the maximum
+// answer length of tokens.
+func (q *QuestionAnswerer) spans(candidates []Answer, context string, window *tokenizer.TokenizerOutput, startLogits, endLogits []float32) []Answer {
+	var positions []int
+	var starts, ends []float32
+	for i, sequence := range window.SequenceIds {
+		if sequence == 1 {
+			positions = append(positions, i)
+			starts = append(starts, startLogits[i])
+			ends = append(ends, endLogits[i])
+		}
+	}
+	// the probabilities are normalized over the context tokens only, so that the
+	// question and special tokens cannot take part of the score
+	starts, ends = ml.Softmax(starts), ml.Softmax(ends)
+
+	for i := range positions {
+		for j := i; j < min(i+q.options.maxAnswerLength, len(positions)); j++ {
+			first, last := window.Offsets[positions[i]], window.Offsets[positions[j]]
+			offset := tokenizer.Offset{Start: first.Start, End: last.End, RuneStart: first.RuneStart, RuneEnd: last.RuneEnd}
+			candidates = append(candidates, Answer{Text: context[offset.Start:offset.End], Offset: offset, Score: starts[i] * ends[j]})
+		}
+	}
+	return candidates
+}
+
+// best returns the top k candidates, keeping only the best score of a span that
+// several windows found
+func (q *QuestionAnswerer) best(candidates []Answer) []Answer {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	topK := max(q.options.topK, 1)
+	answers := make([]Answer, 0, topK)
+	seen := make(map[[2]int]bool)
+	for _, c := range candidates {
+		span := [2]int{c.Offset.Start, c.Offset.End}
+		if seen[span] {
+			continue
+		}
+		seen[span] = true
+		answers = append(answers, c)
+		if len(answers) == topK {
+			break
+		}
+	}
+	return answers
+}
+
+// Close releases the session
+func (q *QuestionAnswerer) Close() error {
+	return q.encoder.session.Close()
+}
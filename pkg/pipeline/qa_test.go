@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/infergotest"
+	"github.com/joeychilson/infergo/pkg/tokenizer"
+)
+
+// spanSession returns the given start and end logits for tokens, and zero for
+// all other tokens
+func spanSession(tok *tokenizer.BERTTokenizer, starts, ends map[string]float32) *infergotest.Session {
+	return newTestSession([]string{"start_logits", "end_logits"}, func(ids, mask []int64, shape []int64) []*backend.Tensor {
+		startLogits := make([]float32, len(ids))
+		endLogits := make([]float32, len(ids))
+		for i, id := range ids {
+			token, _ := tok.IDToToken(id)
+			startLogits[i], endLogits[i] = starts[token], ends[token]
+		}
+		return []*backend.Tensor{backend.NewTensor(shape, startLogits), backend.NewTensor(shape, endLogits)}
+	})
+}
+
+func TestQuestionAnswerer(t *testing.T) {
+	tok := newTestTokenizer(t)
+	context := strings.Repeat("The weather was nice. ", 3) + "Anna lives in Paris, France." + strings.Repeat(" It rained.", 3)
+	session := spanSession(tok, map[string]float32{"paris": 5}, map[string]float32{"paris": 5, "france": 3})
+
+	qa, err := NewQuestionAnswerer(session, tok, WithMaxLength(24), WithStride(6), WithBatchSize(2), WithTopK(2))
+	if err != nil {
+		t.Fatalf("NewQuestionAnswerer() error = %v", err)
+	}
+	answers, err := qa.Answer("Where does Anna live?", context)
+	if err != nil {
+		t.Fatalf("Answer() error = %v", err)
+	}
+	if calls := len(session.Calls()); calls < 2 {
+		t.Errorf("session ran %d times, want the windows split over several batches", calls)
+	}
+
+	start := strings.Index(context, "Paris")
+	want := []Answer{
+		{Text: "Paris", Offset: tokenizer.Offset{Start: start, End: start + 5, RuneStart: start, RuneEnd: start + 5}},
+		{Text: "Paris, France", Offset: tokenizer.Offset{Start: start, End: start + 13, RuneStart: start, RuneEnd: start + 13}},
+	}
+	if len(answers) != len(want) {
+		t.Fatalf("Answer() = %+v, want %d answers", answers, len(want))
+	}
+	for i, a := range answers {
+		if a.Text != want[i].Text || a.Offset != want[i].Offset {
+			t.Errorf("answer %d = %+v, want %+v", i, a, want[i])
+		}
+		if a.Score <= 0 || a.Score > 1 {
+			t.Errorf("answer %d score = %f, want a probability", i, a.Score)
+		}
+	}
+	if answers[0].Score < answers[1].Score {
+		t.Errorf("answers are not sorted by score: %+v", answers)
+	}
+}
+
+func TestQuestionAnswererMaxAnswerLength(t *testing.T) {
+	tok := newTestTokenizer(t)
+	context := "Anna lives in New York City, she says."
+	starts := map[string]float32{"new": 5}
+	ends := map[string]float32{"york": 1, "city": 5}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "default", want: "New York City"},
+		{name: "two tokens", opts: []Option{WithMaxAnswerLength(2)}, want: "New York"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qa, err := NewQuestionAnswerer(spanSession(tok, starts, ends), tok, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewQuestionAnswerer() error = %v", err)
+			}
+			answers, err := qa.Answer("Where does she live?", context)
+			if err != nil {
+				t.Fatalf("Answer() error = %v", err)
+			}
+			if len(answers) != 1 || answers[0].Text != tt.want {
+				t.Errorf("Answer() = %+v, want %q", answers, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewQuestionAnswererErrors(t *testing.T) {
+	tok := newTestTokenizer(t)
+	if _, err := NewQuestionAnswerer(newTestSession([]string{"logits"}, nil), tok); err == nil {
+		t.Error("NewQuestionAnswerer() without span outputs succeeded")
+	}
+	if _, err := NewQuestionAnswerer(spanSession(tok, nil, nil), tok, WithMaxAnswerLength(0)); err == nil {
+		t.Error("NewQuestionAnswerer() with a zero max answer length succeeded")
+	}
+}
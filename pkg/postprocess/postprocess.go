@@ -1,9 +1,11 @@
 package postprocess
 
 import (
+	"errors"
 	"image"
 	"sort"
 
+	"github.com/joeychilson/infergo/pkg/metadata"
 	"github.com/joeychilson/infergo/pkg/ml"
 )
 
@@ -14,21 +16,35 @@ type Classification struct {
 	Confidence float32
 }
 
-// ClassificationOptions contains options for processing classification results
+// ClassificationOptions contains options for processing classification results.
+// Either Labels or Metadata with an id2label must be set. There are no default
+// labels, and ProcessClassification returns an error without them.
 type ClassificationOptions struct {
-	Labels   map[int]string // Label mapping
-	TopK     int            // Number of top predictions to return
-	MinScore float32        // Minimum confidence threshold
-	Softmax  bool           // Whether to apply softmax to logits
+	Labels   map[int]string     // Label mapping
+	Metadata *metadata.Metadata // Model metadata whose id2label is used when Labels is nil
+	TopK     int                // Number of top predictions to return
+	MinScore float32            // Minimum confidence threshold
+	Softmax  bool               // Whether to apply softmax to logits
+	Sigmoid  bool               // Whether to apply sigmoid to every logit, for multi-label models
 }
 
 // ProcessClassification converts raw logits into structured classifications
 func ProcessClassification(logits []float32, opts ClassificationOptions) ([]Classification, error) {
 	probabilities := logits
-	if opts.Softmax {
+	switch {
+	case opts.Softmax:
 		probabilities = ml.Softmax(logits)
+	case opts.Sigmoid:
+		probabilities = make([]float32, len(logits))
+		for i, logit := range logits {
+			probabilities[i] = ml.Sigmoid(logit)
+		}
 	}
 
+	labels, err := resolveLabels(opts.Labels, opts.Metadata)
+	if err != nil {
+		return nil, err
+	}
 	indices := ml.TopK(probabilities, opts.TopK)
 
 	classifications := make([]Classification, 0, len(indices))
@@ -38,7 +54,7 @@ func ProcessClassification(logits []float32, opts ClassificationOptions) ([]Clas
 			continue
 		}
 
-		label, ok := opts.Labels[idx]
+		label, ok := labels[idx]
 		if !ok {
 			continue
 		}
@@ -52,12 +68,15 @@ func ProcessClassification(logits []float32, opts ClassificationOptions) ([]Clas
 	return classifications, nil
 }
 
-// DetectionOptions contains options for processing detection results
+// DetectionOptions contains options for processing detection results. Either
+// Labels or Metadata with an id2label must be set. There are no default labels,
+// and ProcessDetections returns an error without them.
 type DetectionOptions struct {
-	Labels        map[int]string // Label mapping
-	MaxDetections int            // Maximum number of detections to return
-	ConfThreshold float32        // Confidence threshold for detections
-	IoUThreshold  float32        // IoU threshold for NMS
+	Labels        map[int]string     // Label mapping
+	Metadata      *metadata.Metadata // Model metadata whose id2label is used when Labels is nil
+	MaxDetections int                // Maximum number of detections to return
+	ConfThreshold float32            // Confidence threshold for detections
+	IoUThreshold  float32            // IoU threshold for NMS
 }
 
 // Detection represents a detected object with its bounding box
@@ -78,6 +97,10 @@ type Box struct {
 func ProcessDetections(logits []float32, boxes []float32, imageSize image.Point, opts DetectionOptions) ([]Detection, error) {
 	numBoxes := len(boxes) / 4
 	numClasses := len(logits) / numBoxes
+	labels, err := resolveLabels(opts.Labels, opts.Metadata)
+	if err != nil {
+		return nil, err
+	}
 
 	var detections []Detection
 	for i := 0; i < numBoxes; i++ {
@@ -99,7 +122,7 @@ func ProcessDetections(logits []float32, boxes []float32, imageSize image.Point,
 			continue
 		}
 
-		label, ok := opts.Labels[maxClass]
+		label, ok := labels[maxClass]
 		if !ok {
 			continue
 		}
@@ -123,6 +146,18 @@ func ProcessDetections(logits []float32, boxes []float32, imageSize image.Point,
 	return NonMaxSuppression(detections, opts.IoUThreshold), nil
 }
 
+// resolveLabels returns the explicit labels, falling back to the labels embedded
+// in the model. It fails when there are neither, as no label set fits every model.
+func resolveLabels(labels map[int]string, md *metadata.Metadata) (map[int]string, error) {
+	if labels != nil {
+		return labels, nil
+	}
+	if labels = md.Labels(); labels == nil {
+		return nil, errors.New("no labels: set Labels, or Metadata of a model with an id2label")
+	}
+	return labels, nil
+}
+
 // NonMaxSuppression applies non-maximum suppression to remove overlapping detections
 func NonMaxSuppression(detections []Detection, iouThreshold float32) []Detection {
 	if len(detections) == 0 {
@@ -0,0 +1,200 @@
+package postprocess
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/metadata"
+)
+
+func TestProcessClassification(t *testing.T) {
+	labels := map[int]string{0: "cat", 1: "dog", 2: "bird"}
+
+	tests := []struct {
+		name   string
+		logits []float32
+		opts   ClassificationOptions
+		want   []string
+	}{
+		{
+			name:   "top k",
+			logits: []float32{1, 3, 2},
+			opts:   ClassificationOptions{Labels: labels, TopK: 2},
+			want:   []string{"dog", "bird"},
+		},
+		{
+			name:   "min score after softmax",
+			logits: []float32{0, 5, 0},
+			opts:   ClassificationOptions{Labels: labels, TopK: 3, Softmax: true, MinScore: 0.5},
+			want:   []string{"dog"},
+		},
+		{
+			name:   "missing label skipped",
+			logits: []float32{1, 3, 2},
+			opts:   ClassificationOptions{Labels: map[int]string{0: "cat", 2: "bird"}, TopK: 3},
+			want:   []string{"bird", "cat"},
+		},
+		{
+			name:   "metadata labels when labels are nil",
+			logits: []float32{1, 3, 2},
+			opts:   ClassificationOptions{Metadata: &metadata.Metadata{ID2Label: labels}, TopK: 1},
+			want:   []string{"dog"},
+		},
+		{
+			name:   "explicit labels take precedence over metadata",
+			logits: []float32{1, 3, 2},
+			opts: ClassificationOptions{
+				Labels:   map[int]string{1: "puppy"},
+				Metadata: &metadata.Metadata{ID2Label: labels},
+				TopK:     1,
+			},
+			want: []string{"puppy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProcessClassification(tt.logits, tt.opts)
+			if err != nil {
+				t.Fatalf("ProcessClassification: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d classifications %+v, want %v", len(got), got, tt.want)
+			}
+			for i, c := range got {
+				if c.Label != tt.want[i] {
+					t.Errorf("classification %d = %q, want %q", i, c.Label, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProcessClassificationSoftmax(t *testing.T) {
+	got, err := ProcessClassification([]float32{0, 0}, ClassificationOptions{
+		Labels:  map[int]string{0: "a", 1: "b"},
+		TopK:    2,
+		Softmax: true,
+	})
+	if err != nil {
+		t.Fatalf("ProcessClassification: %v", err)
+	}
+	for _, c := range got {
+		if math.Abs(float64(c.Confidence)-0.5) > 1e-6 {
+			t.Errorf("confidence of %q = %f, want 0.5", c.Label, c.Confidence)
+		}
+	}
+}
+
+func TestProcessClassificationSigmoid(t *testing.T) {
+	got, err := ProcessClassification([]float32{0, 2, -2}, ClassificationOptions{
+		Labels:   map[int]string{0: "a", 1: "b", 2: "c"},
+		TopK:     3,
+		Sigmoid:  true,
+		MinScore: 0.5,
+	})
+	if err != nil {
+		t.Fatalf("ProcessClassification: %v", err)
+	}
+	want := []float32{0.880797, 0.5}
+	if len(got) != len(want) {
+		t.Fatalf("got %d classifications, want %d", len(got), len(want))
+	}
+	for i, c := range got {
+		if math.Abs(float64(c.Confidence-want[i])) > 1e-6 {
+			t.Errorf("confidence of %q = %f, want %f", c.Label, c.Confidence, want[i])
+		}
+	}
+}
+
+func TestProcessDetections(t *testing.T) {
+	labels := map[int]string{0: "person", 1: "car"}
+	// Three boxes with two classes and a no-object class; the first two overlap
+	logits := []float32{
+		5, 0, 0,
+		4, 0, 0,
+		0, 5, 0,
+	}
+	boxes := []float32{
+		0.5, 0.5, 0.2, 0.2,
+		0.51, 0.5, 0.2, 0.2,
+		0.2, 0.2, 0.1, 0.1,
+	}
+
+	got, err := ProcessDetections(logits, boxes, image.Point{X: 100, Y: 200}, DetectionOptions{
+		Labels:        labels,
+		ConfThreshold: 0.5,
+		IoUThreshold:  0.5,
+	})
+	if err != nil {
+		t.Fatalf("ProcessDetections: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d detections %+v, want 2", len(got), got)
+	}
+
+	if got[0].Label != "person" || got[1].Label != "car" {
+		t.Errorf("labels = %q, %q, want person, car", got[0].Label, got[1].Label)
+	}
+
+	want := Box{X1: 40, Y1: 80, X2: 60, Y2: 120}
+	if !boxNear(got[0].Box, want) {
+		t.Errorf("box = %+v, want %+v", got[0].Box, want)
+	}
+}
+
+func TestProcessDetectionsMetadataLabels(t *testing.T) {
+	got, err := ProcessDetections([]float32{5, 0}, []float32{0.5, 0.5, 0.2, 0.2}, image.Point{X: 10, Y: 10}, DetectionOptions{
+		Metadata:      &metadata.Metadata{ID2Label: map[int]string{0: "person"}},
+		ConfThreshold: 0.5,
+		IoUThreshold:  0.5,
+	})
+	if err != nil {
+		t.Fatalf("ProcessDetections: %v", err)
+	}
+	if len(got) != 1 || got[0].Label != "person" {
+		t.Errorf("detections = %+v, want one person", got)
+	}
+}
+
+func TestProcessWithoutLabels(t *testing.T) {
+	// without labels or an id2label in the metadata there is no label set that fits
+	// the model, so nothing falls back silently
+	if _, err := ProcessClassification([]float32{1, 2}, ClassificationOptions{TopK: 1}); err == nil {
+		t.Error("ProcessClassification() without labels succeeded")
+	}
+	if _, err := ProcessClassification([]float32{1, 2}, ClassificationOptions{Metadata: &metadata.Metadata{}, TopK: 1}); err == nil {
+		t.Error("ProcessClassification() with metadata without id2label succeeded")
+	}
+	if _, err := ProcessDetections([]float32{5, 0}, []float32{0.5, 0.5, 0.2, 0.2}, image.Point{X: 10, Y: 10}, DetectionOptions{}); err == nil {
+		t.Error("ProcessDetections() without labels succeeded")
+	}
+}
+
+func TestNonMaxSuppression(t *testing.T) {
+	detections := []Detection{
+		{Classification: Classification{Class: 0, Confidence: 0.6}, Box: Box{0, 0, 10, 10}},
+		{Classification: Classification{Class: 0, Confidence: 0.9}, Box: Box{1, 1, 11, 11}},
+		{Classification: Classification{Class: 1, Confidence: 0.8}, Box: Box{0, 0, 10, 10}},
+		{Classification: Classification{Class: 0, Confidence: 0.7}, Box: Box{50, 50, 60, 60}},
+	}
+
+	got := NonMaxSuppression(detections, 0.5)
+	if len(got) != 3 {
+		t.Fatalf("got %d detections, want 3", len(got))
+	}
+
+	wantConfidences := []float32{0.9, 0.8, 0.7}
+	for i, d := range got {
+		if d.Confidence != wantConfidences[i] {
+			t.Errorf("detection %d confidence = %f, want %f", i, d.Confidence, wantConfidences[i])
+		}
+	}
+}
+
+func boxNear(a, b Box) bool {
+	const eps = 1e-3
+	return math.Abs(float64(a.X1-b.X1)) < eps && math.Abs(float64(a.Y1-b.Y1)) < eps &&
+		math.Abs(float64(a.X2-b.X2)) < eps && math.Abs(float64(a.Y2-b.Y2)) < eps
+}
@@ -0,0 +1,226 @@
+package reference
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+)
+
+// window describes a 2-D sliding window over the spatial dimensions of an NCHW tensor
+type window struct {
+	kernel    [2]int64
+	strides   [2]int64
+	dilations [2]int64
+	// pads holds the padding added before each spatial dimension
+	pads [2]int64
+	in   [2]int64
+	out  [2]int64
+}
+
+// newWindow resolves the window attributes shared by Conv and MaxPool
+func newWindow(n *onnxproto.Node, in, kernel [2]int64, ceilMode bool) (*window, error) {
+	w := &window{kernel: kernel, in: in, strides: [2]int64{1, 1}, dilations: [2]int64{1, 1}}
+	if err := readPair(n, "strides", &w.strides); err != nil {
+		return nil, err
+	}
+	if err := readPair(n, "dilations", &w.dilations); err != nil {
+		return nil, err
+	}
+	for i := range 2 {
+		if w.strides[i] <= 0 || w.dilations[i] <= 0 {
+			return nil, fmt.Errorf("strides %v and dilations %v must be positive", w.strides, w.dilations)
+		}
+	}
+
+	var pads [4]int64
+	if p := attrInts(n, "pads"); p != nil {
+		if len(p) != 4 {
+			return nil, fmt.Errorf("expected 4 pads, got %v", p)
+		}
+		copy(pads[:], p)
+	}
+
+	autoPad := attrString(n, "auto_pad", "NOTSET")
+	for i := range 2 {
+		extent := (w.kernel[i]-1)*w.dilations[i] + 1
+		switch autoPad {
+		case "NOTSET":
+			span := in[i] + pads[i] + pads[i+2] - extent
+			if span < 0 {
+				return nil, fmt.Errorf("kernel extent %d exceeds padded input size %d", extent, in[i]+pads[i]+pads[i+2])
+			}
+			w.out[i] = span/w.strides[i] + 1
+			if ceilMode {
+				w.out[i] = (span+w.strides[i]-1)/w.strides[i] + 1
+				// the last window must start inside the input or the leading padding
+				if (w.out[i]-1)*w.strides[i] >= in[i]+pads[i] {
+					w.out[i]--
+				}
+			}
+			w.pads[i] = pads[i]
+		case "VALID":
+			if in[i] < extent {
+				return nil, fmt.Errorf("kernel extent %d exceeds input size %d", extent, in[i])
+			}
+			w.out[i] = (in[i]-extent)/w.strides[i] + 1
+		case "SAME_UPPER", "SAME_LOWER":
+			w.out[i] = (in[i] + w.strides[i] - 1) / w.strides[i]
+			total := max(0, (w.out[i]-1)*w.strides[i]+extent-in[i])
+			w.pads[i] = total / 2
+			if autoPad == "SAME_LOWER" {
+				w.pads[i] = total - total/2
+			}
+		default:
+			return nil, fmt.Errorf("unsupported auto_pad %q", autoPad)
+		}
+	}
+	return w, nil
+}
+
+func readPair(n *onnxproto.Node, name string, dst *[2]int64) error {
+	values := attrInts(n, name)
+	if values == nil {
+		return nil
+	}
+	if len(values) != 2 {
+		return fmt.Errorf("expected 2 %s, got %v", name, values)
+	}
+	copy(dst[:], values)
+	return nil
+}
+
+// convOp implements 2-D grouped convolution on NCHW inputs
+func convOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 2); err != nil {
+		return nil, err
+	}
+	x, weight := inputs[0], inputs[1]
+	var bias *tensor
+	if len(inputs) > 2 {
+		bias = inputs[2]
+	}
+	if err := requireFloat(x, weight, bias); err != nil {
+		return nil, err
+	}
+	if len(x.shape) != 4 || len(weight.shape) != 4 {
+		return nil, fmt.Errorf("only 2-D convolution is supported, got input %v and weight %v", x.shape, weight.shape)
+	}
+
+	batch, channels := x.shape[0], x.shape[1]
+	filters, groupChannels := weight.shape[0], weight.shape[1]
+	group := attrInt(n, "group", 1)
+	if group <= 0 || channels != groupChannels*group || filters%group != 0 {
+		return nil, fmt.Errorf("weight %v is incompatible with input %v and group %d", weight.shape, x.shape, group)
+	}
+	if bias != nil && (len(bias.shape) != 1 || bias.shape[0] != filters) {
+		return nil, fmt.Errorf("bias %v does not match %d filters", bias.shape, filters)
+	}
+
+	kernel := [2]int64{weight.shape[2], weight.shape[3]}
+	if err := readPair(n, "kernel_shape", &kernel); err != nil {
+		return nil, err
+	}
+	if kernel != [2]int64{weight.shape[2], weight.shape[3]} {
+		return nil, fmt.Errorf("kernel_shape %v does not match weight %v", kernel, weight.shape)
+	}
+	w, err := newWindow(n, [2]int64{x.shape[2], x.shape[3]}, kernel, false)
+	if err != nil {
+		return nil, err
+	}
+
+	inH, inW := w.in[0], w.in[1]
+	outH, outW := w.out[0], w.out[1]
+	filtersPerGroup := filters / group
+	out := make([]float32, batch*filters*outH*outW)
+	for b := int64(0); b < batch; b++ {
+		for f := int64(0); f < filters; f++ {
+			g := f / filtersPerGroup
+			var biasValue float32
+			if bias != nil {
+				biasValue = bias.floats[f]
+			}
+			for oh := int64(0); oh < outH; oh++ {
+				for ow := int64(0); ow < outW; ow++ {
+					sum := biasValue
+					for c := int64(0); c < groupChannels; c++ {
+						inputBase := (b*channels + g*groupChannels + c) * inH * inW
+						weightBase := (f*groupChannels + c) * kernel[0] * kernel[1]
+						for kh := int64(0); kh < kernel[0]; kh++ {
+							ih := oh*w.strides[0] + kh*w.dilations[0] - w.pads[0]
+							if ih < 0 || ih >= inH {
+								continue
+							}
+							for kw := int64(0); kw < kernel[1]; kw++ {
+								iw := ow*w.strides[1] + kw*w.dilations[1] - w.pads[1]
+								if iw < 0 || iw >= inW {
+									continue
+								}
+								sum += x.floats[inputBase+ih*inW+iw] * weight.floats[weightBase+kh*kernel[1]+kw]
+							}
+						}
+					}
+					out[((b*filters+f)*outH+oh)*outW+ow] = sum
+				}
+			}
+		}
+	}
+	return []*tensor{newFloats([]int64{batch, filters, outH, outW}, out)}, nil
+}
+
+// maxPoolOp implements 2-D max pooling on NCHW inputs
+func maxPoolOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 1); err != nil {
+		return nil, err
+	}
+	if len(n.Outputs) > 1 && n.Outputs[1] != "" {
+		return nil, fmt.Errorf("the Indices output is not supported")
+	}
+	x := inputs[0]
+	if err := requireFloat(x); err != nil {
+		return nil, err
+	}
+	if len(x.shape) != 4 {
+		return nil, fmt.Errorf("only 2-D pooling is supported, got input %v", x.shape)
+	}
+
+	var kernel [2]int64
+	if attrInts(n, "kernel_shape") == nil {
+		return nil, fmt.Errorf("missing kernel_shape attribute")
+	}
+	if err := readPair(n, "kernel_shape", &kernel); err != nil {
+		return nil, err
+	}
+	w, err := newWindow(n, [2]int64{x.shape[2], x.shape[3]}, kernel, attrInt(n, "ceil_mode", 0) != 0)
+	if err != nil {
+		return nil, err
+	}
+
+	planes := x.shape[0] * x.shape[1]
+	inH, inW := w.in[0], w.in[1]
+	outH, outW := w.out[0], w.out[1]
+	out := make([]float32, planes*outH*outW)
+	for p := int64(0); p < planes; p++ {
+		plane := x.floats[p*inH*inW : (p+1)*inH*inW]
+		for oh := int64(0); oh < outH; oh++ {
+			for ow := int64(0); ow < outW; ow++ {
+				value := float32(math.Inf(-1))
+				for kh := int64(0); kh < kernel[0]; kh++ {
+					ih := oh*w.strides[0] + kh*w.dilations[0] - w.pads[0]
+					if ih < 0 || ih >= inH {
+						continue
+					}
+					for kw := int64(0); kw < kernel[1]; kw++ {
+						iw := ow*w.strides[1] + kw*w.dilations[1] - w.pads[1]
+						if iw < 0 || iw >= inW {
+							continue
+						}
+						value = max(value, plane[ih*inW+iw])
+					}
+				}
+				out[(p*outH+oh)*outW+ow] = value
+			}
+		}
+	}
+	return []*tensor{newFloats([]int64{x.shape[0], x.shape[1], outH, outW}, out)}, nil
+}
@@ -0,0 +1,596 @@
+package reference
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+)
+
+// operator computes the outputs of a node. Optional inputs that are omitted in the
+// graph are passed as nil.
+type operator func(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error)
+
+var operators = map[string]operator{
+	"Add":       binary(func(a, b float32) float32 { return a + b }, func(a, b int64) int64 { return a + b }),
+	"Sub":       binary(func(a, b float32) float32 { return a - b }, func(a, b int64) int64 { return a - b }),
+	"Mul":       binary(func(a, b float32) float32 { return a * b }, func(a, b int64) int64 { return a * b }),
+	"Div":       binary(func(a, b float32) float32 { return a / b }, divInt),
+	"Relu":      unary(func(x float32) float32 { return max(x, 0) }),
+	"Sigmoid":   unary(sigmoid),
+	"Softmax":   softmaxOp,
+	"Gemm":      gemmOp,
+	"MatMul":    matMulOp,
+	"Reshape":   reshapeOp,
+	"Flatten":   flattenOp,
+	"Transpose": transposeOp,
+	"Conv":      convOp,
+	"MaxPool":   maxPoolOp,
+	"Gather":    gatherOp,
+	"Concat":    concatOp,
+	"Constant":  constantOp,
+	"Identity":  identityOp,
+}
+
+// SupportedOps returns the operator types the reference executor implements
+func SupportedOps() []string {
+	ops := make([]string, 0, len(operators))
+	for op := range operators {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func attrInt(n *onnxproto.Node, name string, def int64) int64 {
+	if attr := n.Attribute(name); attr != nil {
+		return attr.Int
+	}
+	return def
+}
+
+func attrFloat(n *onnxproto.Node, name string, def float32) float32 {
+	if attr := n.Attribute(name); attr != nil {
+		return attr.Float
+	}
+	return def
+}
+
+func attrInts(n *onnxproto.Node, name string) []int64 {
+	if attr := n.Attribute(name); attr != nil {
+		return attr.Ints
+	}
+	return nil
+}
+
+func attrString(n *onnxproto.Node, name, def string) string {
+	if attr := n.Attribute(name); attr != nil {
+		return attr.String
+	}
+	return def
+}
+
+// requireInputs checks that the first count inputs are present
+func requireInputs(inputs []*tensor, count int) error {
+	if len(inputs) < count {
+		return fmt.Errorf("expected at least %d inputs, got %d", count, len(inputs))
+	}
+	for i := 0; i < count; i++ {
+		if inputs[i] == nil {
+			return fmt.Errorf("missing required input %d", i)
+		}
+	}
+	return nil
+}
+
+// requireFloat checks that the given inputs are floating point tensors
+func requireFloat(inputs ...*tensor) error {
+	for i, input := range inputs {
+		if input != nil && input.integer {
+			return fmt.Errorf("input %d must be a float tensor", i)
+		}
+	}
+	return nil
+}
+
+func sigmoid(x float32) float32 {
+	return float32(1 / (1 + math.Exp(-float64(x))))
+}
+
+func divInt(a, b int64) int64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+func unary(fn func(float32) float32) operator {
+	return func(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(inputs, 1); err != nil {
+			return nil, err
+		}
+		x := inputs[0]
+		if err := requireFloat(x); err != nil {
+			return nil, err
+		}
+		out := make([]float32, len(x.floats))
+		for i, v := range x.floats {
+			out[i] = fn(v)
+		}
+		return []*tensor{newFloats(x.shape, out)}, nil
+	}
+}
+
+func binary(floatFn func(a, b float32) float32, intFn func(a, b int64) int64) operator {
+	return func(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+		if err := requireInputs(inputs, 2); err != nil {
+			return nil, err
+		}
+		a, b := inputs[0], inputs[1]
+		if a.integer != b.integer {
+			return nil, fmt.Errorf("operands have mismatched types %s and %s", a.kind(), b.kind())
+		}
+
+		shape, err := broadcastShape(a.shape, b.shape)
+		if err != nil {
+			return nil, err
+		}
+		ai := broadcastIndices(a.shape, shape)
+		bi := broadcastIndices(b.shape, shape)
+
+		if a.integer {
+			out := make([]int64, len(ai))
+			for i := range out {
+				out[i] = intFn(a.ints[ai[i]], b.ints[bi[i]])
+			}
+			return []*tensor{newInts(shape, out)}, nil
+		}
+		out := make([]float32, len(ai))
+		for i := range out {
+			out[i] = floatFn(a.floats[ai[i]], b.floats[bi[i]])
+		}
+		return []*tensor{newFloats(shape, out)}, nil
+	}
+}
+
+// softmaxOp implements Softmax. Before opset 13 the input is coerced into a 2-D
+// matrix at axis and normalized per row; from opset 13 on it is normalized along axis.
+func softmaxOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 1); err != nil {
+		return nil, err
+	}
+	x := inputs[0]
+	if err := requireFloat(x); err != nil {
+		return nil, err
+	}
+
+	defaultAxis := int64(-1)
+	if opset < 13 {
+		defaultAxis = 1
+	}
+	axis, err := normalizeAxis(attrInt(n, "axis", defaultAxis), len(x.shape))
+	if err != nil {
+		return nil, err
+	}
+
+	outer := backend.NumElements(x.shape[:axis])
+	length := x.shape[axis]
+	inner := backend.NumElements(x.shape[axis+1:])
+	if opset < 13 {
+		length *= inner
+		inner = 1
+	}
+
+	out := make([]float32, len(x.floats))
+	for o := int64(0); o < outer; o++ {
+		for in := int64(0); in < inner; in++ {
+			base := o*length*inner + in
+			maxValue := float32(math.Inf(-1))
+			for k := int64(0); k < length; k++ {
+				maxValue = max(maxValue, x.floats[base+k*inner])
+			}
+			var sum float64
+			for k := int64(0); k < length; k++ {
+				e := math.Exp(float64(x.floats[base+k*inner] - maxValue))
+				out[base+k*inner] = float32(e)
+				sum += e
+			}
+			for k := int64(0); k < length; k++ {
+				out[base+k*inner] = float32(float64(out[base+k*inner]) / sum)
+			}
+		}
+	}
+	return []*tensor{newFloats(x.shape, out)}, nil
+}
+
+func gemmOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 2); err != nil {
+		return nil, err
+	}
+	a, b := inputs[0], inputs[1]
+	var c *tensor
+	if len(inputs) > 2 {
+		c = inputs[2]
+	}
+	if err := requireFloat(a, b, c); err != nil {
+		return nil, err
+	}
+	if len(a.shape) != 2 || len(b.shape) != 2 {
+		return nil, fmt.Errorf("expected 2-D operands, got shapes %v and %v", a.shape, b.shape)
+	}
+
+	transA := attrInt(n, "transA", 0) != 0
+	transB := attrInt(n, "transB", 0) != 0
+	alpha := attrFloat(n, "alpha", 1)
+	beta := attrFloat(n, "beta", 1)
+
+	m, k := a.shape[0], a.shape[1]
+	if transA {
+		m, k = k, m
+	}
+	kb, cols := b.shape[0], b.shape[1]
+	if transB {
+		kb, cols = cols, kb
+	}
+	if k != kb {
+		return nil, fmt.Errorf("inner dimensions %d and %d do not match", k, kb)
+	}
+
+	shape := []int64{m, cols}
+	out := make([]float32, m*cols)
+	for i := int64(0); i < m; i++ {
+		for j := int64(0); j < cols; j++ {
+			var sum float32
+			for p := int64(0); p < k; p++ {
+				av := a.floats[i*a.shape[1]+p]
+				if transA {
+					av = a.floats[p*a.shape[1]+i]
+				}
+				bv := b.floats[p*b.shape[1]+j]
+				if transB {
+					bv = b.floats[j*b.shape[1]+p]
+				}
+				sum += av * bv
+			}
+			out[i*cols+j] = alpha * sum
+		}
+	}
+
+	if c != nil {
+		// the bias broadcasts to the output, so it may not add dimensions to it
+		if len(c.shape) > 2 {
+			return nil, fmt.Errorf("bias must have rank <= 2, got shape %v", c.shape)
+		}
+		broadcast, err := broadcastShape(c.shape, shape)
+		if err != nil {
+			return nil, fmt.Errorf("bias: %w", err)
+		}
+		if !slices.Equal(broadcast, shape) {
+			return nil, fmt.Errorf("bias of shape %v does not broadcast to output shape %v", c.shape, shape)
+		}
+		ci := broadcastIndices(c.shape, shape)
+		for i := range out {
+			out[i] += beta * c.floats[ci[i]]
+		}
+	}
+	return []*tensor{newFloats(shape, out)}, nil
+}
+
+// matMulOp implements numpy-style matrix multiplication with batch broadcasting
+func matMulOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 2); err != nil {
+		return nil, err
+	}
+	a, b := inputs[0], inputs[1]
+	if err := requireFloat(a, b); err != nil {
+		return nil, err
+	}
+	if len(a.shape) == 0 || len(b.shape) == 0 {
+		return nil, fmt.Errorf("operands must have rank >= 1")
+	}
+
+	aShape, bShape := a.shape, b.shape
+	if len(aShape) == 1 {
+		aShape = []int64{1, aShape[0]}
+	}
+	if len(bShape) == 1 {
+		bShape = []int64{bShape[0], 1}
+	}
+
+	m, k := aShape[len(aShape)-2], aShape[len(aShape)-1]
+	kb, cols := bShape[len(bShape)-2], bShape[len(bShape)-1]
+	if k != kb {
+		return nil, fmt.Errorf("inner dimensions %d and %d do not match", k, kb)
+	}
+
+	aBatch, bBatch := aShape[:len(aShape)-2], bShape[:len(bShape)-2]
+	batch, err := broadcastShape(aBatch, bBatch)
+	if err != nil {
+		return nil, err
+	}
+	ai := broadcastIndices(aBatch, batch)
+	bi := broadcastIndices(bBatch, batch)
+
+	out := make([]float32, int64(len(ai))*m*cols)
+	for batchIndex := range ai {
+		aOff := int64(ai[batchIndex]) * m * k
+		bOff := int64(bi[batchIndex]) * k * cols
+		oOff := int64(batchIndex) * m * cols
+		for i := int64(0); i < m; i++ {
+			for p := int64(0); p < k; p++ {
+				// zeros are not skipped, 0 * Inf and 0 * NaN are NaN as in ONNX Runtime
+				av := a.floats[aOff+i*k+p]
+				row := b.floats[bOff+p*cols : bOff+(p+1)*cols]
+				dst := out[oOff+i*cols : oOff+(i+1)*cols]
+				for j, bv := range row {
+					dst[j] += av * bv
+				}
+			}
+		}
+	}
+
+	shape := append([]int64(nil), batch...)
+	if len(a.shape) > 1 {
+		shape = append(shape, m)
+	}
+	if len(b.shape) > 1 {
+		shape = append(shape, cols)
+	}
+	return []*tensor{newFloats(shape, out)}, nil
+}
+
+func reshapeOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 2); err != nil {
+		return nil, err
+	}
+	x, target := inputs[0], inputs[1]
+	if !target.integer {
+		return nil, fmt.Errorf("shape must be an integer tensor")
+	}
+	allowZero := attrInt(n, "allowzero", 0) != 0
+
+	shape := append([]int64(nil), target.ints...)
+	infer := -1
+	known := int64(1)
+	for i, dim := range shape {
+		switch {
+		case dim == -1:
+			if infer >= 0 {
+				return nil, fmt.Errorf("shape %v has more than one -1 dimension", target.ints)
+			}
+			infer = i
+			continue
+		case dim == 0 && !allowZero:
+			if i >= len(x.shape) {
+				return nil, fmt.Errorf("shape %v copies dimension %d of rank %d input", target.ints, i, len(x.shape))
+			}
+			shape[i] = x.shape[i]
+		case dim < 0:
+			return nil, fmt.Errorf("invalid shape %v", target.ints)
+		}
+		known *= shape[i]
+	}
+
+	size := int64(x.size())
+	if infer >= 0 {
+		if known == 0 || size%known != 0 {
+			return nil, fmt.Errorf("cannot reshape %v into %v", x.shape, target.ints)
+		}
+		shape[infer] = size / known
+	} else if known != size {
+		return nil, fmt.Errorf("cannot reshape %v into %v", x.shape, target.ints)
+	}
+	return []*tensor{x.withShape(shape)}, nil
+}
+
+func flattenOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 1); err != nil {
+		return nil, err
+	}
+	x := inputs[0]
+	axis := attrInt(n, "axis", 1)
+	if axis < 0 {
+		axis += int64(len(x.shape))
+	}
+	if axis < 0 || axis > int64(len(x.shape)) {
+		return nil, fmt.Errorf("axis %d is out of range for rank %d", axis, len(x.shape))
+	}
+	shape := []int64{backend.NumElements(x.shape[:axis]), backend.NumElements(x.shape[axis:])}
+	return []*tensor{x.withShape(shape)}, nil
+}
+
+func transposeOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 1); err != nil {
+		return nil, err
+	}
+	x := inputs[0]
+	rank := len(x.shape)
+
+	perm := attrInts(n, "perm")
+	if perm == nil {
+		perm = make([]int64, rank)
+		for i := range perm {
+			perm[i] = int64(rank - 1 - i)
+		}
+	}
+	if len(perm) != rank {
+		return nil, fmt.Errorf("perm %v does not match rank %d", perm, rank)
+	}
+
+	shape := make([]int64, rank)
+	seen := make([]bool, rank)
+	for i, p := range perm {
+		if p < 0 || p >= int64(rank) || seen[p] {
+			return nil, fmt.Errorf("invalid perm %v", perm)
+		}
+		seen[p] = true
+		shape[i] = x.shape[p]
+	}
+
+	// permuted[i] is the input stride of output dimension i
+	in := strides(x.shape)
+	permuted := make([]int64, rank)
+	for i, p := range perm {
+		permuted[i] = in[p]
+	}
+
+	size := x.size()
+	indices := make([]int, size)
+	counter := make([]int64, rank)
+	index := int64(0)
+	for i := 0; i < size; i++ {
+		indices[i] = int(index)
+		for d := rank - 1; d >= 0; d-- {
+			counter[d]++
+			index += permuted[d]
+			if counter[d] < shape[d] {
+				break
+			}
+			index -= permuted[d] * counter[d]
+			counter[d] = 0
+		}
+	}
+	return []*tensor{x.gather(shape, indices)}, nil
+}
+
+func gatherOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 2); err != nil {
+		return nil, err
+	}
+	data, indices := inputs[0], inputs[1]
+	if !indices.integer {
+		return nil, fmt.Errorf("indices must be an integer tensor")
+	}
+	axis, err := normalizeAxis(attrInt(n, "axis", 0), len(data.shape))
+	if err != nil {
+		return nil, err
+	}
+
+	outer := backend.NumElements(data.shape[:axis])
+	dim := data.shape[axis]
+	inner := backend.NumElements(data.shape[axis+1:])
+
+	shape := append([]int64(nil), data.shape[:axis]...)
+	shape = append(shape, indices.shape...)
+	shape = append(shape, data.shape[axis+1:]...)
+
+	positions := make([]int, 0, backend.NumElements(shape))
+	for o := int64(0); o < outer; o++ {
+		for _, index := range indices.ints {
+			if index < 0 {
+				index += dim
+			}
+			if index < 0 || index >= dim {
+				return nil, fmt.Errorf("index %d is out of range for axis of size %d", index, dim)
+			}
+			base := (o*dim + index) * inner
+			for in := int64(0); in < inner; in++ {
+				positions = append(positions, int(base+in))
+			}
+		}
+	}
+	return []*tensor{data.gather(shape, positions)}, nil
+}
+
+func concatOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("expected at least 1 input")
+	}
+	if err := requireInputs(inputs, len(inputs)); err != nil {
+		return nil, err
+	}
+	first := inputs[0]
+	if n.Attribute("axis") == nil {
+		return nil, fmt.Errorf("missing axis attribute")
+	}
+	axis, err := normalizeAxis(attrInt(n, "axis", 0), len(first.shape))
+	if err != nil {
+		return nil, err
+	}
+
+	shape := append([]int64(nil), first.shape...)
+	shape[axis] = 0
+	for i, input := range inputs {
+		if input.integer != first.integer {
+			return nil, fmt.Errorf("input %d has type %s, expected %s", i, input.kind(), first.kind())
+		}
+		if len(input.shape) != len(first.shape) {
+			return nil, fmt.Errorf("input %d has rank %d, expected %d", i, len(input.shape), len(first.shape))
+		}
+		for d, dim := range input.shape {
+			if d != axis && dim != first.shape[d] {
+				return nil, fmt.Errorf("input %d has shape %v, incompatible with %v", i, input.shape, first.shape)
+			}
+		}
+		shape[axis] += input.shape[axis]
+	}
+
+	outer := backend.NumElements(first.shape[:axis])
+	inner := backend.NumElements(first.shape[axis+1:])
+	out := &tensor{shape: shape, integer: first.integer}
+	for o := int64(0); o < outer; o++ {
+		for _, input := range inputs {
+			chunk := input.shape[axis] * inner
+			start, end := o*chunk, (o+1)*chunk
+			if input.integer {
+				out.ints = append(out.ints, input.ints[start:end]...)
+			} else {
+				out.floats = append(out.floats, input.floats[start:end]...)
+			}
+		}
+	}
+	return []*tensor{out}, nil
+}
+
+func constantOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	for _, attr := range n.Attributes {
+		switch attr.Name {
+		case "value":
+			t, err := fromProto(attr.Tensor)
+			if err != nil {
+				return nil, err
+			}
+			return []*tensor{t}, nil
+		case "value_float":
+			return []*tensor{newFloats([]int64{}, []float32{attr.Float})}, nil
+		case "value_floats":
+			return []*tensor{newFloats([]int64{int64(len(attr.Floats))}, append([]float32(nil), attr.Floats...))}, nil
+		case "value_int":
+			return []*tensor{newInts([]int64{}, []int64{attr.Int})}, nil
+		case "value_ints":
+			return []*tensor{newInts([]int64{int64(len(attr.Ints))}, append([]int64(nil), attr.Ints...))}, nil
+		}
+	}
+	return nil, fmt.Errorf("missing or unsupported value attribute")
+}
+
+func identityOp(n *onnxproto.Node, inputs []*tensor, opset int64) ([]*tensor, error) {
+	if err := requireInputs(inputs, 1); err != nil {
+		return nil, err
+	}
+	return []*tensor{inputs[0]}, nil
+}
+
+// withShape returns a tensor sharing the data of t with a new shape
+func (t *tensor) withShape(shape []int64) *tensor {
+	return &tensor{shape: shape, integer: t.integer, floats: t.floats, ints: t.ints}
+}
+
+// gather returns a tensor of the given shape holding the elements of t at indices
+func (t *tensor) gather(shape []int64, indices []int) *tensor {
+	if t.integer {
+		out := make([]int64, len(indices))
+		for i, index := range indices {
+			out[i] = t.ints[index]
+		}
+		return newInts(shape, out)
+	}
+	out := make([]float32, len(indices))
+	for i, index := range indices {
+		out[i] = t.floats[index]
+	}
+	return newFloats(shape, out)
+}
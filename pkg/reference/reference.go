@@ -0,0 +1,259 @@
+package reference
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/metadata"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+)
+
+// Session executes an ONNX model with the pure-Go reference operators. It is meant
+// for tests and numeric cross-checks on small models, not for production inference.
+type Session struct {
+	model        *onnxproto.Model
+	opset        int64
+	initializers map[string]*tensor
+	inputs       []backend.TensorInfo
+	outputs      []backend.TensorInfo
+	outputTypes  []onnxproto.DataType
+}
+
+// NewSession loads the model at modelPath bound to the given inputs and outputs
+func NewSession(modelPath string, inputNames, outputNames []string) (*Session, error) {
+	model, err := onnxproto.ReadFile(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewSessionFromModel(model, inputNames, outputNames)
+}
+
+// NewSessionFromModel creates a session for an already decoded model
+func NewSessionFromModel(model *onnxproto.Model, inputNames, outputNames []string) (*Session, error) {
+	graph := model.Graph
+	for _, node := range graph.Nodes {
+		if node.Domain != "" && node.Domain != "ai.onnx" {
+			return nil, fmt.Errorf("unsupported operator %s.%s", node.Domain, node.OpType)
+		}
+		if _, ok := operators[node.OpType]; !ok {
+			return nil, fmt.Errorf("unsupported operator %s", node.OpType)
+		}
+	}
+
+	s := &Session{
+		model:        model,
+		opset:        model.Opset(""),
+		initializers: make(map[string]*tensor, len(graph.Initializers)),
+	}
+
+	for _, init := range graph.Initializers {
+		t, err := fromProto(init)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load initializer %q: %w", init.Name, err)
+		}
+		s.initializers[init.Name] = t
+	}
+
+	runtimeInputs := graph.RuntimeInputs()
+	for _, name := range inputNames {
+		i := slices.IndexFunc(runtimeInputs, func(v *onnxproto.ValueInfo) bool { return v.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("model has no input %q", name)
+		}
+		s.inputs = append(s.inputs, tensorInfo(runtimeInputs[i]))
+	}
+
+	produced := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		for _, output := range node.Outputs {
+			produced[output] = true
+		}
+	}
+	for _, name := range outputNames {
+		i := slices.IndexFunc(graph.Outputs, func(v *onnxproto.ValueInfo) bool { return v.Name == name })
+		switch {
+		case i >= 0:
+			s.outputs = append(s.outputs, tensorInfo(graph.Outputs[i]))
+			s.outputTypes = append(s.outputTypes, graph.Outputs[i].ElemType)
+		case produced[name] || s.initializers[name] != nil:
+			s.outputs = append(s.outputs, backend.TensorInfo{Name: name})
+			s.outputTypes = append(s.outputTypes, onnxproto.DataTypeUndefined)
+		default:
+			return nil, fmt.Errorf("model has no output %q", name)
+		}
+	}
+	return s, nil
+}
+
+// Backend is the pure-Go implementation of backend.Backend
+type Backend struct{}
+
+// NewBackend creates a new reference backend
+func NewBackend() *Backend {
+	return &Backend{}
+}
+
+// NewSession creates a new reference session for the model at modelPath
+func (b *Backend) NewSession(modelPath string, inputNames, outputNames []string) (backend.Session, error) {
+	return NewSession(modelPath, inputNames, outputNames)
+}
+
+func tensorInfo(v *onnxproto.ValueInfo) backend.TensorInfo {
+	info := backend.TensorInfo{Name: v.Name, DataType: fromProtoDataType(v.ElemType)}
+	if v.HasShape {
+		info.Shape = make([]int64, len(v.Shape))
+		for i, dim := range v.Shape {
+			info.Shape[i] = -1
+			if dim.Value > 0 {
+				info.Shape[i] = dim.Value
+			}
+		}
+	}
+	return info
+}
+
+func fromProtoDataType(t onnxproto.DataType) backend.DataType {
+	switch t {
+	case onnxproto.DataTypeFloat:
+		return backend.DataTypeFloat32
+	case onnxproto.DataTypeDouble:
+		return backend.DataTypeFloat64
+	case onnxproto.DataTypeInt64:
+		return backend.DataTypeInt64
+	case onnxproto.DataTypeInt32:
+		return backend.DataTypeInt32
+	case onnxproto.DataTypeInt8:
+		return backend.DataTypeInt8
+	case onnxproto.DataTypeUint8:
+		return backend.DataTypeUint8
+	case onnxproto.DataTypeBool:
+		return backend.DataTypeBool
+	}
+	return backend.DataTypeUndefined
+}
+
+// Inputs describes the session inputs
+func (s *Session) Inputs() []backend.TensorInfo {
+	return s.inputs
+}
+
+// Outputs describes the session outputs
+func (s *Session) Outputs() []backend.TensorInfo {
+	return s.outputs
+}
+
+// Metadata returns the metadata stored in the session's model
+func (s *Session) Metadata() (*metadata.Metadata, error) {
+	m := s.model
+	return metadata.New(m.ProducerName, m.Graph.Name, m.Domain, m.DocString, m.ModelVersion, m.MetadataProps)
+}
+
+// Run executes the graph nodes in order, returning outputs in the order of the
+// session's output names
+func (s *Session) Run(inputs []*backend.Tensor) ([]*backend.Tensor, error) {
+	if len(inputs) != len(s.inputs) {
+		return nil, fmt.Errorf("expected %d inputs, got %d", len(s.inputs), len(inputs))
+	}
+
+	values := make(map[string]*tensor, len(s.initializers)+len(s.model.Graph.Nodes))
+	for name, t := range s.initializers {
+		values[name] = t
+	}
+	for i, input := range inputs {
+		t, err := fromBackend(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input %q: %w", s.inputs[i].Name, err)
+		}
+		values[s.inputs[i].Name] = t
+	}
+
+	for i, node := range s.model.Graph.Nodes {
+		nodeInputs := make([]*tensor, len(node.Inputs))
+		for j, name := range node.Inputs {
+			if name == "" {
+				continue
+			}
+			value, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("node %d (%s): input %q is not available", i, node.OpType, name)
+			}
+			nodeInputs[j] = value
+		}
+
+		outputs, err := operators[node.OpType](node, nodeInputs, s.opset)
+		if err != nil {
+			return nil, fmt.Errorf("node %d (%s %s): %w", i, node.OpType, node.Name, err)
+		}
+		for j, name := range node.Outputs {
+			if name != "" && j < len(outputs) {
+				values[name] = outputs[j]
+			}
+		}
+	}
+
+	outputs := make([]*backend.Tensor, len(s.outputs))
+	for i, info := range s.outputs {
+		value, ok := values[info.Name]
+		if !ok {
+			return nil, fmt.Errorf("output %q was not computed", info.Name)
+		}
+		output, err := toBackend(value, s.outputTypes[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert output %q: %w", info.Name, err)
+		}
+		outputs[i] = output
+	}
+	return outputs, nil
+}
+
+// Close releases resources
+func (s *Session) Close() error {
+	return nil
+}
+
+// CrossCheck compares the outputs of two sessions, typically ONNX Runtime against
+// the reference executor. Values match when |want-got| <= atol + rtol*|want|.
+func CrossCheck(want, got []*backend.Tensor, atol, rtol float64) error {
+	if len(want) != len(got) {
+		return fmt.Errorf("expected %d outputs, got %d", len(want), len(got))
+	}
+
+	for i := range want {
+		if !slices.Equal(want[i].Shape, got[i].Shape) {
+			return fmt.Errorf("output %d: expected shape %v, got %v", i, want[i].Shape, got[i].Shape)
+		}
+		if want[i].DataType() != got[i].DataType() {
+			return fmt.Errorf("output %d: expected %s data, got %s", i, want[i].DataType(), got[i].DataType())
+		}
+
+		w, err := fromBackend(want[i])
+		if err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+		g, err := fromBackend(got[i])
+		if err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+
+		if w.integer {
+			for j := range w.ints {
+				if w.ints[j] != g.ints[j] {
+					return fmt.Errorf("output %d: element %d: expected %d, got %d", i, j, w.ints[j], g.ints[j])
+				}
+			}
+			continue
+		}
+		for j := range w.floats {
+			wv, gv := float64(w.floats[j]), float64(g.floats[j])
+			if math.IsNaN(wv) && math.IsNaN(gv) {
+				continue
+			}
+			if !(math.Abs(wv-gv) <= atol+rtol*math.Abs(wv)) {
+				return fmt.Errorf("output %d: element %d: expected %g, got %g", i, j, wv, gv)
+			}
+		}
+	}
+	return nil
+}
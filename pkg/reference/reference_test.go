@@ -0,0 +1,432 @@
+package reference
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+)
+
+func intAttr(name string, value int64) *onnxproto.Attribute {
+	return &onnxproto.Attribute{Name: name, Type: onnxproto.AttributeInt, Int: value}
+}
+
+func intsAttr(name string, values ...int64) *onnxproto.Attribute {
+	return &onnxproto.Attribute{Name: name, Type: onnxproto.AttributeInts, Ints: values}
+}
+
+func floatAttr(name string, value float32) *onnxproto.Attribute {
+	return &onnxproto.Attribute{Name: name, Type: onnxproto.AttributeFloat, Float: value}
+}
+
+func stringAttr(name, value string) *onnxproto.Attribute {
+	return &onnxproto.Attribute{Name: name, Type: onnxproto.AttributeString, String: value}
+}
+
+func floats(shape []int64, data ...float32) *backend.Tensor {
+	return backend.NewTensor(shape, data)
+}
+
+func ints(shape []int64, data ...int64) *backend.Tensor {
+	return backend.NewTensor(shape, data)
+}
+
+// runNode executes a graph consisting of a single node whose inputs are all runtime inputs
+func runNode(opset int64, node *onnxproto.Node, inputs ...*backend.Tensor) ([]*backend.Tensor, error) {
+	graph := &onnxproto.Graph{Nodes: []*onnxproto.Node{node}}
+	var inputNames []string
+	for _, name := range node.Inputs {
+		if name != "" {
+			graph.Inputs = append(graph.Inputs, &onnxproto.ValueInfo{Name: name, Kind: onnxproto.KindTensor})
+			inputNames = append(inputNames, name)
+		}
+	}
+	model := &onnxproto.Model{
+		IRVersion:    8,
+		OpsetImports: []onnxproto.OperatorSet{{Version: opset}},
+		Graph:        graph,
+	}
+
+	session, err := NewSessionFromModel(model, inputNames, node.Outputs[:1])
+	if err != nil {
+		return nil, err
+	}
+	return session.Run(inputs)
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		name   string
+		opset  int64
+		node   *onnxproto.Node
+		inputs []*backend.Tensor
+		want   *backend.Tensor
+	}{
+		{
+			name:   "Add broadcast",
+			node:   &onnxproto.Node{OpType: "Add", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2, 3}, 1, 2, 3, 4, 5, 6), floats([]int64{3}, 10, 20, 30)},
+			want:   floats([]int64{2, 3}, 11, 22, 33, 14, 25, 36),
+		},
+		{
+			name:   "Add column broadcast",
+			node:   &onnxproto.Node{OpType: "Add", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2, 1}, 1, 2), floats([]int64{1, 3}, 10, 20, 30)},
+			want:   floats([]int64{2, 3}, 11, 21, 31, 12, 22, 32),
+		},
+		{
+			name:   "Mul integer scalar",
+			node:   &onnxproto.Node{OpType: "Mul", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{ints([]int64{2}, 2, 3), ints([]int64{}, 4)},
+			want:   ints([]int64{2}, 8, 12),
+		},
+		{
+			name:   "Relu",
+			node:   &onnxproto.Node{OpType: "Relu", Inputs: []string{"x"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{3}, -1, 0, 2)},
+			want:   floats([]int64{3}, 0, 0, 2),
+		},
+		{
+			name:   "Sigmoid",
+			node:   &onnxproto.Node{OpType: "Sigmoid", Inputs: []string{"x"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2}, 0, float32(math.Log(3)))},
+			want:   floats([]int64{2}, 0.5, 0.75),
+		},
+		{
+			name:   "Softmax",
+			opset:  13,
+			node:   &onnxproto.Node{OpType: "Softmax", Inputs: []string{"x"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{1, 3}, 1, 2, 3)},
+			want:   floats([]int64{1, 3}, 0.09003057, 0.24472847, 0.66524096),
+		},
+		{
+			name:   "Softmax axis",
+			opset:  13,
+			node:   &onnxproto.Node{OpType: "Softmax", Inputs: []string{"x"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{intAttr("axis", 1)}},
+			inputs: []*backend.Tensor{floats([]int64{1, 2, 2}, 0, 0, 0, 0)},
+			want:   floats([]int64{1, 2, 2}, 0.5, 0.5, 0.5, 0.5),
+		},
+		{
+			name:   "Softmax opset 11 coerces to 2-D",
+			opset:  11,
+			node:   &onnxproto.Node{OpType: "Softmax", Inputs: []string{"x"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{intAttr("axis", 1)}},
+			inputs: []*backend.Tensor{floats([]int64{1, 2, 2}, 0, 0, 0, 0)},
+			want:   floats([]int64{1, 2, 2}, 0.25, 0.25, 0.25, 0.25),
+		},
+		{
+			name: "Gemm",
+			node: &onnxproto.Node{OpType: "Gemm", Inputs: []string{"a", "b", "c"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{
+				intAttr("transB", 1), floatAttr("alpha", 2), floatAttr("beta", 0.5),
+			}},
+			inputs: []*backend.Tensor{floats([]int64{2, 2}, 1, 2, 3, 4), floats([]int64{2, 2}, 1, 2, 3, 4), floats([]int64{1}, 1)},
+			want:   floats([]int64{2, 2}, 10.5, 22.5, 22.5, 50.5),
+		},
+		{
+			name:   "Gemm transA",
+			node:   &onnxproto.Node{OpType: "Gemm", Inputs: []string{"a", "b"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{intAttr("transA", 1)}},
+			inputs: []*backend.Tensor{floats([]int64{2, 1}, 1, 2), floats([]int64{2, 2}, 1, 2, 3, 4)},
+			want:   floats([]int64{1, 2}, 7, 10),
+		},
+		{
+			name:   "MatMul batch broadcast",
+			node:   &onnxproto.Node{OpType: "MatMul", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2, 1, 2}, 1, 2, 3, 4), floats([]int64{2, 1}, 1, 1)},
+			want:   floats([]int64{2, 1, 1}, 3, 7),
+		},
+		{
+			name:   "MatMul vector",
+			node:   &onnxproto.Node{OpType: "MatMul", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2}, 1, 2), floats([]int64{2, 2}, 1, 2, 3, 4)},
+			want:   floats([]int64{2}, 7, 10),
+		},
+		{
+			name:   "Reshape",
+			node:   &onnxproto.Node{OpType: "Reshape", Inputs: []string{"x", "shape"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2, 3}, 1, 2, 3, 4, 5, 6), ints([]int64{2}, 3, -1)},
+			want:   floats([]int64{3, 2}, 1, 2, 3, 4, 5, 6),
+		},
+		{
+			name:   "Reshape copies zero dimensions",
+			node:   &onnxproto.Node{OpType: "Reshape", Inputs: []string{"x", "shape"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2, 3}, 1, 2, 3, 4, 5, 6), ints([]int64{3}, 0, 3, 1)},
+			want:   floats([]int64{2, 3, 1}, 1, 2, 3, 4, 5, 6),
+		},
+		{
+			name:   "Flatten",
+			node:   &onnxproto.Node{OpType: "Flatten", Inputs: []string{"x"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{ints([]int64{1, 2, 2}, 1, 2, 3, 4)},
+			want:   ints([]int64{1, 4}, 1, 2, 3, 4),
+		},
+		{
+			name:   "Transpose default",
+			node:   &onnxproto.Node{OpType: "Transpose", Inputs: []string{"x"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2, 3}, 1, 2, 3, 4, 5, 6)},
+			want:   floats([]int64{3, 2}, 1, 4, 2, 5, 3, 6),
+		},
+		{
+			name:   "Transpose perm",
+			node:   &onnxproto.Node{OpType: "Transpose", Inputs: []string{"x"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{intsAttr("perm", 0, 2, 1)}},
+			inputs: []*backend.Tensor{floats([]int64{1, 2, 3}, 1, 2, 3, 4, 5, 6)},
+			want:   floats([]int64{1, 3, 2}, 1, 4, 2, 5, 3, 6),
+		},
+		{
+			name:   "Conv",
+			node:   &onnxproto.Node{OpType: "Conv", Inputs: []string{"x", "w"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{1, 1, 3, 3}, 1, 2, 3, 4, 5, 6, 7, 8, 9), floats([]int64{1, 1, 2, 2}, 1, 1, 1, 1)},
+			want:   floats([]int64{1, 1, 2, 2}, 12, 16, 24, 28),
+		},
+		{
+			name: "Conv pads strides bias",
+			node: &onnxproto.Node{OpType: "Conv", Inputs: []string{"x", "w", "b"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{
+				intsAttr("pads", 1, 1, 1, 1), intsAttr("strides", 2, 2),
+			}},
+			inputs: []*backend.Tensor{
+				floats([]int64{1, 1, 3, 3}, 1, 2, 3, 4, 5, 6, 7, 8, 9),
+				floats([]int64{1, 1, 2, 2}, 1, 1, 1, 1),
+				floats([]int64{1}, 100),
+			},
+			want: floats([]int64{1, 1, 2, 2}, 101, 105, 111, 128),
+		},
+		{
+			name:   "Conv SAME_UPPER",
+			node:   &onnxproto.Node{OpType: "Conv", Inputs: []string{"x", "w"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{stringAttr("auto_pad", "SAME_UPPER")}},
+			inputs: []*backend.Tensor{floats([]int64{1, 1, 3, 3}, 1, 2, 3, 4, 5, 6, 7, 8, 9), floats([]int64{1, 1, 2, 2}, 1, 1, 1, 1)},
+			want:   floats([]int64{1, 1, 3, 3}, 12, 16, 9, 24, 28, 15, 15, 17, 9),
+		},
+		{
+			name:   "Conv group",
+			node:   &onnxproto.Node{OpType: "Conv", Inputs: []string{"x", "w"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{intAttr("group", 2)}},
+			inputs: []*backend.Tensor{floats([]int64{1, 2, 1, 1}, 2, 3), floats([]int64{2, 1, 1, 1}, 10, 100)},
+			want:   floats([]int64{1, 2, 1, 1}, 20, 300),
+		},
+		{
+			name: "MaxPool",
+			node: &onnxproto.Node{OpType: "MaxPool", Inputs: []string{"x"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{
+				intsAttr("kernel_shape", 2, 2), intsAttr("strides", 2, 2),
+			}},
+			inputs: []*backend.Tensor{floats([]int64{1, 1, 4, 4}, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16)},
+			want:   floats([]int64{1, 1, 2, 2}, 6, 8, 14, 16),
+		},
+		{
+			name: "MaxPool ceil mode",
+			node: &onnxproto.Node{OpType: "MaxPool", Inputs: []string{"x"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{
+				intsAttr("kernel_shape", 2, 2), intsAttr("strides", 2, 2), intAttr("ceil_mode", 1),
+			}},
+			inputs: []*backend.Tensor{floats([]int64{1, 1, 3, 3}, 1, 2, 3, 4, 5, 6, 7, 8, 9)},
+			want:   floats([]int64{1, 1, 2, 2}, 5, 6, 8, 9),
+		},
+		{
+			name:   "Gather",
+			node:   &onnxproto.Node{OpType: "Gather", Inputs: []string{"data", "indices"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{3, 2}, 1, 2, 3, 4, 5, 6), ints([]int64{2}, 2, -3)},
+			want:   floats([]int64{2, 2}, 5, 6, 1, 2),
+		},
+		{
+			name:   "Gather scalar index on axis 1",
+			node:   &onnxproto.Node{OpType: "Gather", Inputs: []string{"data", "indices"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{intAttr("axis", 1)}},
+			inputs: []*backend.Tensor{floats([]int64{3, 2}, 1, 2, 3, 4, 5, 6), ints([]int64{}, 1)},
+			want:   floats([]int64{3}, 2, 4, 6),
+		},
+		{
+			name:   "Concat",
+			node:   &onnxproto.Node{OpType: "Concat", Inputs: []string{"a", "b"}, Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{intAttr("axis", -1)}},
+			inputs: []*backend.Tensor{floats([]int64{2, 2}, 1, 2, 3, 4), floats([]int64{2, 1}, 5, 6)},
+			want:   floats([]int64{2, 3}, 1, 2, 5, 3, 4, 6),
+		},
+		{
+			name: "Constant",
+			node: &onnxproto.Node{OpType: "Constant", Outputs: []string{"y"}, Attributes: []*onnxproto.Attribute{{
+				Name: "value", Type: onnxproto.AttributeTensor,
+				Tensor: &onnxproto.Tensor{Dims: []int64{2}, DataType: onnxproto.DataTypeInt64, Int64Data: []int64{4, 5}},
+			}}},
+			want: ints([]int64{2}, 4, 5),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opset := tt.opset
+			if opset == 0 {
+				opset = 17
+			}
+			outputs, err := runNode(opset, tt.node, tt.inputs...)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if err := CrossCheck([]*backend.Tensor{tt.want}, outputs, 1e-6, 1e-5); err != nil {
+				t.Errorf("output = %v %v: %v", outputs[0].Shape, outputs[0].Data, err)
+			}
+		})
+	}
+}
+
+func TestMatMulPropagatesNaN(t *testing.T) {
+	node := &onnxproto.Node{OpType: "MatMul", Inputs: []string{"a", "b"}, Outputs: []string{"y"}}
+	inf := float32(math.Inf(1))
+	nan := float32(math.NaN())
+	for _, b := range []*backend.Tensor{floats([]int64{2, 1}, inf, 1), floats([]int64{2, 1}, nan, 1)} {
+		outputs, err := runNode(17, node, floats([]int64{1, 2}, 0, 1), b)
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if y := outputs[0].Data.([]float32)[0]; !math.IsNaN(float64(y)) {
+			t.Errorf("[0 1] x %v = %v, want NaN", b.Data, y)
+		}
+	}
+}
+
+func TestOperatorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		node   *onnxproto.Node
+		inputs []*backend.Tensor
+	}{
+		{
+			name:   "incompatible broadcast",
+			node:   &onnxproto.Node{OpType: "Add", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2}, 1, 2), floats([]int64{3}, 1, 2, 3)},
+		},
+		{
+			name:   "mixed types",
+			node:   &onnxproto.Node{OpType: "Mul", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{1}, 1), ints([]int64{1}, 1)},
+		},
+		{
+			name:   "MatMul inner mismatch",
+			node:   &onnxproto.Node{OpType: "MatMul", Inputs: []string{"a", "b"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{1, 2}, 1, 2), floats([]int64{3, 1}, 1, 2, 3)},
+		},
+		{
+			name:   "Gemm bias of higher rank",
+			node:   &onnxproto.Node{OpType: "Gemm", Inputs: []string{"a", "b", "c"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{1, 2}, 1, 2), floats([]int64{2, 3}, 1, 2, 3, 4, 5, 6), floats([]int64{2, 1, 3}, 1, 2, 3, 4, 5, 6)},
+		},
+		{
+			name:   "Gemm bias larger than output",
+			node:   &onnxproto.Node{OpType: "Gemm", Inputs: []string{"a", "b", "c"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{1, 2}, 1, 2), floats([]int64{2, 3}, 1, 2, 3, 4, 5, 6), floats([]int64{2, 3}, 1, 2, 3, 4, 5, 6)},
+		},
+		{
+			name:   "Reshape size mismatch",
+			node:   &onnxproto.Node{OpType: "Reshape", Inputs: []string{"x", "shape"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{3}, 1, 2, 3), ints([]int64{1}, 2)},
+		},
+		{
+			name:   "Gather index out of range",
+			node:   &onnxproto.Node{OpType: "Gather", Inputs: []string{"data", "indices"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{floats([]int64{2}, 1, 2), ints([]int64{1}, 2)},
+		},
+		{
+			name:   "Relu on integers",
+			node:   &onnxproto.Node{OpType: "Relu", Inputs: []string{"x"}, Outputs: []string{"y"}},
+			inputs: []*backend.Tensor{ints([]int64{1}, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runNode(17, tt.node, tt.inputs...); err == nil {
+				t.Error("Run succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestNewSessionRejectsUnsupportedOps(t *testing.T) {
+	model := &onnxproto.Model{Graph: &onnxproto.Graph{
+		Nodes:   []*onnxproto.Node{{OpType: "LayerNormalization", Inputs: []string{"x"}, Outputs: []string{"y"}}},
+		Inputs:  []*onnxproto.ValueInfo{{Name: "x", Kind: onnxproto.KindTensor}},
+		Outputs: []*onnxproto.ValueInfo{{Name: "y", Kind: onnxproto.KindTensor}},
+	}}
+	if _, err := NewSessionFromModel(model, []string{"x"}, []string{"y"}); err == nil {
+		t.Error("NewSessionFromModel succeeded, want error for unsupported operator")
+	}
+}
+
+func TestBackend(t *testing.T) {
+	model := &onnxproto.Model{
+		IRVersion:     8,
+		OpsetImports:  []onnxproto.OperatorSet{{Version: 17}},
+		ProducerName:  "infergo",
+		MetadataProps: map[string]string{"id2label": `{"0": "neg", "1": "pos"}`},
+		Graph: &onnxproto.Graph{
+			Name: "linear",
+			Nodes: []*onnxproto.Node{
+				{OpType: "MatMul", Inputs: []string{"x", "weight"}, Outputs: []string{"xw"}},
+				{OpType: "Add", Inputs: []string{"xw", "bias"}, Outputs: []string{"logits"}},
+			},
+			Initializers: []*onnxproto.Tensor{
+				{Name: "weight", Dims: []int64{3, 2}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{1, 0, 0, 1, 1, 1}},
+				{Name: "bias", Dims: []int64{2}, DataType: onnxproto.DataTypeFloat, FloatData: []float32{0.5, -0.5}},
+			},
+			Inputs: []*onnxproto.ValueInfo{{
+				Name: "x", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat, HasShape: true,
+				Shape: []onnxproto.Dimension{{Param: "batch"}, {Value: 3}},
+			}},
+			Outputs: []*onnxproto.ValueInfo{{
+				Name: "logits", Kind: onnxproto.KindTensor, ElemType: onnxproto.DataTypeFloat, HasShape: true,
+				Shape: []onnxproto.Dimension{{Param: "batch"}, {Value: 2}},
+			}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "linear.onnx")
+	if err := onnxproto.WriteFile(path, model); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var b backend.Backend = NewBackend()
+	session, err := b.NewSession(path, []string{"x"}, []string{"logits"})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+
+	wantInputs := []backend.TensorInfo{{Name: "x", DataType: backend.DataTypeFloat32, Shape: []int64{-1, 3}}}
+	if !reflect.DeepEqual(session.Inputs(), wantInputs) {
+		t.Errorf("Inputs = %+v, want %+v", session.Inputs(), wantInputs)
+	}
+
+	outputs, err := session.Run([]*backend.Tensor{floats([]int64{2, 3}, 1, 2, 3, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []*backend.Tensor{floats([]int64{2, 2}, 4.5, 4.5, 1.5, 0.5)}
+	if err := CrossCheck(want, outputs, 0, 0); err != nil {
+		t.Error(err)
+	}
+
+	md, err := backend.Metadata(session)
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	if md.Producer != "infergo" || md.GraphName != "linear" || md.Labels()[1] != "pos" {
+		t.Errorf("Metadata = %+v", md)
+	}
+
+	if _, err := b.NewSession(path, []string{"weight"}, []string{"logits"}); err == nil {
+		t.Error("NewSession with an initializer as input succeeded, want error")
+	}
+	if _, err := b.NewSession(path, []string{"x"}, []string{"missing"}); err == nil {
+		t.Error("NewSession with an unknown output succeeded, want error")
+	}
+}
+
+func TestCrossCheck(t *testing.T) {
+	want := []*backend.Tensor{floats([]int64{2}, 1, 100)}
+
+	if err := CrossCheck(want, []*backend.Tensor{floats([]int64{2}, 1.0001, 100.01)}, 1e-3, 1e-3); err != nil {
+		t.Errorf("CrossCheck within tolerance: %v", err)
+	}
+	if err := CrossCheck(want, []*backend.Tensor{floats([]int64{2}, 1.1, 100)}, 1e-3, 1e-3); err == nil {
+		t.Error("CrossCheck outside tolerance succeeded, want error")
+	}
+	if err := CrossCheck(want, []*backend.Tensor{floats([]int64{1, 2}, 1, 100)}, 1e-3, 1e-3); err == nil {
+		t.Error("CrossCheck with mismatched shape succeeded, want error")
+	}
+	if err := CrossCheck(want, []*backend.Tensor{ints([]int64{2}, 1, 100)}, 1e-3, 1e-3); err == nil {
+		t.Error("CrossCheck with mismatched type succeeded, want error")
+	}
+}
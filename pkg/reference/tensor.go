@@ -0,0 +1,220 @@
+package reference
+
+import (
+	"fmt"
+
+	"github.com/joeychilson/infergo/pkg/backend"
+	"github.com/joeychilson/infergo/pkg/onnxproto"
+)
+
+// tensor is the executor's internal value. Floating point tensors are computed in
+// float32 and every integer or bool tensor in int64.
+type tensor struct {
+	shape   []int64
+	integer bool
+	floats  []float32
+	ints    []int64
+}
+
+func newFloats(shape []int64, data []float32) *tensor {
+	return &tensor{shape: shape, floats: data}
+}
+
+func newInts(shape []int64, data []int64) *tensor {
+	return &tensor{shape: shape, integer: true, ints: data}
+}
+
+func (t *tensor) size() int {
+	return int(backend.NumElements(t.shape))
+}
+
+func (t *tensor) kind() string {
+	if t.integer {
+		return "integer"
+	}
+	return "float"
+}
+
+// fromBackend converts a backend tensor into the executor representation
+func fromBackend(t *backend.Tensor) (*tensor, error) {
+	if t == nil {
+		return nil, fmt.Errorf("nil tensor")
+	}
+	shape := append([]int64(nil), t.Shape...)
+
+	var out *tensor
+	switch data := t.Data.(type) {
+	case []float32:
+		out = newFloats(shape, append([]float32(nil), data...))
+	case []float64:
+		out = newFloats(shape, convert[float32](data))
+	case []int64:
+		out = newInts(shape, append([]int64(nil), data...))
+	case []int32:
+		out = newInts(shape, convert[int64](data))
+	case []int8:
+		out = newInts(shape, convert[int64](data))
+	case []uint8:
+		out = newInts(shape, convert[int64](data))
+	case []bool:
+		ints := make([]int64, len(data))
+		for i, v := range data {
+			if v {
+				ints[i] = 1
+			}
+		}
+		out = newInts(shape, ints)
+	default:
+		return nil, fmt.Errorf("unsupported tensor data %T", t.Data)
+	}
+
+	if n := max(len(out.floats), len(out.ints)); n != out.size() {
+		return nil, fmt.Errorf("tensor has %d elements, shape %v requires %d", n, shape, out.size())
+	}
+	return out, nil
+}
+
+// toBackend converts t into a backend tensor of the given ONNX element type
+func toBackend(t *tensor, elemType onnxproto.DataType) (*backend.Tensor, error) {
+	shape := append([]int64(nil), t.shape...)
+	if !t.integer {
+		switch elemType {
+		case onnxproto.DataTypeFloat, onnxproto.DataTypeUndefined:
+			return backend.NewTensor(shape, t.floats), nil
+		case onnxproto.DataTypeDouble:
+			return backend.NewTensor(shape, convert[float64](t.floats)), nil
+		}
+		return nil, fmt.Errorf("cannot return float data as %s", elemType)
+	}
+
+	switch elemType {
+	case onnxproto.DataTypeInt64, onnxproto.DataTypeUndefined:
+		return backend.NewTensor(shape, t.ints), nil
+	case onnxproto.DataTypeInt32:
+		return backend.NewTensor(shape, convert[int32](t.ints)), nil
+	case onnxproto.DataTypeInt8:
+		return backend.NewTensor(shape, convert[int8](t.ints)), nil
+	case onnxproto.DataTypeUint8:
+		return backend.NewTensor(shape, convert[uint8](t.ints)), nil
+	case onnxproto.DataTypeBool:
+		bools := make([]bool, len(t.ints))
+		for i, v := range t.ints {
+			bools[i] = v != 0
+		}
+		return backend.NewTensor(shape, bools), nil
+	}
+	return nil, fmt.Errorf("cannot return integer data as %s", elemType)
+}
+
+// fromProto decodes an initializer or constant tensor
+func fromProto(t *onnxproto.Tensor) (*tensor, error) {
+	shape := append([]int64{}, t.Dims...)
+	switch t.DataType {
+	case onnxproto.DataTypeFloat, onnxproto.DataTypeDouble:
+		data, err := t.Float32s()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) != t.NumElements() {
+			return nil, fmt.Errorf("tensor %q has %d elements, dims %v require %d", t.Name, len(data), t.Dims, t.NumElements())
+		}
+		return newFloats(shape, append([]float32(nil), data...)), nil
+	}
+
+	data, err := t.Int64s()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != t.NumElements() {
+		return nil, fmt.Errorf("tensor %q has %d elements, dims %v require %d", t.Name, len(data), t.Dims, t.NumElements())
+	}
+	return newInts(shape, append([]int64(nil), data...)), nil
+}
+
+func convert[To, From float32 | float64 | int64 | int32 | int8 | uint8](values []From) []To {
+	out := make([]To, len(values))
+	for i, v := range values {
+		out[i] = To(v)
+	}
+	return out
+}
+
+// strides returns the row-major strides of shape
+func strides(shape []int64) []int64 {
+	s := make([]int64, len(shape))
+	stride := int64(1)
+	for i := len(shape) - 1; i >= 0; i-- {
+		s[i] = stride
+		stride *= shape[i]
+	}
+	return s
+}
+
+// broadcastShape returns the multidirectional broadcast of the given shapes
+func broadcastShape(shapes ...[]int64) ([]int64, error) {
+	rank := 0
+	for _, shape := range shapes {
+		rank = max(rank, len(shape))
+	}
+
+	out := make([]int64, rank)
+	for i := range out {
+		out[i] = 1
+	}
+	for _, shape := range shapes {
+		offset := rank - len(shape)
+		for i, dim := range shape {
+			switch {
+			case dim == out[offset+i] || dim == 1:
+			case out[offset+i] == 1:
+				out[offset+i] = dim
+			default:
+				return nil, fmt.Errorf("shapes %v are not broadcastable", shapes)
+			}
+		}
+	}
+	return out, nil
+}
+
+// broadcastIndices maps every element of out to the flat index of the
+// corresponding element in a tensor of the given shape
+func broadcastIndices(shape, out []int64) []int {
+	rank := len(out)
+	s := make([]int64, rank)
+	inner := strides(shape)
+	offset := rank - len(shape)
+	for i, dim := range shape {
+		if dim != 1 {
+			s[offset+i] = inner[i]
+		}
+	}
+
+	n := int(backend.NumElements(out))
+	indices := make([]int, n)
+	counter := make([]int64, rank)
+	index := int64(0)
+	for i := 0; i < n; i++ {
+		indices[i] = int(index)
+		for d := rank - 1; d >= 0; d-- {
+			counter[d]++
+			index += s[d]
+			if counter[d] < out[d] {
+				break
+			}
+			index -= s[d] * counter[d]
+			counter[d] = 0
+		}
+	}
+	return indices
+}
+
+// normalizeAxis resolves a possibly negative axis against rank
+func normalizeAxis(axis int64, rank int) (int, error) {
+	if axis < 0 {
+		axis += int64(rank)
+	}
+	if axis < 0 || axis >= int64(rank) {
+		return 0, fmt.Errorf("axis %d is out of range for rank %d", axis, rank)
+	}
+	return int(axis), nil
+}
@@ -0,0 +1,167 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// addedToken is a token matched in the input text before normal tokenization
+type addedToken struct {
+	id      int64
+	content string
+	// singleWord only matches the token when it is not part of a larger word
+	singleWord bool
+	// lstrip and rstrip make the token consume whitespace on its left or right
+	lstrip, rstrip bool
+	// normalized matches the token against normalized instead of raw text
+	normalized bool
+	special    bool
+}
+
+// addedVocabulary holds the added tokens of a tokenizer
+type addedVocabulary struct {
+	tokens []addedToken
+	byID   map[int64]addedToken
+	// raw and normalized match the tokens before and after normalization
+	raw, normalized *addedMatcher
+}
+
+func newAddedVocabulary(tokens []addedToken, n normalizer) *addedVocabulary {
+	a := &addedVocabulary{byID: make(map[int64]addedToken, len(tokens))}
+	for _, t := range tokens {
+		a.add(t)
+	}
+
+	var raw, normalized []addedToken
+	for _, t := range a.tokens {
+		if t.content == "" {
+			continue
+		}
+		if t.normalized && n != nil {
+			// match the normalized form of the token against normalized text
+			content := newNormalized(t.content, 0)
+			n.normalize(content)
+			t.content = content.text
+			normalized = append(normalized, t)
+		} else {
+			raw = append(raw, t)
+		}
+	}
+	a.raw, a.normalized = newAddedMatcher(raw), newAddedMatcher(normalized)
+	return a
+}
+
+// add registers t, replacing any added token with the same content
+func (a *addedVocabulary) add(t addedToken) {
+	for i, existing := range a.tokens {
+		if existing.content == t.content {
+			a.tokens = append(a.tokens[:i], a.tokens[i+1:]...)
+			delete(a.byID, existing.id)
+			break
+		}
+	}
+	a.tokens = append(a.tokens, t)
+	a.byID[t.id] = t
+}
+
+// lookup returns the id of the added token with the given content
+func (a *addedVocabulary) lookup(content string) (int64, bool) {
+	for _, t := range a.tokens {
+		if t.content == content {
+			return t.id, true
+		}
+	}
+	return 0, false
+}
+
+// segment is either a piece of text or a matched added token
+type segment struct {
+	text  *normalized
+	added *addedToken
+	span  span
+}
+
+// split finds the leftmost longest occurrences of the tokens of m in n and
+// returns the text between them and the matched tokens in order
+func (a *addedVocabulary) split(n *normalized, m *addedMatcher) []segment {
+	if len(m.tokens) == 0 {
+		return []segment{{text: n}}
+	}
+
+	var segments []segment
+	prev := 0
+	for i := 0; i < len(n.text); {
+		t, ok := m.match(n.text, i)
+		if !ok {
+			_, size := utf8.DecodeRuneInString(n.text[i:])
+			i += size
+			continue
+		}
+
+		start, end := i, i+len(t.content)
+		if t.lstrip {
+			start = prev + len(strings.TrimRightFunc(n.text[prev:start], unicode.IsSpace))
+		}
+		if t.rstrip {
+			end = len(n.text) - len(strings.TrimLeftFunc(n.text[end:], unicode.IsSpace))
+		}
+		if start > prev {
+			segments = append(segments, segment{text: n.slice(prev, start)})
+		}
+		segments = append(segments, segment{added: t, span: n.original(i, i+len(t.content))})
+		prev, i = end, end
+	}
+	if prev < len(n.text) {
+		segments = append(segments, segment{text: n.slice(prev, len(n.text))})
+	}
+	return segments
+}
+
+// addedMatcher finds added tokens in text with a trie of their contents
+type addedMatcher struct {
+	tokens []addedToken
+	// trie maps the content of every token to its index in tokens
+	trie *trie
+}
+
+func newAddedMatcher(tokens []addedToken) *addedMatcher {
+	keys := make(map[string]int64, len(tokens))
+	for i, t := range tokens {
+		// the first token with a content wins, as a normalized content may repeat
+		if _, ok := keys[t.content]; !ok {
+			keys[t.content] = int64(i)
+		}
+	}
+	return &addedMatcher{tokens: tokens, trie: newTrie(keys, "")}
+}
+
+// match returns the longest token starting at s[i]. A single word token that is
+// part of a larger word gives way to a shorter token.
+func (m *addedMatcher) match(s string, i int) (*addedToken, bool) {
+	var found *addedToken
+	node := int32(0)
+	for end := i; end < len(s); end++ {
+		if node = m.trie.child(node, s[end]); node < 0 {
+			break
+		}
+		index := m.trie.nodes[node].id
+		if index < 0 {
+			continue
+		}
+		t := &m.tokens[index]
+		if t.singleWord {
+			before, _ := utf8.DecodeLastRuneInString(s[:i])
+			after, _ := utf8.DecodeRuneInString(s[end+1:])
+			if (i > 0 && isWordChar(before)) || (end+1 < len(s) && isWordChar(after)) {
+				continue
+			}
+		}
+		found = t
+	}
+	return found, found != nil
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
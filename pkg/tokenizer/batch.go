@@ -0,0 +1,143 @@
+package tokenizer
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// PaddingStrategy selects the length that encodings are padded to
+type PaddingStrategy int
+
+const (
+	// PadToMaxLength pads to the maximum length given to Encode, the default of
+	// Encode and EncodePair
+	PadToMaxLength PaddingStrategy = iota
+	// PadLongest pads to the longest encoding, the default of EncodeBatch
+	PadLongest
+	// DoNotPad leaves encodings at their own length. Batches are still padded to
+	// their longest encoding.
+	DoNotPad
+)
+
+// WithPadding sets the padding strategy
+func WithPadding(strategy PaddingStrategy) EncodeOption {
+	return func(o *encodeOptions) {
+		o.padding = strategy
+	}
+}
+
+// WithPadToMultipleOf rounds the padded length up to a multiple of n, which
+// lets accelerators reuse kernels across batches
+func WithPadToMultipleOf(n int) EncodeOption {
+	return func(o *encodeOptions) {
+		o.multiple = n
+	}
+}
+
+// WithPaddingSide sets the side padding tokens are added to, SideRight by
+// default. Decoder models are padded on the left.
+func WithPaddingSide(side Side) EncodeOption {
+	return func(o *encodeOptions) {
+		o.padSide = side
+	}
+}
+
+// BatchOutput holds a batch of encodings padded to a common length, with
+// row-major [BatchSize, SequenceLength] matrices ready for batched inference
+type BatchOutput struct {
+	InputIds      []int64
+	AttentionMask []int64
+	TokenTypeIds  []int64
+	// BatchSize is the number of rows, one per text and overflowing window
+	BatchSize int
+	// SequenceLength is the number of tokens of every row
+	SequenceLength int
+	// Samples holds the index of the text of every row
+	Samples []int
+	// Encodings holds the output of every text, including its overflowing windows
+	Encodings []*TokenizerOutput
+}
+
+// padLength returns the length that windows are padded to
+func (o *encodeOptions) padLength(maxLength int, windows []*encoding) int {
+	longest := 0
+	for _, w := range windows {
+		longest = max(longest, w.len())
+	}
+
+	var n int
+	switch o.padding {
+	case PadToMaxLength:
+		n = max(maxLength, longest)
+	case PadLongest:
+		n = longest
+	default:
+		return 0
+	}
+	if o.multiple > 0 && n%o.multiple != 0 {
+		n += o.multiple - n%o.multiple
+	}
+	return n
+}
+
+// encodeBatch tokenizes texts concurrently and pads them to a common length
+func (p *pipeline) encodeBatch(texts []string, maxLength int, opts ...EncodeOption) (*BatchOutput, error) {
+	o := p.defaults
+	o.padding = PadLongest
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.padding == DoNotPad {
+		o.padding = PadLongest
+	}
+
+	windows := make([][]*encoding, len(texts))
+	errs := make([]error, len(texts))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(texts)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				windows[i], errs[i] = p.encodeWindows(texts[i], maxLength, &o)
+			}
+		}()
+	}
+	for i := range texts {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var all []*encoding
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode text %d: %w", i, err)
+		}
+		all = append(all, windows[i]...)
+	}
+	length := o.padLength(maxLength, all)
+
+	out := &BatchOutput{
+		InputIds:       make([]int64, 0, len(all)*length),
+		AttentionMask:  make([]int64, 0, len(all)*length),
+		TokenTypeIds:   make([]int64, 0, len(all)*length),
+		BatchSize:      len(all),
+		SequenceLength: length,
+		Samples:        make([]int, 0, len(all)),
+		Encodings:      make([]*TokenizerOutput, len(texts)),
+	}
+	for i, w := range windows {
+		enc := p.finish(w, length, &o, texts[i])
+		out.Encodings[i] = enc
+		for _, row := range append([]*TokenizerOutput{enc}, enc.Overflowing...) {
+			out.InputIds = append(out.InputIds, row.InputIds...)
+			out.AttentionMask = append(out.AttentionMask, row.AttentionMask...)
+			out.TokenTypeIds = append(out.TokenTypeIds, row.TokenTypeIds...)
+			out.Samples = append(out.Samples, i)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeBatch(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	texts := []string{"one two three", "one", ""}
+	tests := []struct {
+		name        string
+		texts       []string
+		maxLength   int
+		opts        []EncodeOption
+		wantLength  int
+		wantMask    []int64
+		wantSamples []int
+	}{
+		{
+			name:        "longest",
+			texts:       texts,
+			maxLength:   512,
+			wantLength:  5,
+			wantMask:    []int64{1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 1, 0, 0, 0},
+			wantSamples: []int{0, 1, 2},
+		},
+		{
+			name:        "multiple of",
+			texts:       texts[1:],
+			opts:        []EncodeOption{WithPadToMultipleOf(4)},
+			wantLength:  4,
+			wantMask:    []int64{1, 1, 1, 0, 1, 1, 0, 0},
+			wantSamples: []int{0, 1},
+		},
+		{
+			name:        "left padding",
+			texts:       texts[:2],
+			opts:        []EncodeOption{WithPaddingSide(SideLeft)},
+			wantLength:  5,
+			wantMask:    []int64{1, 1, 1, 1, 1, 0, 0, 1, 1, 1},
+			wantSamples: []int{0, 1},
+		},
+		{
+			name:        "max length",
+			texts:       texts[1:2],
+			maxLength:   6,
+			opts:        []EncodeOption{WithPadding(PadToMaxLength)},
+			wantLength:  6,
+			wantMask:    []int64{1, 1, 1, 0, 0, 0},
+			wantSamples: []int{0},
+		},
+		{
+			name:        "overflowing rows",
+			texts:       texts[:2],
+			maxLength:   4,
+			opts:        []EncodeOption{WithOverflow(1)},
+			wantLength:  4,
+			wantMask:    []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0},
+			wantSamples: []int{0, 0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tok.EncodeBatch(tt.texts, tt.maxLength, tt.opts...)
+			if err != nil {
+				t.Fatalf("EncodeBatch() error = %v", err)
+			}
+			if got.SequenceLength != tt.wantLength {
+				t.Errorf("SequenceLength = %d, want %d", got.SequenceLength, tt.wantLength)
+			}
+			if got.BatchSize != len(tt.wantSamples) {
+				t.Errorf("BatchSize = %d, want %d", got.BatchSize, len(tt.wantSamples))
+			}
+			if !reflect.DeepEqual(got.AttentionMask, tt.wantMask) {
+				t.Errorf("AttentionMask = %v, want %v", got.AttentionMask, tt.wantMask)
+			}
+			if !reflect.DeepEqual(got.Samples, tt.wantSamples) {
+				t.Errorf("Samples = %v, want %v", got.Samples, tt.wantSamples)
+			}
+			if n := got.BatchSize * got.SequenceLength; len(got.InputIds) != n || len(got.TokenTypeIds) != n {
+				t.Errorf("matrix sizes = %d, %d, want %d", len(got.InputIds), len(got.TokenTypeIds), n)
+			}
+
+			// every text encodes as it does alone
+			row := 0
+			for i, text := range tt.texts {
+				want, err := tok.Encode(text, tt.maxLength, append(tt.opts, WithPadding(DoNotPad))...)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				for _, window := range append([]*TokenizerOutput{want}, want.Overflowing...) {
+					ids := got.InputIds[row*got.SequenceLength : (row+1)*got.SequenceLength]
+					mask := got.AttentionMask[row*got.SequenceLength : (row+1)*got.SequenceLength]
+					var unpadded []int64
+					for j, m := range mask {
+						if m == 1 {
+							unpadded = append(unpadded, ids[j])
+						}
+					}
+					if !reflect.DeepEqual(unpadded, window.InputIds) {
+						t.Errorf("row %d of text %d = %v, want %v", row, i, unpadded, window.InputIds)
+					}
+					row++
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeLeftPadding(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	got, err := tok.Encode("one", 5, WithPaddingSide(SideLeft))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := []string{"[PAD]", "[PAD]", "[CLS]", "one", "[SEP]"}
+	if !reflect.DeepEqual(got.Tokens, want) {
+		t.Errorf("Tokens = %q, want %q", got.Tokens, want)
+	}
+	if wantMask := []int64{0, 0, 1, 1, 1}; !reflect.DeepEqual(got.AttentionMask, wantMask) {
+		t.Errorf("AttentionMask = %v, want %v", got.AttentionMask, wantMask)
+	}
+	if wantWords := []int{-1, -1, -1, 0, -1}; !reflect.DeepEqual(got.WordIds, wantWords) {
+		t.Errorf("WordIds = %v, want %v", got.WordIds, wantWords)
+	}
+}
@@ -4,131 +4,238 @@ import (
 	"bufio"
 	"embed"
 	"fmt"
-	"regexp"
-	"strings"
+	"io"
+	"io/fs"
+	"os"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 //go:embed vocabs/bert.txt
 var bertVocabFS embed.FS
 
+// bertVocabPath is the uncased vocabulary embedded in the package
+const bertVocabPath = "vocabs/bert.txt"
+
 type BERTTokenizer struct {
-	vocab         map[string]int
 	labels        map[int]string
 	specialTokens SpecialTokens
+	pipeline      *pipeline
 }
 
-func NewBERTTokenizer() (*BERTTokenizer, error) {
-	vocab, err := loadVocabFromEmbed()
-	if err != nil {
-		return nil, err
+// BERTOption configures a BERT tokenizer
+type BERTOption func(*bertOptions)
+
+type bertOptions struct {
+	lowercase     bool
+	specialTokens SpecialTokens
+	addedTokens   []AddedToken
+}
+
+// WithLowerCase sets whether text is lower-cased and stripped of accents before
+// tokenization, which uncased models expect. It is true by default and must be
+// false for cased checkpoints such as bert-base-cased.
+func WithLowerCase(lowercase bool) BERTOption {
+	return func(o *bertOptions) {
+		o.lowercase = lowercase
 	}
+}
 
-	labels := make(map[int]string, len(vocab))
-	for token, id := range vocab {
-		labels[id] = token
+// WithBERTSpecialTokens sets the special tokens of the vocabulary. The UNK, CLS
+// and SEP tokens are required, PAD and MASK are ignored when they are not in the
+// vocabulary.
+func WithBERTSpecialTokens(tokens SpecialTokens) BERTOption {
+	return func(o *bertOptions) {
+		o.specialTokens = tokens
 	}
+}
 
-	return &BERTTokenizer{
-		vocab:         vocab,
-		labels:        labels,
-		specialTokens: DefaultSpecialTokens(),
-	}, nil
+// WithAddedTokens registers tokens that are matched in the text before it is
+// split into words, so that they are never broken into WordPiece pieces. Tokens
+// that are not in the vocabulary get new ids after it.
+func WithAddedTokens(tokens ...AddedToken) BERTOption {
+	return func(o *bertOptions) {
+		o.addedTokens = append(o.addedTokens, tokens...)
+	}
 }
 
-func (t *BERTTokenizer) Encode(text string, maxLength int) (*TokenizerOutput, error) {
-	pattern := regexp.MustCompile(`\[[^\[\]]+\]|\w+|[^\w\s]+`)
-	tokens := pattern.FindAllString(text, -1)
-	wordpieceTokens := []string{}
+// AddedToken is a token matched in the text before normal tokenization
+type AddedToken struct {
+	Content string
+	// Special tokens are dropped by Decode when skipping special tokens
+	Special bool
+	// SingleWord only matches the token when it is not part of a larger word
+	SingleWord bool
+	// LStrip and RStrip make the token consume the whitespace on its left or right
+	LStrip, RStrip bool
+	// Normalized matches the token against the normalized text, for example
+	// case-insensitively when the tokenizer lower-cases text
+	Normalized bool
+}
 
-	for _, token := range tokens {
-		if strings.HasPrefix(token, "[") && strings.HasSuffix(token, "]") {
-			// Handle special tokens case-insensitively
-			if canonical, isSpecial := t.specialTokens.IsSpecialToken(token); isSpecial {
-				wordpieceTokens = append(wordpieceTokens, canonical)
-				continue
-			}
-		}
+// NewBERTTokenizer creates a tokenizer with the embedded bert-base-uncased vocabulary
+func NewBERTTokenizer(opts ...BERTOption) (*BERTTokenizer, error) {
+	return LoadBERTTokenizerFS(bertVocabFS, bertVocabPath, opts...)
+}
 
-		token = strings.ToLower(token)
-		if _, ok := t.vocab[token]; ok {
-			wordpieceTokens = append(wordpieceTokens, token)
-		} else {
-			subTokens := WordPiece(t.vocab, t.specialTokens, token)
-			wordpieceTokens = append(wordpieceTokens, subTokens...)
-		}
+// LoadBERTTokenizer creates a tokenizer from a vocab.txt file with one token per line
+func LoadBERTTokenizer(vocabPath string, opts ...BERTOption) (*BERTTokenizer, error) {
+	f, err := os.Open(vocabPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocab file: %w", err)
 	}
+	defer f.Close()
+	return NewBERTTokenizerFromReader(f, opts...)
+}
 
-	inputIds := []int64{int64(t.vocab[t.specialTokens.CLS])}
-	finalTokens := []string{t.specialTokens.CLS}
+// LoadBERTTokenizerFS creates a tokenizer from a vocab.txt file in fsys
+func LoadBERTTokenizerFS(fsys fs.FS, name string, opts ...BERTOption) (*BERTTokenizer, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocab file: %w", err)
+	}
+	defer f.Close()
+	return NewBERTTokenizerFromReader(f, opts...)
+}
 
-	for _, token := range wordpieceTokens {
-		if id, ok := t.vocab[token]; ok {
-			inputIds = append(inputIds, int64(id))
-			finalTokens = append(finalTokens, token)
-		} else {
-			inputIds = append(inputIds, int64(t.vocab[t.specialTokens.UNK]))
-			finalTokens = append(finalTokens, t.specialTokens.UNK)
-		}
+// NewBERTTokenizerFromReader creates a tokenizer from the contents of a vocab.txt file
+func NewBERTTokenizerFromReader(r io.Reader, opts ...BERTOption) (*BERTTokenizer, error) {
+	options := &bertOptions{lowercase: true, specialTokens: DefaultSpecialTokens()}
+	for _, opt := range opts {
+		opt(options)
 	}
 
-	inputIds = append(inputIds, int64(t.vocab[t.specialTokens.SEP]))
-	finalTokens = append(finalTokens, t.specialTokens.SEP)
+	ids, err := readVocab(r)
+	if err != nil {
+		return nil, err
+	}
+
+	specialTokens := options.specialTokens
+	for _, token := range []string{specialTokens.UNK, specialTokens.CLS, specialTokens.SEP} {
+		if _, ok := ids[token]; !ok {
+			return nil, fmt.Errorf("special token %q is not in the vocabulary", token)
+		}
+	}
+	for _, token := range []*string{&specialTokens.PAD, &specialTokens.MASK} {
+		if _, ok := ids[*token]; !ok {
+			*token = ""
+		}
+	}
 
-	attentionMask := make([]int64, len(inputIds))
-	for i := range attentionMask {
-		attentionMask[i] = 1
+	model, err := newWordPieceModel(newVocabulary(ids), specialTokens.UNK, "##", 0)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(inputIds) > maxLength {
-		inputIds = inputIds[:maxLength]
-		finalTokens = finalTokens[:maxLength]
-		attentionMask = attentionMask[:maxLength]
-	} else {
-		for len(inputIds) < maxLength {
-			inputIds = append(inputIds, int64(t.vocab[t.specialTokens.PAD]))
-			attentionMask = append(attentionMask, 0)
-			finalTokens = append(finalTokens, t.specialTokens.PAD)
+	special := func(token string) specialToken {
+		return specialToken{value: token, id: ids[token]}
+	}
+	var added []addedToken
+	for _, token := range []string{specialTokens.PAD, specialTokens.UNK, specialTokens.CLS, specialTokens.SEP, specialTokens.MASK} {
+		if token != "" {
+			// special tokens are matched after lower-casing so that [mask] works like [MASK]
+			added = append(added, addedToken{id: ids[token], content: token, normalized: true, special: true})
 		}
 	}
+	next := int64(len(ids))
+	for _, t := range options.addedTokens {
+		id, ok := ids[t.Content]
+		if !ok {
+			id = next
+			next++
+		}
+		added = append(added, addedToken{
+			id:         id,
+			content:    t.Content,
+			singleWord: t.SingleWord,
+			lstrip:     t.LStrip,
+			rstrip:     t.RStrip,
+			normalized: t.Normalized,
+			special:    t.Special,
+		})
+	}
+
+	p := &pipeline{
+		// the BasicTokenizer of the reference implementation composes text first
+		normalizer: normalizerSequence{
+			unicodeNormalizer{form: norm.NFC},
+			bertNormalizer{cleanText: true, handleChineseChars: true, stripAccents: options.lowercase, lowercase: options.lowercase},
+		},
+		preTokenizer: bertPreTokenizer{},
+		model:        model,
+		processor:    bertProcessing{cls: special(specialTokens.CLS), sep: special(specialTokens.SEP)},
+		decoder:      decoderSequence{wordPieceDecoder{prefix: "##"}, cleanupDecoder{}},
+		padToken:     special(specialTokens.PAD),
+	}
+	p.added = newAddedVocabulary(added, p.normalizer)
+
+	labels := make(map[int]string, len(ids)+len(options.addedTokens))
+	for token, id := range ids {
+		labels[int(id)] = token
+	}
+	for _, t := range p.added.tokens {
+		labels[int(t.id)] = t.content
+	}
 
-	return &TokenizerOutput{
-		InputIds:      inputIds,
-		AttentionMask: attentionMask,
-		Tokens:        finalTokens,
+	return &BERTTokenizer{
+		labels:        labels,
+		specialTokens: specialTokens,
+		pipeline:      p,
 	}, nil
 }
 
-// MaskLogits represents the logits for masked tokens
-type MaskLogits struct {
-	Position int       // Position of the mask token
-	Logits   []float32 // Logits for the mask token
+// Encode tokenizes text as [CLS] text [SEP]. When maxLength is positive the text
+// is truncated so that the output, including special tokens, is padded to exactly
+// maxLength tokens. opts configure truncation and overflow.
+func (t *BERTTokenizer) Encode(text string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encode(text, maxLength, opts...)
+}
+
+// EncodePair tokenizes a pair of texts as [CLS] a [SEP] b [SEP] with token type
+// ids 0 for a and 1 for b. When maxLength is positive the longer text is
+// truncated first, unless opts select another strategy, and the output is padded
+// to exactly maxLength tokens.
+func (t *BERTTokenizer) EncodePair(a, b string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encodePair(a, b, maxLength, opts...)
+}
+
+// EncodeBatch tokenizes texts concurrently like Encode and pads them to the
+// longest encoding of the batch, unless opts select another padding strategy
+func (t *BERTTokenizer) EncodeBatch(texts []string, maxLength int, opts ...EncodeOption) (*BatchOutput, error) {
+	return t.pipeline.encodeBatch(texts, maxLength, opts...)
+}
+
+// Decode converts ids back to text, joining ## continuation pieces to their word
+// and removing the spaces before punctuation like the reference BERT tokenizer.
+// Special tokens such as [CLS] and [PAD] are dropped when skipSpecial is set.
+func (t *BERTTokenizer) Decode(ids []int64, skipSpecial bool) (string, error) {
+	return t.pipeline.decode(ids, skipSpecial)
 }
 
 // MaskLogits extracts logits for all mask tokens in the sequence
 func (t *BERTTokenizer) MaskLogits(tokens []string, logits []float32) ([]MaskLogits, error) {
-	if len(logits)%len(tokens) != 0 {
-		return nil, fmt.Errorf("logits length (%d) is not a multiple of tokens length (%d)", len(logits), len(tokens))
-	}
+	return FindMaskLogits(t, tokens, logits)
+}
 
-	vocabSize := len(t.vocab)
+// TokenToID returns the id of a token
+func (t *BERTTokenizer) TokenToID(token string) (int64, bool) {
+	return t.pipeline.tokenToID(token)
+}
 
-	var maskLogits []MaskLogits
-	for pos, token := range tokens {
-		if token == t.specialTokens.MASK {
-			start := pos * vocabSize
-			end := start + vocabSize
-			if end > len(logits) {
-				return nil, fmt.Errorf("logits array too short for mask at position %d", pos)
-			}
+// IDToToken returns the token of an id
+func (t *BERTTokenizer) IDToToken(id int64) (string, bool) {
+	return t.pipeline.idToToken(id)
+}
 
-			maskLogits = append(maskLogits, MaskLogits{
-				Position: pos,
-				Logits:   logits[start:end],
-			})
-		}
-	}
+// VocabSize returns the number of tokens in the vocabulary, including added tokens
+func (t *BERTTokenizer) VocabSize() int {
+	return t.pipeline.vocabSize()
+}
 
-	return maskLogits, nil
+// SpecialTokens returns the special tokens of the tokenizer. PAD and MASK are
+// empty when they are not in the vocabulary.
+func (t *BERTTokenizer) SpecialTokens() SpecialTokens {
+	return t.specialTokens
 }
 
 // Labels returns the labels for the vocabulary
@@ -136,28 +243,23 @@ func (t *BERTTokenizer) Labels() map[int]string {
 	return t.labels
 }
 
-func loadVocabFromEmbed() (map[string]int, error) {
-	vocab := make(map[string]int)
-
-	file, err := bertVocabFS.Open("vocabs/bert.txt")
-	if err != nil {
-		return nil, fmt.Errorf("failed to open embedded vocab file: %w", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	id := 0
+// readVocab reads a vocab.txt file, where the id of a token is its line index
+// as in transformers. Only the line ending is removed, so blank lines keep their
+// ids and tokens keep their spaces.
+func readVocab(r io.Reader) (map[string]int64, error) {
+	vocab := make(map[string]int64)
+	scanner := bufio.NewScanner(r)
+	var id int64
 	for scanner.Scan() {
-		token := strings.TrimSpace(scanner.Text())
-		if token != "" {
-			vocab[token] = id
-			id++
-		}
+		// the scanner removes the trailing \r\n or \n
+		vocab[scanner.Text()] = id
+		id++
 	}
-
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error reading vocab file: %w", err)
 	}
-
+	if len(vocab) == 0 {
+		return nil, fmt.Errorf("vocab file is empty")
+	}
 	return vocab, nil
 }
@@ -0,0 +1,293 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBERTTokenizer(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		a, b          string
+		pair          bool
+		maxLength     int
+		wantTokens    []string
+		wantTypeIds   []int64
+		wantAttention []int64
+	}{
+		{
+			name:          "single",
+			a:             "The [mask] is big.",
+			wantTokens:    []string{"[CLS]", "the", "[MASK]", "is", "big", ".", "[SEP]"},
+			wantTypeIds:   []int64{0, 0, 0, 0, 0, 0, 0},
+			wantAttention: []int64{1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:          "truncation keeps special tokens",
+			a:             "one two three four",
+			maxLength:     4,
+			wantTokens:    []string{"[CLS]", "one", "two", "[SEP]"},
+			wantTypeIds:   []int64{0, 0, 0, 0},
+			wantAttention: []int64{1, 1, 1, 1},
+		},
+		{
+			name:          "pair",
+			a:             "Who are you?",
+			b:             "I am here",
+			pair:          true,
+			wantTokens:    []string{"[CLS]", "who", "are", "you", "?", "[SEP]", "i", "am", "here", "[SEP]"},
+			wantTypeIds:   []int64{0, 0, 0, 0, 0, 0, 1, 1, 1, 1},
+			wantAttention: []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:          "pair truncates the longer text",
+			a:             "one two three four",
+			b:             "five six",
+			pair:          true,
+			maxLength:     8,
+			wantTokens:    []string{"[CLS]", "one", "two", "three", "[SEP]", "five", "six", "[SEP]"},
+			wantTypeIds:   []int64{0, 0, 0, 0, 0, 1, 1, 1},
+			wantAttention: []int64{1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:          "pair padding",
+			a:             "one",
+			b:             "two",
+			pair:          true,
+			maxLength:     7,
+			wantTokens:    []string{"[CLS]", "one", "[SEP]", "two", "[SEP]", "[PAD]", "[PAD]"},
+			wantTypeIds:   []int64{0, 0, 0, 1, 1, 0, 0},
+			wantAttention: []int64{1, 1, 1, 1, 1, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *TokenizerOutput
+			var err error
+			if tt.pair {
+				got, err = tok.EncodePair(tt.a, tt.b, tt.maxLength)
+			} else {
+				got, err = tok.Encode(tt.a, tt.maxLength)
+			}
+			if err != nil {
+				t.Fatalf("encode error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+			if !reflect.DeepEqual(got.TokenTypeIds, tt.wantTypeIds) {
+				t.Errorf("TokenTypeIds = %v, want %v", got.TokenTypeIds, tt.wantTypeIds)
+			}
+			if !reflect.DeepEqual(got.AttentionMask, tt.wantAttention) {
+				t.Errorf("AttentionMask = %v, want %v", got.AttentionMask, tt.wantAttention)
+			}
+			for i, token := range got.Tokens {
+				if want, _ := tok.TokenToID(token); got.InputIds[i] != want {
+					t.Errorf("InputIds[%d] = %d, want %d for %q", i, got.InputIds[i], want, token)
+				}
+			}
+		})
+	}
+}
+
+func TestBERTTokenizerOffsets(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	a, b := "Hello, WORLD!", "née [MASK]"
+	got, err := tok.EncodePair(a, b, 12)
+	if err != nil {
+		t.Fatalf("EncodePair() error = %v", err)
+	}
+
+	wantTokens := []string{"[CLS]", "hello", ",", "world", "!", "[SEP]", "nee", "[MASK]", "[SEP]", "[PAD]", "[PAD]", "[PAD]"}
+	wantOffsets := []Offset{
+		{}, {0, 5, 0, 5}, {5, 6, 5, 6}, {7, 12, 7, 12}, {12, 13, 12, 13}, {},
+		{0, 4, 0, 3}, {5, 11, 4, 10}, {}, {}, {}, {},
+	}
+	wantWords := []int{-1, 0, 1, 2, 3, -1, 0, 1, -1, -1, -1, -1}
+	wantSequences := []int{-1, 0, 0, 0, 0, -1, 1, 1, -1, -1, -1, -1}
+
+	if !reflect.DeepEqual(got.Tokens, wantTokens) {
+		t.Fatalf("Tokens = %q, want %q", got.Tokens, wantTokens)
+	}
+	if !reflect.DeepEqual(got.Offsets, wantOffsets) {
+		t.Errorf("Offsets = %v, want %v", got.Offsets, wantOffsets)
+	}
+	if !reflect.DeepEqual(got.WordIds, wantWords) {
+		t.Errorf("WordIds = %v, want %v", got.WordIds, wantWords)
+	}
+	if !reflect.DeepEqual(got.SequenceIds, wantSequences) {
+		t.Errorf("SequenceIds = %v, want %v", got.SequenceIds, wantSequences)
+	}
+}
+
+// TestBERTBasicTokenizationGolden checks the reference BasicTokenizer behavior
+// recorded in testdata/bert_basic.json: the words before WordPiece and, for some
+// cases, the final tokens
+func TestBERTBasicTokenizationGolden(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "bert_basic.json"))
+	if err != nil {
+		t.Fatalf("failed to read fixtures: %v", err)
+	}
+	var cases []struct {
+		Name   string   `json:"name"`
+		Text   string   `json:"text"`
+		Basic  []string `json:"basic"`
+		Tokens []string `json:"tokens"`
+	}
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("failed to decode fixtures: %v", err)
+	}
+
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+	p := tok.pipeline
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			if tt.Basic != nil {
+				n := newNormalized(tt.Text, 0)
+				p.normalizer.normalize(n)
+				var words []string
+				for _, w := range p.preTokenizer.preTokenize([]*normalized{n}) {
+					if w.text != "" {
+						words = append(words, w.text)
+					}
+				}
+				if !reflect.DeepEqual(words, tt.Basic) {
+					t.Errorf("words = %q, want %q", words, tt.Basic)
+				}
+			}
+			if tt.Tokens != nil {
+				out, err := tok.Encode(tt.Text, 0)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				if got := out.Tokens[1 : len(out.Tokens)-1]; !reflect.DeepEqual(got, tt.Tokens) {
+					t.Errorf("Tokens = %q, want %q", got, tt.Tokens)
+				}
+			}
+		})
+	}
+}
+
+func TestReadVocab(t *testing.T) {
+	// ids are line indices as in transformers load_vocab, blank lines included
+	got, err := readVocab(strings.NewReader("[PAD]\r\n\n a \r\nhello\nhello\nworld"))
+	if err != nil {
+		t.Fatalf("readVocab() error = %v", err)
+	}
+	want := map[string]int64{"[PAD]": 0, "": 1, " a ": 2, "hello": 4, "world": 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readVocab() = %q, want %q", got, want)
+	}
+}
+
+func TestBERTTokenizerOptions(t *testing.T) {
+	vocab, err := os.ReadFile(filepath.Join("testdata", "bert_vocab.txt"))
+	if err != nil {
+		t.Fatalf("failed to read vocab: %v", err)
+	}
+	fsys := fstest.MapFS{"vocab.txt": {Data: vocab}}
+
+	tests := []struct {
+		name          string
+		opts          []BERTOption
+		text          string
+		wantIDs       []int64
+		wantTokens    []string
+		wantDecoded   string
+		wantVocabSize int
+	}{
+		{
+			name:          "uncased",
+			text:          "Héllo, HELLO worlds!",
+			wantIDs:       []int64{2, 6, 8, 6, 7, 10, 9, 3},
+			wantTokens:    []string{"[CLS]", "hello", ",", "hello", "world", "##s", "!", "[SEP]"},
+			wantDecoded:   "hello, hello worlds!",
+			wantVocabSize: 14,
+		},
+		{
+			name:          "cased",
+			opts:          []BERTOption{WithLowerCase(false)},
+			text:          "Hello hello Héllo [MASK] [mask]",
+			wantIDs:       []int64{2, 5, 6, 1, 4, 1, 1, 1, 3},
+			wantTokens:    []string{"[CLS]", "Hello", "hello", "[UNK]", "[MASK]", "[UNK]", "[UNK]", "[UNK]", "[SEP]"},
+			wantDecoded:   "Hello hello",
+			wantVocabSize: 14,
+		},
+		{
+			name:          "custom special tokens",
+			opts:          []BERTOption{WithBERTSpecialTokens(SpecialTokens{UNK: "<unk>", CLS: "<s>", SEP: "</s>"})},
+			text:          "hello there",
+			wantIDs:       []int64{11, 6, 13, 12},
+			wantTokens:    []string{"<s>", "hello", "<unk>", "</s>"},
+			wantDecoded:   "hello",
+			wantVocabSize: 14,
+		},
+		{
+			name:          "added tokens",
+			opts:          []BERTOption{WithAddedTokens(AddedToken{Content: "<ent>"}, AddedToken{Content: "WORLDS", Normalized: true})},
+			text:          "hello<ent>worlds",
+			wantIDs:       []int64{2, 6, 14, 15, 3},
+			wantTokens:    []string{"[CLS]", "hello", "<ent>", "WORLDS", "[SEP]"},
+			wantDecoded:   "hello <ent> WORLDS",
+			wantVocabSize: 16,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := LoadBERTTokenizerFS(fsys, "vocab.txt", tt.opts...)
+			if err != nil {
+				t.Fatalf("LoadBERTTokenizerFS() error = %v", err)
+			}
+			got, err := tok.Encode(tt.text, 0)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.InputIds, tt.wantIDs) {
+				t.Errorf("InputIds = %v, want %v", got.InputIds, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+			decoded, err := tok.Decode(got.InputIds, true)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if decoded != tt.wantDecoded {
+				t.Errorf("Decode() = %q, want %q", decoded, tt.wantDecoded)
+			}
+			if size := tok.VocabSize(); size != tt.wantVocabSize {
+				t.Errorf("VocabSize() = %d, want %d", size, tt.wantVocabSize)
+			}
+		})
+	}
+
+	if _, err := LoadBERTTokenizer(filepath.Join("testdata", "bert_vocab.txt")); err != nil {
+		t.Errorf("LoadBERTTokenizer() error = %v", err)
+	}
+	if _, err := LoadBERTTokenizerFS(fsys, "vocab.txt", WithBERTSpecialTokens(SpecialTokens{UNK: "<missing>", CLS: "<s>", SEP: "</s>"})); err == nil {
+		t.Error("LoadBERTTokenizerFS() with a missing special token succeeded")
+	}
+	if _, err := NewBERTTokenizerFromReader(strings.NewReader("")); err == nil {
+		t.Error("NewBERTTokenizerFromReader() with an empty vocab succeeded")
+	}
+}
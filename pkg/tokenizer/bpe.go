@@ -0,0 +1,189 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// bpeCacheCapacity bounds the number of words whose merges are cached
+const bpeCacheCapacity = 10000
+
+// bpeMerge is the result of merging a pair of tokens
+type bpeMerge struct {
+	rank int
+	id   int64
+}
+
+// bpeModel applies byte-pair encoding merges in rank order
+type bpeModel struct {
+	*vocabulary
+	merges       map[[2]int64]bpeMerge
+	unk          string
+	unkID        int64
+	hasUnk       bool
+	prefix       string
+	suffix       string
+	fuseUnk      bool
+	byteFallback bool
+	ignoreMerges bool
+	cache        *bpeCache
+}
+
+// bpeCache remembers the tokens of recently seen words, which repeat often in
+// natural text. It stops growing once it reaches its capacity.
+type bpeCache struct {
+	mu       sync.RWMutex
+	words    map[string][]token
+	capacity int
+}
+
+func newBPECache(capacity int) *bpeCache {
+	return &bpeCache{words: make(map[string][]token), capacity: capacity}
+}
+
+func (c *bpeCache) get(word string) ([]token, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tokens, ok := c.words[word]
+	return tokens, ok
+}
+
+func (c *bpeCache) put(word string, tokens []token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.words) < c.capacity {
+		c.words[word] = tokens
+	}
+}
+
+// newBPEModel creates a BPE model from its vocabulary and ordered merges. Tokens
+// that continue a word start with prefix.
+func newBPEModel(vocab *vocabulary, merges [][2]string, prefix string) (*bpeModel, error) {
+	m := &bpeModel{
+		vocabulary: vocab,
+		merges:     make(map[[2]int64]bpeMerge, len(merges)),
+		prefix:     prefix,
+		cache:      newBPECache(bpeCacheCapacity),
+	}
+	for rank, pair := range merges {
+		a, err := vocab.requireToken(pair[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid merge %d: %w", rank, err)
+		}
+		b, err := vocab.requireToken(pair[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid merge %d: %w", rank, err)
+		}
+		merged, err := vocab.requireToken(pair[0] + strings.TrimPrefix(pair[1], prefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid merge %d: %w", rank, err)
+		}
+		if _, ok := m.merges[[2]int64{a, b}]; !ok {
+			m.merges[[2]int64{a, b}] = bpeMerge{rank: rank, id: merged}
+		}
+	}
+	return m, nil
+}
+
+// setUnknown sets the token used for characters that are not in the vocabulary
+func (m *bpeModel) setUnknown(unk string) error {
+	id, err := m.requireToken(unk)
+	if err != nil {
+		return fmt.Errorf("invalid unknown token: %w", err)
+	}
+	m.unk, m.unkID, m.hasUnk = unk, id, true
+	return nil
+}
+
+func (m *bpeModel) tokenize(word string) ([]token, error) {
+	if word == "" {
+		return nil, nil
+	}
+	if m.ignoreMerges {
+		if id, ok := m.ids[word]; ok {
+			return []token{{id: id, value: word, start: 0, end: len(word)}}, nil
+		}
+	}
+
+	if tokens, ok := m.cache.get(word); ok {
+		return tokens, nil
+	}
+
+	symbols := m.initialSymbols(word)
+	for len(symbols) > 1 {
+		best := -1
+		var bestMerge bpeMerge
+		for i := 0; i+1 < len(symbols); i++ {
+			merge, ok := m.merges[[2]int64{symbols[i].id, symbols[i+1].id}]
+			if ok && (best < 0 || merge.rank < bestMerge.rank) {
+				best, bestMerge = i, merge
+			}
+		}
+		if best < 0 {
+			break
+		}
+		symbols[best] = token{id: bestMerge.id, start: symbols[best].start, end: symbols[best+1].end}
+		symbols = append(symbols[:best+1], symbols[best+2:]...)
+	}
+
+	for i := range symbols {
+		symbols[i].value = m.tokens[symbols[i].id]
+	}
+	m.cache.put(word, symbols)
+	return symbols, nil
+}
+
+// initialSymbols splits a word into characters, falling back to byte tokens or
+// the unknown token for characters outside the vocabulary
+func (m *bpeModel) initialSymbols(word string) []token {
+	symbols := make([]token, 0, len(word))
+	for i := 0; i < len(word); {
+		_, size := utf8.DecodeRuneInString(word[i:])
+		start, end := i, i+size
+		i = end
+
+		s := word[start:end]
+		if start > 0 {
+			s = m.prefix + s
+		}
+		if end == len(word) {
+			s += m.suffix
+		}
+		if id, ok := m.ids[s]; ok {
+			symbols = append(symbols, token{id: id, start: start, end: end})
+			continue
+		}
+
+		if m.byteFallback {
+			if bytes, ok := byteFallbackTokens(m.vocabulary, word[start:end], start); ok {
+				symbols = append(symbols, bytes...)
+				continue
+			}
+		}
+		if !m.hasUnk {
+			continue
+		}
+		if m.fuseUnk && len(symbols) > 0 && symbols[len(symbols)-1].id == m.unkID && symbols[len(symbols)-1].end == start {
+			symbols[len(symbols)-1].end = end
+			continue
+		}
+		symbols = append(symbols, token{id: m.unkID, start: start, end: end})
+	}
+	return symbols
+}
+
+// byteFallbackTokens returns the <0xXX> tokens of the bytes of s, if all are in the vocabulary
+func byteFallbackTokens(vocab *vocabulary, s string, offset int) ([]token, bool) {
+	tokens := make([]token, len(s))
+	for j := 0; j < len(s); j++ {
+		id, ok := vocab.ids[fmt.Sprintf("<0x%02X>", s[j])]
+		if !ok {
+			return nil, false
+		}
+		// every byte token covers the whole character so offsets stay on rune boundaries
+		tokens[j] = token{id: id, start: offset, end: offset + len(s)}
+	}
+	return tokens, true
+}
@@ -0,0 +1,224 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// endOfText is the GPT-2 end of text token, which also serves as padding when the
+// vocabulary has no padding token
+const endOfText = "<|endoftext|>"
+
+// BPETokenizer is a byte-level BPE tokenizer for GPT-2 and RoBERTa family models
+type BPETokenizer struct {
+	pipeline      *pipeline
+	specialTokens SpecialTokens
+	labels        map[int]string
+}
+
+// BPEOption configures a BPETokenizer
+type BPEOption func(*bpeOptions)
+
+type bpeOptions struct {
+	addPrefixSpace bool
+	specialTokens  SpecialTokens
+}
+
+// WithPrefixSpace adds a space before the text so that the first word is
+// tokenized like any other word
+func WithPrefixSpace(enabled bool) BPEOption {
+	return func(o *bpeOptions) {
+		o.addPrefixSpace = enabled
+	}
+}
+
+// WithBPESpecialTokens sets the special tokens, which default to the RoBERTa tokens
+func WithBPESpecialTokens(tokens SpecialTokens) BPEOption {
+	return func(o *bpeOptions) {
+		o.specialTokens = tokens
+	}
+}
+
+// DefaultBPESpecialTokens returns the special tokens of RoBERTa
+func DefaultBPESpecialTokens() SpecialTokens {
+	return SpecialTokens{
+		PAD:  "<pad>",
+		UNK:  "<unk>",
+		CLS:  "<s>",
+		SEP:  "</s>",
+		MASK: "<mask>",
+	}
+}
+
+// LoadBPETokenizer loads a byte-level BPE tokenizer from vocab.json and merges.txt files
+func LoadBPETokenizer(vocabPath, mergesPath string, opts ...BPEOption) (*BPETokenizer, error) {
+	vocab, err := os.Open(vocabPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocab file: %w", err)
+	}
+	defer vocab.Close()
+
+	merges, err := os.Open(mergesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open merges file: %w", err)
+	}
+	defer merges.Close()
+
+	return NewBPETokenizer(vocab, merges, opts...)
+}
+
+// NewBPETokenizer creates a byte-level BPE tokenizer from the contents of
+// vocab.json and merges.txt. Special tokens that are not in the vocabulary are
+// ignored, and [CLS] A [SEP] style special tokens are only added when the
+// vocabulary has both of them.
+func NewBPETokenizer(vocab, merges io.Reader, opts ...BPEOption) (*BPETokenizer, error) {
+	options := &bpeOptions{specialTokens: DefaultBPESpecialTokens()}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	ids := make(map[string]int64)
+	if err := json.NewDecoder(vocab).Decode(&ids); err != nil {
+		return nil, fmt.Errorf("failed to decode vocab file: %w", err)
+	}
+	pairs, err := readMerges(merges)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newBPEModel(newVocabulary(ids), pairs, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build BPE model: %w", err)
+	}
+
+	special := options.specialTokens
+	for _, token := range []*string{&special.PAD, &special.UNK, &special.CLS, &special.SEP, &special.MASK} {
+		if _, ok := ids[*token]; !ok {
+			*token = ""
+		}
+	}
+	if special.PAD == "" {
+		if _, ok := ids[endOfText]; ok {
+			special.PAD = endOfText
+		}
+	}
+
+	var added []addedToken
+	for _, content := range []string{special.PAD, special.UNK, special.CLS, special.SEP, special.MASK, endOfText} {
+		id, ok := ids[content]
+		if !ok {
+			continue
+		}
+		// like RoBERTa the mask token absorbs the space before it
+		added = append(added, addedToken{id: id, content: content, special: true, lstrip: content == special.MASK})
+	}
+
+	p := &pipeline{
+		preTokenizer: byteLevel{addPrefixSpace: options.addPrefixSpace, useRegex: true},
+		model:        m,
+		processor:    concatProcessing{},
+		decoder:      byteLevelDecoder{},
+		added:        newAddedVocabulary(added, nil),
+		trimOffsets:  true,
+	}
+	if special.CLS != "" && special.SEP != "" {
+		p.processor = robertaProcessing{
+			cls: specialToken{value: special.CLS, id: ids[special.CLS]},
+			sep: specialToken{value: special.SEP, id: ids[special.SEP]},
+		}
+	}
+	if special.PAD != "" {
+		p.padToken = specialToken{value: special.PAD, id: ids[special.PAD]}
+	}
+	if special.UNK != "" {
+		if err := m.setUnknown(special.UNK); err != nil {
+			return nil, err
+		}
+	}
+
+	labels := make(map[int]string, len(ids))
+	for token, id := range ids {
+		labels[int(id)] = token
+	}
+	return &BPETokenizer{pipeline: p, specialTokens: special, labels: labels}, nil
+}
+
+// readMerges reads merges.txt, which lists one space separated pair per line in
+// rank order after an optional #version header
+func readMerges(r io.Reader) ([][2]string, error) {
+	var merges [][2]string
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" || (line == 1 && strings.HasPrefix(text, "#version")) {
+			continue
+		}
+		a, b, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid merge on line %d: %q", line, text)
+		}
+		merges = append(merges, [2]string{a, b})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading merges file: %w", err)
+	}
+	return merges, nil
+}
+
+// Encode tokenizes text with the special tokens of the model. When maxLength is
+// positive the text is truncated, keeping the special tokens, and the output is
+// padded to exactly maxLength tokens. opts configure truncation and overflow.
+func (t *BPETokenizer) Encode(text string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encode(text, maxLength, opts...)
+}
+
+// EncodePair tokenizes a pair of texts, such as a question and a context, with
+// the special tokens of the model. When maxLength is positive the longer text is
+// truncated first, unless opts select another strategy, and the output is padded
+// to exactly maxLength tokens.
+func (t *BPETokenizer) EncodePair(a, b string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encodePair(a, b, maxLength, opts...)
+}
+
+// EncodeBatch tokenizes texts concurrently like Encode and pads them to the
+// longest encoding of the batch, unless opts select another padding strategy
+func (t *BPETokenizer) EncodeBatch(texts []string, maxLength int, opts ...EncodeOption) (*BatchOutput, error) {
+	return t.pipeline.encodeBatch(texts, maxLength, opts...)
+}
+
+// Decode converts ids back to text. Byte-level BPE is lossless, so decoding the
+// ids of Encode without special tokens gives back the original text.
+func (t *BPETokenizer) Decode(ids []int64, skipSpecial bool) (string, error) {
+	return t.pipeline.decode(ids, skipSpecial)
+}
+
+// TokenToID returns the id of a token
+func (t *BPETokenizer) TokenToID(token string) (int64, bool) {
+	return t.pipeline.tokenToID(token)
+}
+
+// IDToToken returns the token of an id
+func (t *BPETokenizer) IDToToken(id int64) (string, bool) {
+	return t.pipeline.idToToken(id)
+}
+
+// VocabSize returns the number of tokens in the vocabulary
+func (t *BPETokenizer) VocabSize() int {
+	return t.pipeline.vocabSize()
+}
+
+// SpecialTokens returns the special tokens of the tokenizer. Tokens that are not
+// in the vocabulary are empty.
+func (t *BPETokenizer) SpecialTokens() SpecialTokens {
+	return t.specialTokens
+}
+
+// Labels returns the tokens of the vocabulary by id
+func (t *BPETokenizer) Labels() map[int]string {
+	return t.labels
+}
@@ -0,0 +1,141 @@
+package tokenizer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func loadTestBPETokenizer(t *testing.T, opts ...BPEOption) *BPETokenizer {
+	t.Helper()
+	tok, err := LoadBPETokenizer(filepath.Join("testdata", "bpe", "vocab.json"), filepath.Join("testdata", "bpe", "merges.txt"), opts...)
+	if err != nil {
+		t.Fatalf("failed to load BPE tokenizer: %v", err)
+	}
+	return tok
+}
+
+func TestBPETokenizerEncode(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []BPEOption
+		text       string
+		maxLength  int
+		wantIDs    []int64
+		wantTokens []string
+	}{
+		{
+			name:       "merges",
+			text:       "hello world",
+			wantIDs:    []int64{0, 263, 268, 2},
+			wantTokens: []string{"<s>", "hello", "Ġworld", "</s>"},
+		},
+		{
+			name:       "prefix space",
+			opts:       []BPEOption{WithPrefixSpace(true)},
+			text:       "world",
+			wantIDs:    []int64{0, 268, 2},
+			wantTokens: []string{"<s>", "Ġworld", "</s>"},
+		},
+		{
+			name:       "unmerged bytes",
+			text:       "wo!",
+			wantIDs:    []int64{0, 123, 115, 37, 2},
+			wantTokens: []string{"<s>", "w", "o", "!", "</s>"},
+		},
+		{
+			name:       "mask and padding",
+			text:       "hello <mask>",
+			maxLength:  6,
+			wantIDs:    []int64{0, 263, 269, 2, 1, 1},
+			wantTokens: []string{"<s>", "hello", "<mask>", "</s>", "<pad>", "<pad>"},
+		},
+		{
+			name:       "without special tokens",
+			opts:       []BPEOption{WithBPESpecialTokens(SpecialTokens{})},
+			text:       "hello",
+			wantIDs:    []int64{263},
+			wantTokens: []string{"hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok := loadTestBPETokenizer(t, tt.opts...)
+			got, err := tok.Encode(tt.text, tt.maxLength)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.InputIds, tt.wantIDs) {
+				t.Errorf("InputIds = %v, want %v", got.InputIds, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestBPETokenizerDecode(t *testing.T) {
+	tok := loadTestBPETokenizer(t)
+	texts := []string{
+		"hello world",
+		"  leading and   repeated spaces\n\ttabs ",
+		"it's 2024, héllo wörld 👋",
+		"日本語のテキスト",
+		"hello <mask> world",
+	}
+
+	for _, text := range texts {
+		out, err := tok.Encode(text, 0)
+		if err != nil {
+			t.Fatalf("Encode(%q) error = %v", text, err)
+		}
+		got, err := tok.Decode(out.InputIds, true)
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		// the mask token absorbs the space before it
+		want := text
+		if text == "hello <mask> world" {
+			want = "hello world"
+		}
+		if got != want {
+			t.Errorf("Decode(Encode(%q)) = %q, want %q", text, got, want)
+		}
+	}
+
+	got, err := tok.Decode([]int64{0, 263, 2}, false)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if want := "<s>hello</s>"; got != want {
+		t.Errorf("Decode() = %q, want %q", got, want)
+	}
+	if _, err := tok.Decode([]int64{1000}, false); err == nil {
+		t.Error("Decode() with an unknown id succeeded")
+	}
+}
+
+func TestGPT2Matches(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello world's  test 123!!", []string{"Hello", " world", "'s", " ", " test", " 123", "!!"}},
+		{"a  ", []string{"a", "  "}},
+		{" \n b", []string{" \n", " b"}},
+		{"they'll", []string{"they", "'ll"}},
+		{"x'", []string{"x", "'"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, m := range gpt2Matches(tt.text) {
+			got = append(got, tt.text[m[0]:m[1]])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("gpt2Matches(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
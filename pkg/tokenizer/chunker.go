@@ -0,0 +1,404 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChunkBoundary is the kind of text unit that chunks are preferably split at
+type ChunkBoundary int
+
+const (
+	// ChunkSentences packs whole sentences into chunks
+	ChunkSentences ChunkBoundary = iota
+	// ChunkParagraphs packs whole paragraphs into chunks and only splits a
+	// paragraph into sentences when it does not fit on its own
+	ChunkParagraphs
+)
+
+// Chunk is a part of a document that fits the token budget of a Chunker
+type Chunk struct {
+	// Text is the text of the chunk, Offset.Start to Offset.End of the document
+	Text string
+	// Offset locates the chunk in the document in bytes and in runes
+	Offset Offset
+	// Tokens is the number of tokens of the chunk when it is encoded on its own,
+	// without special tokens
+	Tokens int
+}
+
+// ChunkOption configures a Chunker
+type ChunkOption func(*chunkOptions)
+
+type chunkOptions struct {
+	overlap  int
+	boundary ChunkBoundary
+}
+
+// WithChunkOverlap repeats up to the given number of tokens at the end of a chunk
+// at the start of the next one. Whole sentences or paragraphs are repeated, so
+// the overlap can be smaller.
+func WithChunkOverlap(tokens int) ChunkOption {
+	return func(o *chunkOptions) {
+		o.overlap = tokens
+	}
+}
+
+// WithChunkBoundary sets whether chunks are built from sentences, the default, or
+// from paragraphs
+func WithChunkBoundary(boundary ChunkBoundary) ChunkOption {
+	return func(o *chunkOptions) {
+		o.boundary = boundary
+	}
+}
+
+// Chunker splits long documents into chunks that a model can encode without
+// truncation
+type Chunker struct {
+	tokenizer Tokenizer
+	maxTokens int
+	budget    int
+	options   chunkOptions
+}
+
+// NewChunker creates a chunker for a tokenizer. maxTokens is the largest
+// encoding of a chunk, including the special tokens the tokenizer adds.
+func NewChunker(t Tokenizer, maxTokens int, opts ...ChunkOption) (*Chunker, error) {
+	options := chunkOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	empty, err := t.Encode("", 0, WithPadding(DoNotPad))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count special tokens: %w", err)
+	}
+	budget := maxTokens - len(empty.InputIds)
+	if budget <= 0 {
+		return nil, fmt.Errorf("max tokens %d leaves no room next to %d special tokens", maxTokens, len(empty.InputIds))
+	}
+	if options.overlap < 0 || options.overlap >= budget {
+		return nil, fmt.Errorf("overlap %d must be less than the %d tokens of a chunk", options.overlap, budget)
+	}
+	return &Chunker{tokenizer: t, maxTokens: maxTokens, budget: budget, options: options}, nil
+}
+
+// textUnit is a sentence, paragraph or run of tokens of the document. Its text is
+// the byte range [start, end) and its tokens the range [first, last).
+type textUnit struct {
+	start, end  int
+	first, last int
+}
+
+func (u textUnit) tokens() int {
+	return u.last - u.first
+}
+
+// Chunk splits text into chunks of at most the token budget. Consecutive sentences
+// or paragraphs are packed into a chunk while they fit, and a sentence that is
+// longer than a chunk on its own is split between tokens.
+func (c *Chunker) Chunk(text string) ([]Chunk, error) {
+	out, err := c.tokenizer.Encode(text, 0, WithPadding(DoNotPad))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode text: %w", err)
+	}
+	var offsets []Offset
+	for i, o := range out.Offsets {
+		if out.SequenceIds[i] == 0 {
+			offsets = append(offsets, o)
+		}
+	}
+	positions := tokenPositions(text, offsets)
+
+	var units []textUnit
+	for _, paragraph := range paragraphs(text) {
+		if c.options.boundary == ChunkParagraphs {
+			p := tokenRange(paragraph, positions)
+			fits, err := c.fits(text, p)
+			if err != nil {
+				return nil, err
+			}
+			if fits {
+				units = append(units, p)
+				continue
+			}
+		}
+		for _, sentence := range sentences(text, paragraph) {
+			s := tokenRange(sentence, positions)
+			fits, err := c.fits(text, s)
+			if err != nil {
+				return nil, err
+			}
+			if fits {
+				units = append(units, s)
+				continue
+			}
+			windows, err := c.tokenWindows(text, s, offsets, positions)
+			if err != nil {
+				return nil, err
+			}
+			units = append(units, windows...)
+		}
+	}
+
+	var chunks []Chunk
+	runes := runeIndex(text)
+	for i := 0; i < len(units); {
+		// tokens between units, such as whitespace tokens, count toward the budget
+		// too, so the tokens of a chunk are the range from its first unit to its last
+		start := i
+		for i < len(units) && units[i].last-units[start].first <= c.budget {
+			i++
+		}
+		// a chunk can encode to more tokens on its own than in the document when
+		// it starts inside a word, so it drops units until its text fits
+		var first, last textUnit
+		var tokens int
+		for ; ; i-- {
+			first, last = units[start], units[i-1]
+			var err error
+			if tokens, err = c.count(text[first.start:last.end]); err != nil {
+				return nil, err
+			}
+			if tokens <= c.budget || i-1 == start {
+				break
+			}
+		}
+		chunks = append(chunks, Chunk{
+			Text:   text[first.start:last.end],
+			Offset: Offset{Start: first.start, End: last.end, RuneStart: runes[first.start], RuneEnd: runes[last.end]},
+			Tokens: tokens,
+		})
+		if i == len(units) {
+			break
+		}
+
+		// step back over the units that fit the overlap, as long as the next unit
+		// still fits after them and the chunk moves forward. Units without tokens
+		// of their own, whose text a token of the previous unit covers, would fit
+		// any overlap, so nothing steps back without one.
+		next := i
+		for back := next - 1; c.options.overlap > 0 && back > start; back-- {
+			if units[next-1].last-units[back].first > c.options.overlap || units[next].last-units[back].first > c.budget {
+				break
+			}
+			i = back
+		}
+	}
+	return chunks, nil
+}
+
+// tokenWindows splits a unit that is longer than the budget into runs of tokens
+// that overlap by the overlap of the chunker. A window that starts or ends inside
+// a word can encode to more tokens on its own than in the document, as with
+// tokenizers that prepend a space to their input, so windows are shortened until
+// their text fits.
+func (c *Chunker) tokenWindows(text string, u textUnit, offsets []Offset, positions []int) ([]textUnit, error) {
+	var windows []textUnit
+	for first := u.first; ; {
+		start := u.start
+		if first > u.first {
+			start = positions[first]
+		}
+		last := min(first+c.budget, u.last)
+		for ; ; last-- {
+			w := textUnit{start: start, end: u.end, first: first, last: last}
+			if last < u.last {
+				// tokens of one character, such as byte fallback tokens, stay in one
+				// window, and a window that ends with a space token leaves it out
+				if last > first+1 && positions[last] < offsets[last-1].End {
+					continue
+				}
+				r, _ := trimRange(text, start, max(start, offsets[last-1].End))
+				w.end = r[1]
+			}
+			if last == first+1 {
+				windows = append(windows, w)
+				break
+			}
+			fits, err := c.fits(text, w)
+			if err != nil {
+				return nil, err
+			}
+			if fits {
+				windows = append(windows, w)
+				break
+			}
+		}
+		if last == u.last {
+			return windows, nil
+		}
+		first = max(last-c.options.overlap, first+1)
+	}
+}
+
+// count returns the number of tokens of text encoded on its own, without
+// special tokens
+func (c *Chunker) count(text string) (int, error) {
+	out, err := c.tokenizer.Encode(text, 0, WithPadding(DoNotPad))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode chunk: %w", err)
+	}
+	return len(out.InputIds) - (c.maxTokens - c.budget), nil
+}
+
+// fits reports whether a unit fits the budget, both in the encoding of the
+// document and encoded on its own
+func (c *Chunker) fits(text string, u textUnit) (bool, error) {
+	if u.tokens() > c.budget {
+		return false, nil
+	}
+	tokens, err := c.count(text[u.start:u.end])
+	return tokens <= c.budget, err
+}
+
+// tokenPositions returns the byte that places every token in a sentence or
+// paragraph, its first byte that is not a space. Tokens of only spaces, such as
+// the "▁" of Metaspace or the "Ġ" of byte-level tokenizers, belong to the word
+// after them and take its position.
+func tokenPositions(text string, offsets []Offset) []int {
+	positions := make([]int, len(offsets))
+	next := len(text)
+	for i := len(offsets) - 1; i >= 0; i-- {
+		start, limit := offsets[i].Start, len(text)
+		if i+1 < len(offsets) {
+			limit = max(start, offsets[i+1].Start)
+		}
+		if j := strings.IndexFunc(text[start:limit], isNotSpace); j >= 0 {
+			next = start + j
+		}
+		positions[i] = next
+	}
+	return positions
+}
+
+func isNotSpace(r rune) bool {
+	return !unicode.IsSpace(r)
+}
+
+// tokenRange finds the tokens whose positions are within the byte range
+// [start, end)
+func tokenRange(r [2]int, positions []int) textUnit {
+	u := textUnit{start: r[0], end: r[1]}
+	u.first = searchPositions(positions, r[0])
+	u.last = searchPositions(positions, r[1])
+	return u
+}
+
+// searchPositions returns the index of the first token at or after pos
+func searchPositions(positions []int, pos int) int {
+	lo, hi := 0, len(positions)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if positions[mid] < pos {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// paragraphs returns the byte ranges of the paragraphs of text, which are
+// separated by blank lines, without surrounding whitespace
+func paragraphs(text string) [][2]int {
+	var out [][2]int
+	start := 0
+	for start < len(text) {
+		end := len(text)
+		next := len(text)
+		for i := start; i < len(text); {
+			j := strings.IndexByte(text[i:], '\n')
+			if j < 0 {
+				break
+			}
+			j += i
+			// a blank line is a newline followed by whitespace and another newline
+			k := j + 1
+			for k < len(text) && text[k] != '\n' && isSpaceByte(text[k]) {
+				k++
+			}
+			if k < len(text) && text[k] == '\n' {
+				end = j
+				next = k + 1
+				break
+			}
+			i = j + 1
+		}
+		if r, ok := trimRange(text, start, end); ok {
+			out = append(out, r)
+		}
+		start = next
+	}
+	return out
+}
+
+// sentences returns the byte ranges of the sentences of a paragraph. A sentence
+// ends at a line break or after terminal punctuation, and closing quotes or
+// brackets, that is followed by whitespace. CJK terminal punctuation needs no
+// whitespace after it.
+func sentences(text string, paragraph [2]int) [][2]int {
+	var out [][2]int
+	start := paragraph[0]
+	for i := paragraph[0]; i < paragraph[1]; {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		end := -1
+		switch {
+		case r == '\n':
+			end = i - size
+		case isSentenceTerminal(r):
+			for i < paragraph[1] {
+				next, n := utf8.DecodeRuneInString(text[i:])
+				if !isSentenceTerminal(next) && !isClosingPunct(next) {
+					break
+				}
+				i += n
+			}
+			if i == paragraph[1] || isFullWidthTerminal(r) {
+				end = i
+			} else if next, _ := utf8.DecodeRuneInString(text[i:]); unicode.IsSpace(next) {
+				end = i
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		if r, ok := trimRange(text, start, end); ok {
+			out = append(out, r)
+		}
+		start = i
+	}
+	if r, ok := trimRange(text, start, paragraph[1]); ok {
+		out = append(out, r)
+	}
+	return out
+}
+
+func isSentenceTerminal(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…' || isFullWidthTerminal(r)
+}
+
+func isFullWidthTerminal(r rune) bool {
+	return r == '。' || r == '！' || r == '？'
+}
+
+func isClosingPunct(r rune) bool {
+	return r == '"' || r == '\'' || r == ')' || r == ']' || r == '”' || r == '’' || r == '」' || r == '』' || r == '）'
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\v' || b == '\f'
+}
+
+// trimRange trims whitespace from both ends of text[start:end] and reports
+// whether anything is left
+func trimRange(text string, start, end int) ([2]int, bool) {
+	s := text[start:end]
+	trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+	start += len(s) - len(trimmed)
+	end = start + len(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+	return [2]int{start, end}, end > start
+}
@@ -0,0 +1,219 @@
+package tokenizer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "One. Two! Three?", want: []string{"One.", "Two!", "Three?"}},
+		{text: "He said \"stop.\" Then left...  Fine", want: []string{"He said \"stop.\"", "Then left...", "Fine"}},
+		{text: "Version 3.14 is out.\nNext line", want: []string{"Version 3.14 is out.", "Next line"}},
+		{text: "你好。世界！好", want: []string{"你好。", "世界！", "好"}},
+		{text: "  no terminal  ", want: []string{"no terminal"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, r := range sentences(tt.text, [2]int{0, len(tt.text)}) {
+			got = append(got, tt.text[r[0]:r[1]])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sentences(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParagraphs(t *testing.T) {
+	text := "\nFirst line\nsame paragraph.\n \t\nSecond.\n\n\n  Third  \n"
+	var got []string
+	for _, r := range paragraphs(text) {
+		got = append(got, text[r[0]:r[1]])
+	}
+	if want := []string{"First line\nsame paragraph.", "Second.", "Third"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paragraphs() = %q, want %q", got, want)
+	}
+}
+
+func TestChunker(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+	corpus := readCorpus(t)
+
+	tests := []struct {
+		name      string
+		text      string
+		maxTokens int
+		opts      []ChunkOption
+		want      []string
+	}{
+		{
+			name:      "sentences",
+			text:      "One two three. Four five. Six seven eight nine.",
+			maxTokens: 10,
+			want:      []string{"One two three. Four five.", "Six seven eight nine."},
+		},
+		{
+			name:      "overlap",
+			text:      "One two. Three four. Five six. Seven eight.",
+			maxTokens: 11,
+			opts:      []ChunkOption{WithChunkOverlap(3)},
+			want:      []string{"One two. Three four. Five six.", "Five six. Seven eight."},
+		},
+		{
+			name:      "paragraphs",
+			text:      "One two. Three.\n\nFour five. Six.",
+			maxTokens: 10,
+			opts:      []ChunkOption{WithChunkBoundary(ChunkParagraphs)},
+			want:      []string{"One two. Three.", "Four five. Six."},
+		},
+		{
+			name:      "sentences across paragraphs",
+			text:      "One two. Three.\n\nFour five. Six.",
+			maxTokens: 10,
+			want:      []string{"One two. Three.\n\nFour five.", "Six."},
+		},
+		{
+			name:      "long sentence",
+			text:      "one two three four five six seven eight",
+			maxTokens: 6,
+			opts:      []ChunkOption{WithChunkOverlap(1)},
+			want:      []string{"one two three four", "four five six seven", "seven eight"},
+		},
+		{
+			name:      "empty",
+			text:      " \n\n ",
+			maxTokens: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewChunker(tok, tt.maxTokens, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewChunker() error = %v", err)
+			}
+			chunks, err := c.Chunk(tt.text)
+			if err != nil {
+				t.Fatalf("Chunk() error = %v", err)
+			}
+			var got []string
+			for _, chunk := range chunks {
+				got = append(got, chunk.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chunk() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// every chunk of a real document fits the budget when encoded on its own and
+	// its offsets locate its text, also for tokenizers whose tokens start with the
+	// space before a word
+	unigram, err := LoadHuggingFaceTokenizer(filepath.Join("testdata", "unigram.json"))
+	if err != nil {
+		t.Fatalf("LoadHuggingFaceTokenizer() error = %v", err)
+	}
+	roberta, err := LoadHuggingFaceTokenizer(filepath.Join("testdata", "roberta.json"))
+	if err != nil {
+		t.Fatalf("LoadHuggingFaceTokenizer() error = %v", err)
+	}
+	short := "Hello world. The cat sat. A dog"
+	invariants := []struct {
+		name      string
+		tok       Tokenizer
+		text      string
+		maxTokens int
+	}{
+		{"bert", tok, corpus, 48},
+		{"unigram", unigram, corpus, 48},
+		{"unigram short", unigram, short, 10},
+		{"roberta", roberta, corpus, 48},
+		{"roberta short", roberta, short, 5},
+	}
+	for _, inv := range invariants {
+		empty, err := inv.tok.Encode("", 0, WithPadding(DoNotPad))
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		special := len(empty.InputIds)
+
+		for _, overlap := range []int{0, 2} {
+			c, err := NewChunker(inv.tok, inv.maxTokens, WithChunkOverlap(overlap))
+			if err != nil {
+				t.Fatalf("%s: NewChunker() error = %v", inv.name, err)
+			}
+			chunks, err := c.Chunk(inv.text)
+			if err != nil {
+				t.Fatalf("%s: Chunk() error = %v", inv.name, err)
+			}
+			if len(chunks) < 2 {
+				t.Fatalf("%s: Chunk() returned %d chunks", inv.name, len(chunks))
+			}
+			overlapped := 0
+			for i, chunk := range chunks {
+				out, err := inv.tok.Encode(chunk.Text, 0, WithPadding(DoNotPad))
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				if len(out.InputIds) > inv.maxTokens || len(out.InputIds)-special != chunk.Tokens {
+					t.Errorf("%s: chunk %d %q encodes to %d tokens, Tokens = %d", inv.name, i, chunk.Text, len(out.InputIds), chunk.Tokens)
+				}
+				o := chunk.Offset
+				if inv.text[o.Start:o.End] != chunk.Text || utf8.RuneCountInString(inv.text[:o.Start]) != o.RuneStart ||
+					utf8.RuneCountInString(inv.text[:o.End]) != o.RuneEnd {
+					t.Errorf("%s: chunk %d offsets %+v do not locate %q", inv.name, i, o, chunk.Text)
+				}
+				if i > 0 && o.Start < chunks[i-1].Offset.End {
+					overlapped++
+				}
+			}
+			if overlap == 0 && overlapped > 0 {
+				t.Errorf("%s: %d of %d chunks overlap without overlap", inv.name, overlapped, len(chunks))
+			}
+		}
+	}
+
+	// the repeated text of overlapping chunks fits the overlap
+	c, err := NewChunker(tok, 48, WithChunkOverlap(16))
+	if err != nil {
+		t.Fatalf("NewChunker() error = %v", err)
+	}
+	chunks, err := c.Chunk(corpus)
+	if err != nil {
+		t.Fatalf("Chunk() error = %v", err)
+	}
+	overlapped := 0
+	for i := 1; i < len(chunks); i++ {
+		o := chunks[i].Offset
+		if o.Start >= chunks[i-1].Offset.End {
+			continue
+		}
+		overlapped++
+		repeated, err := tok.Encode(corpus[o.Start:chunks[i-1].Offset.End], 0, WithPadding(DoNotPad))
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		if len(repeated.InputIds)-2 > 16 {
+			t.Errorf("chunk %d repeats %d tokens of chunk %d", i, len(repeated.InputIds)-2, i-1)
+		}
+	}
+	if overlapped == 0 {
+		t.Errorf("none of %d chunks overlap with an overlap of 16 tokens", len(chunks))
+	}
+
+	if _, err := NewChunker(tok, 2); err == nil {
+		t.Error("NewChunker() with no room for text succeeded")
+	}
+	if _, err := NewChunker(tok, 10, WithChunkOverlap(8)); err == nil {
+		t.Error("NewChunker() with an overlap of a whole chunk succeeded")
+	}
+}
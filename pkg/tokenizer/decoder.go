@@ -0,0 +1,251 @@
+package tokenizer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decoder turns the tokens of a model back into text. Decoders rewrite the tokens
+// and can be chained, the final tokens are concatenated.
+type decoder interface {
+	decodeChain(tokens []string) []string
+}
+
+// decoderSequence applies decoders in order
+type decoderSequence []decoder
+
+func (s decoderSequence) decodeChain(tokens []string) []string {
+	for _, d := range s {
+		tokens = d.decodeChain(tokens)
+	}
+	return tokens
+}
+
+// byteLevelDecoder reverses the byte to unicode mapping of byte-level BPE
+type byteLevelDecoder struct{}
+
+func (byteLevelDecoder) decodeChain(tokens []string) []string {
+	var b []byte
+	for _, t := range tokens {
+		for i := 0; i < len(t); {
+			r, size := utf8.DecodeRuneInString(t[i:])
+			if c, ok := unicodeToByte[r]; ok {
+				b = append(b, c)
+			} else {
+				b = append(b, t[i:i+size]...)
+			}
+			i += size
+		}
+	}
+	return []string{string(b)}
+}
+
+// byteFallbackDecoder turns runs of <0xXX> byte tokens back into text, with a
+// replacement character for every byte of an invalid sequence
+type byteFallbackDecoder struct{}
+
+func (byteFallbackDecoder) decodeChain(tokens []string) []string {
+	out := make([]string, 0, len(tokens))
+	var pending []byte
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if utf8.Valid(pending) {
+			out = append(out, string(pending))
+		} else {
+			for range pending {
+				out = append(out, string(utf8.RuneError))
+			}
+		}
+		pending = pending[:0]
+	}
+	for _, t := range tokens {
+		if b, ok := parseByteToken(t); ok {
+			pending = append(pending, b)
+			continue
+		}
+		flush()
+		out = append(out, t)
+	}
+	flush()
+	return out
+}
+
+// parseByteToken parses a <0xXX> byte fallback token
+func parseByteToken(t string) (byte, bool) {
+	if len(t) != 6 || !strings.HasPrefix(t, "<0x") || t[5] != '>' {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(t[3:5], 16, 8)
+	if err != nil {
+		return 0, false
+	}
+	return byte(v), true
+}
+
+// metaspaceDecoder replaces word boundary markers with spaces and removes the
+// space added before the first word
+type metaspaceDecoder struct {
+	replacement string
+	prepend     prependScheme
+}
+
+func (m metaspaceDecoder) decodeChain(tokens []string) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		t = strings.ReplaceAll(t, m.replacement, " ")
+		if i == 0 && m.prepend != prependNever {
+			t = strings.TrimPrefix(t, " ")
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// wordPieceDecoder joins continuation pieces to the previous token and puts a
+// space before every other token
+type wordPieceDecoder struct {
+	prefix string
+	// cleanup removes the spaces before punctuation and contractions
+	cleanup bool
+}
+
+func (d wordPieceDecoder) decodeChain(tokens []string) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		if i > 0 {
+			if strings.HasPrefix(t, d.prefix) {
+				t = t[len(d.prefix):]
+			} else {
+				t = " " + t
+			}
+		}
+		if d.cleanup {
+			t = cleanupTokenization(t)
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// tokenizationCleanup undoes the spaces that splitting punctuation and
+// contractions puts into text
+var tokenizationCleanup = strings.NewReplacer(
+	" .", ".",
+	" ?", "?",
+	" !", "!",
+	" ,", ",",
+	" ' ", "'",
+	" n't", "n't",
+	" 'm", "'m",
+	" 's", "'s",
+	" 've", "'ve",
+	" 're", "'re",
+)
+
+// cleanupTokenization removes the spaces the reference BERT tokenizer removes
+// when decoding
+func cleanupTokenization(s string) string {
+	return tokenizationCleanup.Replace(s)
+}
+
+// cleanupDecoder joins tokens and removes the spaces before punctuation and
+// contractions from the whole text, like the Python BERT tokenizer
+type cleanupDecoder struct{}
+
+func (cleanupDecoder) decodeChain(tokens []string) []string {
+	return []string{cleanupTokenization(strings.Join(tokens, ""))}
+}
+
+// bpeDecoder turns the end of word suffix of BPE models into spaces
+type bpeDecoder struct {
+	suffix string
+}
+
+func (d bpeDecoder) decodeChain(tokens []string) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		replacement := " "
+		if i == len(tokens)-1 {
+			replacement = ""
+		}
+		out[i] = strings.ReplaceAll(t, d.suffix, replacement)
+	}
+	return out
+}
+
+// fuseDecoder joins all tokens into one
+type fuseDecoder struct{}
+
+func (fuseDecoder) decodeChain(tokens []string) []string {
+	return []string{strings.Join(tokens, "")}
+}
+
+// stripDecoder removes up to start leading and stop trailing occurrences of
+// content from every token
+type stripDecoder struct {
+	content     string
+	start, stop int
+}
+
+func (d stripDecoder) decodeChain(tokens []string) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		for range d.start {
+			if !strings.HasPrefix(t, d.content) {
+				break
+			}
+			t = t[len(d.content):]
+		}
+		for range d.stop {
+			if !strings.HasSuffix(t, d.content) {
+				break
+			}
+			t = t[:len(t)-len(d.content)]
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// replaceDecoder replaces the matches of a pattern in every token
+type replaceDecoder struct {
+	pattern *regexp.Regexp
+	content string
+}
+
+func (d replaceDecoder) decodeChain(tokens []string) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = d.pattern.ReplaceAllLiteralString(t, d.content)
+	}
+	return out
+}
+
+// decode converts ids back to text. Special added tokens are dropped when
+// skipSpecial is set.
+func (p *pipeline) decode(ids []int64, skipSpecial bool) (string, error) {
+	tokens := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if t, ok := p.added.byID[id]; ok {
+			if !t.special || !skipSpecial {
+				tokens = append(tokens, t.content)
+			}
+			continue
+		}
+		token, ok := p.model.idToToken(id)
+		if !ok {
+			return "", fmt.Errorf("token id %d is not in the vocabulary", id)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if p.decoder == nil {
+		return strings.Join(tokens, " "), nil
+	}
+	return strings.Join(p.decoder.decodeChain(tokens), ""), nil
+}
@@ -0,0 +1,144 @@
+package tokenizer
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestDecoders(t *testing.T) {
+	tests := []struct {
+		name    string
+		decoder decoder
+		tokens  []string
+		want    []string
+	}{
+		{
+			name:    "wordpiece",
+			decoder: wordPieceDecoder{prefix: "##", cleanup: true},
+			tokens:  []string{"un", "##aff", "##able", "isn", "'", "t", "it", "?", "don", "'", "t", ",", "ok"},
+			want:    []string{"un", "aff", "able", " isn", " '", " t", " it", "?", " don", " '", " t", ",", " ok"},
+		},
+		{
+			name:    "whole text cleanup",
+			decoder: decoderSequence{wordPieceDecoder{prefix: "##"}, cleanupDecoder{}},
+			tokens:  []string{"isn", "'", "t", "it", "?", "don", "'", "t", ",", "ok", "."},
+			want:    []string{"isn't it? don't, ok."},
+		},
+		{
+			name:    "wordpiece without cleanup",
+			decoder: wordPieceDecoder{prefix: "##"},
+			tokens:  []string{"hello", "##s", "!"},
+			want:    []string{"hello", "s", " !"},
+		},
+		{
+			name:    "bpe suffix",
+			decoder: bpeDecoder{suffix: "</w>"},
+			tokens:  []string{"hel", "lo</w>", "world</w>"},
+			want:    []string{"hel", "lo ", "world"},
+		},
+		{
+			name:    "fuse",
+			decoder: fuseDecoder{},
+			tokens:  []string{"a", "b", "c"},
+			want:    []string{"abc"},
+		},
+		{
+			name:    "strip",
+			decoder: stripDecoder{content: " ", start: 1},
+			tokens:  []string{"  a", "b "},
+			want:    []string{" a", "b "},
+		},
+		{
+			name:    "replace",
+			decoder: replaceDecoder{pattern: regexp.MustCompile("▁"), content: " "},
+			tokens:  []string{"▁hello", "▁world"},
+			want:    []string{" hello", " world"},
+		},
+		{
+			name: "llama sequence",
+			decoder: decoderSequence{
+				replaceDecoder{pattern: regexp.MustCompile("▁"), content: " "},
+				byteFallbackDecoder{},
+				fuseDecoder{},
+				stripDecoder{content: " ", start: 1},
+			},
+			tokens: []string{"▁h", "<0xC3>", "<0xA9>", "llo", "▁world"},
+			want:   []string{"héllo world"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.decoder.decodeChain(tt.tokens); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeChain(%q) = %q, want %q", tt.tokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBERTTokenizerDecode(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	tests := []struct {
+		text        string
+		maxLength   int
+		skipSpecial bool
+		want        string
+	}{
+		{text: "Hello, world! How are you?", skipSpecial: true, want: "hello, world! how are you?"},
+		{text: "It's unaffable, isn't it.", skipSpecial: true, want: "it's unaffable, isn't it."},
+		{text: "the [MASK] sat", maxLength: 8, skipSpecial: true, want: "the sat"},
+		{text: "the [MASK] sat", maxLength: 8, want: "[CLS] the [MASK] sat [SEP] [PAD] [PAD] [PAD]"},
+	}
+
+	for _, tt := range tests {
+		out, err := tok.Encode(tt.text, tt.maxLength)
+		if err != nil {
+			t.Fatalf("Encode(%q) error = %v", tt.text, err)
+		}
+		got, err := tok.Decode(out.InputIds, tt.skipSpecial)
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Decode(Encode(%q)) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	if _, err := tok.Decode([]int64{1 << 40}, false); err == nil {
+		t.Error("Decode() with an unknown id succeeded")
+	}
+}
+
+func TestHuggingFaceTokenizerDecode(t *testing.T) {
+	tests := []struct {
+		file string
+		text string
+		want string
+	}{
+		{file: "bert.json", text: "Hello, worlds! UNAFFABLE", want: "hello, worlds! unaffable"},
+		{file: "roberta.json", text: "hello world", want: "hello world"},
+		{file: "unigram.json", text: "Ab  c", want: "ab c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			tok := loadTestTokenizer(t, tt.file)
+			out, err := tok.Encode(tt.text, 0)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			got, err := tok.Decode(out.InputIds, true)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decode(Encode(%q)) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1014 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// HuggingFaceTokenizer is a tokenizer loaded from a Hugging Face tokenizer.json file
+type HuggingFaceTokenizer struct {
+	pipeline      *pipeline
+	specialTokens SpecialTokens
+	labels        map[int]string
+}
+
+// LoadHuggingFaceTokenizer loads a tokenizer from a tokenizer.json file
+func LoadHuggingFaceTokenizer(path string) (*HuggingFaceTokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokenizer file: %w", err)
+	}
+	defer f.Close()
+	return NewHuggingFaceTokenizer(f)
+}
+
+// NewHuggingFaceTokenizer reads a tokenizer in the tokenizer.json format
+func NewHuggingFaceTokenizer(r io.Reader) (*HuggingFaceTokenizer, error) {
+	var file hfFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode tokenizer: %w", err)
+	}
+
+	p := &pipeline{processor: concatProcessing{}}
+	var err error
+	if p.normalizer, err = parseNormalizer(file.Normalizer); err != nil {
+		return nil, fmt.Errorf("failed to parse normalizer: %w", err)
+	}
+	if p.preTokenizer, err = parsePreTokenizer(file.PreTokenizer); err != nil {
+		return nil, fmt.Errorf("failed to parse pre-tokenizer: %w", err)
+	}
+	if p.model, err = parseModel(file.Model); err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+	if processor, err := parsePostProcessor(file.PostProcessor); err != nil {
+		return nil, fmt.Errorf("failed to parse post-processor: %w", err)
+	} else if processor != nil {
+		p.processor = processor
+	}
+	if p.decoder, err = parseDecoder(file.Decoder); err != nil {
+		return nil, fmt.Errorf("failed to parse decoder: %w", err)
+	}
+	p.trimOffsets = trimsOffsets(file.PostProcessor)
+	if file.Truncation != nil {
+		if err := file.Truncation.apply(&p.defaults); err != nil {
+			return nil, fmt.Errorf("failed to parse truncation: %w", err)
+		}
+	}
+
+	added := make([]addedToken, len(file.AddedTokens))
+	for i, t := range file.AddedTokens {
+		added[i] = addedToken{
+			id:         t.ID,
+			content:    t.Content,
+			singleWord: t.SingleWord,
+			lstrip:     t.LStrip,
+			rstrip:     t.RStrip,
+			normalized: t.Normalized,
+			special:    t.Special,
+		}
+	}
+	p.added = newAddedVocabulary(added, p.normalizer)
+
+	t := &HuggingFaceTokenizer{pipeline: p}
+	t.specialTokens = t.findSpecialTokens(file.Padding)
+	if file.Padding != nil {
+		p.padToken = specialToken{value: file.Padding.PadToken, id: file.Padding.PadID}
+		p.padTypeID = file.Padding.PadTypeID
+		if err := file.Padding.apply(&p.defaults); err != nil {
+			return nil, fmt.Errorf("failed to parse padding: %w", err)
+		}
+	} else if id, ok := p.tokenToID(t.specialTokens.PAD); ok {
+		p.padToken = specialToken{value: t.specialTokens.PAD, id: id}
+	}
+
+	t.labels = make(map[int]string, p.vocabSize())
+	for id := range int64(p.vocabSize()) {
+		if token, ok := p.idToToken(id); ok {
+			t.labels[int(id)] = token
+		}
+	}
+	for _, a := range p.added.tokens {
+		t.labels[int(a.id)] = a.content
+	}
+	return t, nil
+}
+
+// Encode tokenizes text with the special tokens of the model. When maxLength is
+// positive the text is truncated, keeping the special tokens, and the output is
+// padded to exactly maxLength tokens. When it is 0 the text is truncated to the
+// max_length of the truncation stored in the file, if any, without padding. opts
+// configure truncation and overflow.
+func (t *HuggingFaceTokenizer) Encode(text string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encode(text, maxLength, opts...)
+}
+
+// EncodePair tokenizes a pair of texts, such as a question and a context, with
+// the special tokens of the model. When maxLength is positive the longer text is
+// truncated first, unless opts select another strategy, and the output is padded
+// to exactly maxLength tokens.
+func (t *HuggingFaceTokenizer) EncodePair(a, b string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encodePair(a, b, maxLength, opts...)
+}
+
+// EncodeBatch tokenizes texts concurrently like Encode and pads them to the
+// longest encoding of the batch, unless opts select another padding strategy
+func (t *HuggingFaceTokenizer) EncodeBatch(texts []string, maxLength int, opts ...EncodeOption) (*BatchOutput, error) {
+	return t.pipeline.encodeBatch(texts, maxLength, opts...)
+}
+
+// Decode converts ids back to text with the decoder of the file, or by joining
+// the tokens with spaces when it has none. Special tokens are dropped when
+// skipSpecial is set.
+func (t *HuggingFaceTokenizer) Decode(ids []int64, skipSpecial bool) (string, error) {
+	return t.pipeline.decode(ids, skipSpecial)
+}
+
+// TokenToID returns the id of a token
+func (t *HuggingFaceTokenizer) TokenToID(token string) (int64, bool) {
+	return t.pipeline.tokenToID(token)
+}
+
+// IDToToken returns the token of an id
+func (t *HuggingFaceTokenizer) IDToToken(id int64) (string, bool) {
+	return t.pipeline.idToToken(id)
+}
+
+// VocabSize returns the number of tokens including added tokens
+func (t *HuggingFaceTokenizer) VocabSize() int {
+	return t.pipeline.vocabSize()
+}
+
+// SpecialTokens returns the special tokens of the tokenizer. Tokens the model
+// does not use are empty.
+func (t *HuggingFaceTokenizer) SpecialTokens() SpecialTokens {
+	return t.specialTokens
+}
+
+// Labels returns the tokens of the vocabulary by id
+func (t *HuggingFaceTokenizer) Labels() map[int]string {
+	return t.labels
+}
+
+// findSpecialTokens derives the special tokens from the post-processor, the
+// padding configuration and the conventional token names in the vocabulary
+func (t *HuggingFaceTokenizer) findSpecialTokens(padding *hfPadding) SpecialTokens {
+	p := t.pipeline
+	var special SpecialTokens
+
+	switch processor := p.processor.(type) {
+	case bertProcessing:
+		special.CLS, special.SEP = processor.cls.value, processor.sep.value
+	case robertaProcessing:
+		special.CLS, special.SEP = processor.cls.value, processor.sep.value
+	case templateProcessing:
+		special.CLS, special.SEP = templateSpecialTokens(processor.single)
+	case processorSequence:
+		if template, ok := processor.main().(templateProcessing); ok {
+			special.CLS, special.SEP = templateSpecialTokens(template.single)
+		}
+	}
+
+	switch m := p.model.(type) {
+	case *wordPieceModel:
+		special.UNK = m.unk
+	case *wordLevelModel:
+		special.UNK = m.unk
+	case *bpeModel:
+		special.UNK = m.unk
+	case *unigramModel:
+		if m.hasUnk {
+			special.UNK = m.tokens[m.unkID]
+		}
+	}
+
+	if padding != nil {
+		special.PAD = padding.PadToken
+	}
+	first := func(candidates ...string) string {
+		for _, c := range candidates {
+			if _, ok := p.tokenToID(c); ok {
+				return c
+			}
+		}
+		return ""
+	}
+	if special.PAD == "" {
+		special.PAD = first("[PAD]", "<pad>")
+	}
+	if special.CLS == "" {
+		special.CLS = first("[CLS]", "<s>")
+	}
+	if special.SEP == "" {
+		special.SEP = first("[SEP]", "</s>")
+	}
+	special.MASK = first("[MASK]", "<mask>")
+	return special
+}
+
+// templateSpecialTokens returns the last special token before and the first
+// special token after the first sequence of a template
+func templateSpecialTokens(template []templatePiece) (cls, sep string) {
+	seen := false
+	for _, piece := range template {
+		if piece.sequence >= 0 {
+			seen = true
+			continue
+		}
+		if len(piece.special) == 0 {
+			continue
+		}
+		if !seen {
+			cls = piece.special[len(piece.special)-1].value
+		} else if sep == "" {
+			sep = piece.special[0].value
+		}
+	}
+	return cls, sep
+}
+
+type hfFile struct {
+	AddedTokens   []hfAddedToken  `json:"added_tokens"`
+	Normalizer    json.RawMessage `json:"normalizer"`
+	PreTokenizer  json.RawMessage `json:"pre_tokenizer"`
+	Model         json.RawMessage `json:"model"`
+	PostProcessor json.RawMessage `json:"post_processor"`
+	Decoder       json.RawMessage `json:"decoder"`
+	Padding       *hfPadding      `json:"padding"`
+	Truncation    *hfTruncation   `json:"truncation"`
+}
+
+type hfAddedToken struct {
+	ID         int64  `json:"id"`
+	Content    string `json:"content"`
+	SingleWord bool   `json:"single_word"`
+	LStrip     bool   `json:"lstrip"`
+	RStrip     bool   `json:"rstrip"`
+	Normalized bool   `json:"normalized"`
+	Special    bool   `json:"special"`
+}
+
+type hfPadding struct {
+	Direction       string `json:"direction"`
+	PadToMultipleOf *int   `json:"pad_to_multiple_of"`
+	PadID           int64  `json:"pad_id"`
+	PadTypeID       int64  `json:"pad_type_id"`
+	PadToken        string `json:"pad_token"`
+}
+
+type hfTruncation struct {
+	Direction string `json:"direction"`
+	MaxLength int    `json:"max_length"`
+	Strategy  string `json:"strategy"`
+	Stride    int    `json:"stride"`
+}
+
+// apply sets the truncation stored in the file as default options. The maximum
+// length applies when Encode is called with a maxLength of 0, and the stride to
+// WithOverflow with a negative stride.
+func (t *hfTruncation) apply(o *encodeOptions) error {
+	if t.MaxLength < 0 || t.Stride < 0 {
+		return fmt.Errorf("invalid max length %d or stride %d", t.MaxLength, t.Stride)
+	}
+	o.maxLength, o.stride = t.MaxLength, t.Stride
+	var err error
+	if o.side, err = parseSide(t.Direction); err != nil {
+		return fmt.Errorf("unsupported truncation direction %q", t.Direction)
+	}
+	switch t.Strategy {
+	case "", "LongestFirst":
+		o.strategy = LongestFirst
+	case "OnlyFirst":
+		o.strategy = OnlyFirst
+	case "OnlySecond":
+		o.strategy = OnlySecond
+	default:
+		return fmt.Errorf("unsupported truncation strategy %q", t.Strategy)
+	}
+	return nil
+}
+
+// apply sets the padding side and multiple stored in the file as default
+// options. The padding length is chosen per call.
+func (p *hfPadding) apply(o *encodeOptions) error {
+	var err error
+	if o.padSide, err = parseSide(p.Direction); err != nil {
+		return fmt.Errorf("unsupported padding direction %q", p.Direction)
+	}
+	if p.PadToMultipleOf != nil {
+		o.multiple = *p.PadToMultipleOf
+	}
+	return nil
+}
+
+func parseSide(direction string) (Side, error) {
+	switch direction {
+	case "", "Right":
+		return SideRight, nil
+	case "Left":
+		return SideLeft, nil
+	}
+	return SideRight, fmt.Errorf("unknown side %q", direction)
+}
+
+// hfPattern is a split pattern given either as a literal string or a regex
+type hfPattern struct {
+	String *string `json:"String"`
+	Regex  *string `json:"Regex"`
+}
+
+func (p hfPattern) compile() (*regexp.Regexp, error) {
+	switch {
+	case p.String != nil:
+		return regexp.MustCompile(regexp.QuoteMeta(*p.String)), nil
+	case p.Regex != nil:
+		re, err := regexp.Compile(*p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported pattern %q: %w", *p.Regex, err)
+		}
+		return re, nil
+	}
+	return nil, fmt.Errorf("pattern has neither a string nor a regex")
+}
+
+// matcher returns a function finding the matches of the pattern, with a
+// hand-written matcher for the GPT-2 pattern
+func (p hfPattern) matcher() (func(s string) [][2]int, error) {
+	if p.Regex != nil && *p.Regex == gpt2Pattern {
+		return gpt2Matches, nil
+	}
+	re, err := p.compile()
+	if err != nil {
+		return nil, err
+	}
+	return func(s string) [][2]int {
+		return regexpMatches(re, s)
+	}, nil
+}
+
+// isNull reports whether a component is absent from the file
+func isNull(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
+
+// componentType returns the "type" field of a component
+func componentType(raw json.RawMessage) (string, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return "", err
+	}
+	return header.Type, nil
+}
+
+func parseNormalizer(raw json.RawMessage) (normalizer, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	kind, err := componentType(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "BertNormalizer":
+		config := struct {
+			CleanText          *bool `json:"clean_text"`
+			HandleChineseChars *bool `json:"handle_chinese_chars"`
+			StripAccents       *bool `json:"strip_accents"`
+			Lowercase          *bool `json:"lowercase"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		b := bertNormalizer{
+			cleanText:          boolOr(config.CleanText, true),
+			handleChineseChars: boolOr(config.HandleChineseChars, true),
+			lowercase:          boolOr(config.Lowercase, true),
+		}
+		// accents are stripped with lowercasing unless configured explicitly
+		b.stripAccents = boolOr(config.StripAccents, b.lowercase)
+		return b, nil
+	case "Lowercase":
+		return lowercase{}, nil
+	case "StripAccents":
+		return stripAccents{}, nil
+	case "NFC":
+		return unicodeNormalizer{form: norm.NFC}, nil
+	case "NFD":
+		return unicodeNormalizer{form: norm.NFD}, nil
+	case "NFKC":
+		return unicodeNormalizer{form: norm.NFKC}, nil
+	case "NFKD":
+		return unicodeNormalizer{form: norm.NFKD}, nil
+	case "Nmt":
+		return nmt{}, nil
+	case "Strip":
+		config := struct {
+			Left  bool `json:"strip_left"`
+			Right bool `json:"strip_right"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		return strip{left: config.Left, right: config.Right}, nil
+	case "Prepend":
+		config := struct {
+			Prepend string `json:"prepend"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		return prepend{prefix: config.Prepend}, nil
+	case "Replace":
+		config := struct {
+			Pattern hfPattern `json:"pattern"`
+			Content string    `json:"content"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		pattern, err := config.Pattern.compile()
+		if err != nil {
+			return nil, err
+		}
+		return replace{pattern: pattern, content: config.Content}, nil
+	case "Precompiled":
+		config := struct {
+			CharsMap *string `json:"precompiled_charsmap"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		if config.CharsMap == nil {
+			return &precompiled{}, nil
+		}
+		charsMap, err := base64.StdEncoding.DecodeString(*config.CharsMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode precompiled charsmap: %w", err)
+		}
+		return newPrecompiled(charsMap)
+	case "Sequence":
+		config := struct {
+			Normalizers []json.RawMessage `json:"normalizers"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		var sequence normalizerSequence
+		for _, child := range config.Normalizers {
+			n, err := parseNormalizer(child)
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				sequence = append(sequence, n)
+			}
+		}
+		return sequence, nil
+	}
+	return nil, fmt.Errorf("unsupported normalizer type %q", kind)
+}
+
+func parsePreTokenizer(raw json.RawMessage) (preTokenizer, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	kind, err := componentType(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "BertPreTokenizer":
+		return bertPreTokenizer{}, nil
+	case "Whitespace":
+		return whitespacePreTokenizer{}, nil
+	case "WhitespaceSplit":
+		return whitespaceSplit{}, nil
+	case "Punctuation":
+		config := struct {
+			Behavior string `json:"behavior"`
+		}{Behavior: "Isolated"}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		behavior, err := parseSplitBehavior(config.Behavior)
+		if err != nil {
+			return nil, err
+		}
+		return punctuation{behavior: behavior}, nil
+	case "Digits":
+		config := struct {
+			Individual bool `json:"individual_digits"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		return digits{individual: config.Individual}, nil
+	case "CharDelimiterSplit":
+		config := struct {
+			Delimiter string `json:"delimiter"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		runes := []rune(config.Delimiter)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("delimiter %q is not a single character", config.Delimiter)
+		}
+		return charDelimiterSplit{delimiter: runes[0]}, nil
+	case "Split":
+		config := struct {
+			Pattern  hfPattern `json:"pattern"`
+			Behavior string    `json:"behavior"`
+			Invert   bool      `json:"invert"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		behavior, err := parseSplitBehavior(config.Behavior)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := config.Pattern.matcher()
+		if err != nil {
+			return nil, err
+		}
+		return patternSplit{matches: matches, behavior: behavior, invert: config.Invert}, nil
+	case "Metaspace":
+		config := struct {
+			Replacement    string  `json:"replacement"`
+			PrependScheme  *string `json:"prepend_scheme"`
+			AddPrefixSpace *bool   `json:"add_prefix_space"`
+			Split          *bool   `json:"split"`
+		}{Replacement: metaspaceReplacement}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		prepend, err := parsePrependScheme(config.PrependScheme, config.AddPrefixSpace)
+		if err != nil {
+			return nil, err
+		}
+		return metaspace{replacement: config.Replacement, prepend: prepend, split: boolOr(config.Split, true)}, nil
+	case "ByteLevel":
+		config := struct {
+			AddPrefixSpace bool  `json:"add_prefix_space"`
+			UseRegex       *bool `json:"use_regex"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		return byteLevel{addPrefixSpace: config.AddPrefixSpace, useRegex: boolOr(config.UseRegex, true)}, nil
+	case "Sequence":
+		config := struct {
+			PreTokenizers []json.RawMessage `json:"pretokenizers"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		var sequence preTokenizerSequence
+		for _, child := range config.PreTokenizers {
+			p, err := parsePreTokenizer(child)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil {
+				sequence = append(sequence, p)
+			}
+		}
+		return sequence, nil
+	}
+	return nil, fmt.Errorf("unsupported pre-tokenizer type %q", kind)
+}
+
+func parseSplitBehavior(behavior string) (splitBehavior, error) {
+	switch behavior {
+	case "Removed":
+		return splitRemoved, nil
+	case "Isolated":
+		return splitIsolated, nil
+	case "MergedWithPrevious":
+		return splitMergedWithPrevious, nil
+	case "MergedWithNext":
+		return splitMergedWithNext, nil
+	case "Contiguous":
+		return splitContiguous, nil
+	}
+	return 0, fmt.Errorf("unsupported split behavior %q", behavior)
+}
+
+// parsePrependScheme returns the Metaspace prepend scheme, given either directly
+// or by the older add_prefix_space flag
+func parsePrependScheme(scheme *string, addPrefixSpace *bool) (prependScheme, error) {
+	switch {
+	case scheme != nil:
+		switch *scheme {
+		case "always":
+			return prependAlways, nil
+		case "first":
+			return prependFirst, nil
+		case "never":
+			return prependNever, nil
+		}
+		return prependAlways, fmt.Errorf("unsupported prepend scheme %q", *scheme)
+	case addPrefixSpace != nil && !*addPrefixSpace:
+		return prependNever, nil
+	}
+	return prependAlways, nil
+}
+
+func parseModel(raw json.RawMessage) (model, error) {
+	if isNull(raw) {
+		return nil, fmt.Errorf("tokenizer has no model")
+	}
+	config := struct {
+		Type                    string            `json:"type"`
+		Vocab                   json.RawMessage   `json:"vocab"`
+		Merges                  []json.RawMessage `json:"merges"`
+		UnkToken                *string           `json:"unk_token"`
+		UnkID                   *int64            `json:"unk_id"`
+		ContinuingSubwordPrefix *string           `json:"continuing_subword_prefix"`
+		EndOfWordSuffix         *string           `json:"end_of_word_suffix"`
+		MaxInputCharsPerWord    int               `json:"max_input_chars_per_word"`
+		FuseUnk                 bool              `json:"fuse_unk"`
+		ByteFallback            bool              `json:"byte_fallback"`
+		IgnoreMerges            bool              `json:"ignore_merges"`
+	}{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+
+	kind := config.Type
+	if kind == "" {
+		// files written by older versions of the library omit the model type
+		switch {
+		case config.Merges != nil:
+			kind = "BPE"
+		case bytes.HasPrefix(bytes.TrimSpace(config.Vocab), []byte("[")):
+			kind = "Unigram"
+		case config.ContinuingSubwordPrefix != nil:
+			kind = "WordPiece"
+		default:
+			kind = "WordLevel"
+		}
+	}
+
+	if kind == "Unigram" {
+		var entries [][2]json.RawMessage
+		if err := json.Unmarshal(config.Vocab, &entries); err != nil {
+			return nil, fmt.Errorf("failed to decode vocabulary: %w", err)
+		}
+		pieces := make([]unigramPiece, len(entries))
+		for i, entry := range entries {
+			if err := json.Unmarshal(entry[0], &pieces[i].piece); err != nil {
+				return nil, fmt.Errorf("failed to decode piece %d: %w", i, err)
+			}
+			if err := json.Unmarshal(entry[1], &pieces[i].score); err != nil {
+				return nil, fmt.Errorf("failed to decode score of piece %d: %w", i, err)
+			}
+			pieces[i].matchable = true
+		}
+		unkID := int64(-1)
+		if config.UnkID != nil {
+			unkID = *config.UnkID
+		}
+		return newUnigramModel(pieces, unkID, config.ByteFallback)
+	}
+
+	ids := make(map[string]int64)
+	if err := json.Unmarshal(config.Vocab, &ids); err != nil {
+		return nil, fmt.Errorf("failed to decode vocabulary: %w", err)
+	}
+	vocab := newVocabulary(ids)
+
+	switch kind {
+	case "WordPiece":
+		prefix := "##"
+		if config.ContinuingSubwordPrefix != nil {
+			prefix = *config.ContinuingSubwordPrefix
+		}
+		return newWordPieceModel(vocab, stringOr(config.UnkToken, "[UNK]"), prefix, config.MaxInputCharsPerWord)
+	case "WordLevel":
+		return newWordLevelModel(vocab, stringOr(config.UnkToken, "<unk>"))
+	case "BPE":
+		merges := make([][2]string, len(config.Merges))
+		for i, merge := range config.Merges {
+			pair, err := parseMerge(merge)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode merge %d: %w", i, err)
+			}
+			merges[i] = pair
+		}
+		m, err := newBPEModel(vocab, merges, stringOr(config.ContinuingSubwordPrefix, ""))
+		if err != nil {
+			return nil, err
+		}
+		m.suffix = stringOr(config.EndOfWordSuffix, "")
+		m.fuseUnk = config.FuseUnk
+		m.byteFallback = config.ByteFallback
+		m.ignoreMerges = config.IgnoreMerges
+		if config.UnkToken != nil {
+			if err := m.setUnknown(*config.UnkToken); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported model type %q", kind)
+}
+
+// parseMerge decodes a merge written as "a b" or as ["a", "b"]
+func parseMerge(raw json.RawMessage) ([2]string, error) {
+	var pair [2]string
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		a, b, ok := strings.Cut(s, " ")
+		if !ok {
+			return pair, fmt.Errorf("merge %q is not a pair", s)
+		}
+		return [2]string{a, b}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return pair, err
+	}
+	if len(list) != 2 {
+		return pair, fmt.Errorf("merge has %d parts", len(list))
+	}
+	return [2]string{list[0], list[1]}, nil
+}
+
+func parseDecoder(raw json.RawMessage) (decoder, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	kind, err := componentType(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "WordPiece":
+		config := struct {
+			Prefix  *string `json:"prefix"`
+			Cleanup *bool   `json:"cleanup"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		return wordPieceDecoder{prefix: stringOr(config.Prefix, "##"), cleanup: boolOr(config.Cleanup, true)}, nil
+	case "BPEDecoder":
+		config := struct {
+			Suffix *string `json:"suffix"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		return bpeDecoder{suffix: stringOr(config.Suffix, "</w>")}, nil
+	case "ByteLevel":
+		return byteLevelDecoder{}, nil
+	case "ByteFallback":
+		return byteFallbackDecoder{}, nil
+	case "Fuse":
+		return fuseDecoder{}, nil
+	case "Metaspace":
+		config := struct {
+			Replacement    string  `json:"replacement"`
+			PrependScheme  *string `json:"prepend_scheme"`
+			AddPrefixSpace *bool   `json:"add_prefix_space"`
+		}{Replacement: metaspaceReplacement}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		prepend, err := parsePrependScheme(config.PrependScheme, config.AddPrefixSpace)
+		if err != nil {
+			return nil, err
+		}
+		return metaspaceDecoder{replacement: config.Replacement, prepend: prepend}, nil
+	case "Strip":
+		config := struct {
+			Content string `json:"content"`
+			Start   int    `json:"start"`
+			Stop    int    `json:"stop"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		return stripDecoder{content: config.Content, start: config.Start, stop: config.Stop}, nil
+	case "Replace":
+		config := struct {
+			Pattern hfPattern `json:"pattern"`
+			Content string    `json:"content"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		pattern, err := config.Pattern.compile()
+		if err != nil {
+			return nil, err
+		}
+		return replaceDecoder{pattern: pattern, content: config.Content}, nil
+	case "Sequence":
+		config := struct {
+			Decoders []json.RawMessage `json:"decoders"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		var sequence decoderSequence
+		for _, child := range config.Decoders {
+			d, err := parseDecoder(child)
+			if err != nil {
+				return nil, err
+			}
+			if d != nil {
+				sequence = append(sequence, d)
+			}
+		}
+		return sequence, nil
+	}
+	return nil, fmt.Errorf("unsupported decoder type %q", kind)
+}
+
+func parsePostProcessor(raw json.RawMessage) (postProcessor, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	kind, err := componentType(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "BertProcessing", "RobertaProcessing":
+		config := struct {
+			Sep [2]json.RawMessage `json:"sep"`
+			Cls [2]json.RawMessage `json:"cls"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		sep, err := parseSpecialToken(config.Sep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sep token: %w", err)
+		}
+		cls, err := parseSpecialToken(config.Cls)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cls token: %w", err)
+		}
+		if kind == "BertProcessing" {
+			return bertProcessing{cls: cls, sep: sep}, nil
+		}
+		return robertaProcessing{cls: cls, sep: sep}, nil
+	case "TemplateProcessing":
+		return parseTemplate(raw)
+	case "ByteLevel":
+		return concatProcessing{}, nil
+	case "Sequence":
+		config := struct {
+			Processors []json.RawMessage `json:"processors"`
+		}{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		var sequence processorSequence
+		for _, child := range config.Processors {
+			p, err := parsePostProcessor(child)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil {
+				sequence = append(sequence, p)
+			}
+		}
+		return sequence, nil
+	}
+	return nil, fmt.Errorf("unsupported post-processor type %q", kind)
+}
+
+// trimsOffsets reports whether a byte-level or RoBERTa post-processor removes
+// whitespace from token offsets
+func trimsOffsets(raw json.RawMessage) bool {
+	if isNull(raw) {
+		return false
+	}
+	config := struct {
+		Type        string            `json:"type"`
+		TrimOffsets *bool             `json:"trim_offsets"`
+		Processors  []json.RawMessage `json:"processors"`
+	}{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return false
+	}
+	switch config.Type {
+	case "ByteLevel", "RobertaProcessing":
+		return boolOr(config.TrimOffsets, true)
+	case "Sequence":
+		for _, child := range config.Processors {
+			if trimsOffsets(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseSpecialToken(pair [2]json.RawMessage) (specialToken, error) {
+	var t specialToken
+	if err := json.Unmarshal(pair[0], &t.value); err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(pair[1], &t.id); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+func parseTemplate(raw json.RawMessage) (templateProcessing, error) {
+	type item struct {
+		SpecialToken *struct {
+			ID     string `json:"id"`
+			TypeID int64  `json:"type_id"`
+		} `json:"SpecialToken"`
+		Sequence *struct {
+			ID     string `json:"id"`
+			TypeID int64  `json:"type_id"`
+		} `json:"Sequence"`
+	}
+	config := struct {
+		Single        []item `json:"single"`
+		Pair          []item `json:"pair"`
+		SpecialTokens map[string]struct {
+			ID     string   `json:"id"`
+			IDs    []int64  `json:"ids"`
+			Tokens []string `json:"tokens"`
+		} `json:"special_tokens"`
+	}{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return templateProcessing{}, err
+	}
+
+	convert := func(items []item) ([]templatePiece, error) {
+		pieces := make([]templatePiece, 0, len(items))
+		for _, it := range items {
+			switch {
+			case it.Sequence != nil:
+				piece := templatePiece{sequence: 0, typeID: it.Sequence.TypeID}
+				if it.Sequence.ID == "B" {
+					piece.sequence = 1
+				}
+				pieces = append(pieces, piece)
+			case it.SpecialToken != nil:
+				special, ok := config.SpecialTokens[it.SpecialToken.ID]
+				if !ok {
+					return nil, fmt.Errorf("template uses undefined special token %q", it.SpecialToken.ID)
+				}
+				if len(special.IDs) != len(special.Tokens) {
+					return nil, fmt.Errorf("special token %q has %d ids and %d tokens", it.SpecialToken.ID, len(special.IDs), len(special.Tokens))
+				}
+				piece := templatePiece{sequence: -1, typeID: it.SpecialToken.TypeID}
+				for i := range special.IDs {
+					piece.special = append(piece.special, specialToken{value: special.Tokens[i], id: special.IDs[i]})
+				}
+				pieces = append(pieces, piece)
+			default:
+				return nil, fmt.Errorf("template item is neither a sequence nor a special token")
+			}
+		}
+		return pieces, nil
+	}
+
+	single, err := convert(config.Single)
+	if err != nil {
+		return templateProcessing{}, fmt.Errorf("invalid single template: %w", err)
+	}
+	pair, err := convert(config.Pair)
+	if err != nil {
+		return templateProcessing{}, fmt.Errorf("invalid pair template: %w", err)
+	}
+	return templateProcessing{single: single, pair: pair}, nil
+}
+
+func boolOr(b *bool, fallback bool) bool {
+	if b == nil {
+		return fallback
+	}
+	return *b
+}
+
+func stringOr(s *string, fallback string) string {
+	if s == nil {
+		return fallback
+	}
+	return *s
+}
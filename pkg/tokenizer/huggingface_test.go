@@ -0,0 +1,334 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadTestTokenizer(t *testing.T, name string) *HuggingFaceTokenizer {
+	t.Helper()
+	tok, err := LoadHuggingFaceTokenizer(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", name, err)
+	}
+	return tok
+}
+
+func TestHuggingFaceTokenizer(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		text       string
+		maxLength  int
+		wantIDs    []int64
+		wantTokens []string
+		wantMask   []int64
+	}{
+		{
+			name:       "wordpiece",
+			file:       "bert.json",
+			text:       "Hello, worlds! [MASK]",
+			wantIDs:    []int64{2, 5, 11, 6, 7, 12, 4, 3},
+			wantTokens: []string{"[CLS]", "hello", ",", "world", "##s", "!", "[MASK]", "[SEP]"},
+			wantMask:   []int64{1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:       "wordpiece subwords and unknown",
+			file:       "bert.json",
+			text:       "UNAFFABLE xyz",
+			wantIDs:    []int64{2, 8, 9, 10, 1, 3},
+			wantTokens: []string{"[CLS]", "un", "##aff", "##able", "[UNK]", "[SEP]"},
+			wantMask:   []int64{1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:       "wordpiece truncation",
+			file:       "bert.json",
+			text:       "hello, worlds!",
+			maxLength:  5,
+			wantIDs:    []int64{2, 5, 11, 6, 3},
+			wantTokens: []string{"[CLS]", "hello", ",", "world", "[SEP]"},
+			wantMask:   []int64{1, 1, 1, 1, 1},
+		},
+		{
+			name:       "wordpiece padding",
+			file:       "bert.json",
+			text:       "hello",
+			maxLength:  5,
+			wantIDs:    []int64{2, 5, 3, 0, 0},
+			wantTokens: []string{"[CLS]", "hello", "[SEP]", "[PAD]", "[PAD]"},
+			wantMask:   []int64{1, 1, 1, 0, 0},
+		},
+		{
+			name:       "byte-level bpe",
+			file:       "roberta.json",
+			text:       "hello world",
+			wantIDs:    []int64{0, 15, 20, 2},
+			wantTokens: []string{"<s>", "hello", "Ġworld", "</s>"},
+			wantMask:   []int64{1, 1, 1, 1},
+		},
+		{
+			name:       "added token strips whitespace",
+			file:       "roberta.json",
+			text:       "hello <mask>",
+			maxLength:  6,
+			wantIDs:    []int64{0, 15, 21, 2, 1, 1},
+			wantTokens: []string{"<s>", "hello", "<mask>", "</s>", "<pad>", "<pad>"},
+			wantMask:   []int64{1, 1, 1, 1, 0, 0},
+		},
+		{
+			name:       "unigram with precompiled normalizer",
+			file:       "unigram.json",
+			text:       "Ab c",
+			wantIDs:    []int64{5, 2, 7, 1},
+			wantTokens: []string{"▁ab", "▁", "c", "</s>"},
+			wantMask:   []int64{1, 1, 1, 1},
+		},
+		{
+			name:       "unigram unknown",
+			file:       "unigram.json",
+			text:       "ab   xy",
+			wantIDs:    []int64{5, 2, 0, 1},
+			wantTokens: []string{"▁ab", "▁", "<unk>", "</s>"},
+			wantMask:   []int64{1, 1, 1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok := loadTestTokenizer(t, tt.file)
+			got, err := tok.Encode(tt.text, tt.maxLength)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.InputIds, tt.wantIDs) {
+				t.Errorf("InputIds = %v, want %v", got.InputIds, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+			if !reflect.DeepEqual(got.AttentionMask, tt.wantMask) {
+				t.Errorf("AttentionMask = %v, want %v", got.AttentionMask, tt.wantMask)
+			}
+		})
+	}
+}
+
+func TestHuggingFaceTokenizerEncodePair(t *testing.T) {
+	tests := []struct {
+		file        string
+		wantTokens  []string
+		wantTypeIds []int64
+	}{
+		{
+			file:        "bert.json",
+			wantTokens:  []string{"[CLS]", "hello", "[SEP]", "world", "[SEP]"},
+			wantTypeIds: []int64{0, 0, 0, 1, 1},
+		},
+		{
+			file:        "roberta.json",
+			wantTokens:  []string{"<s>", "hello", "</s>", "</s>", "hello", "</s>"},
+			wantTypeIds: []int64{0, 0, 0, 0, 0, 0},
+		},
+		{
+			file:        "unigram.json",
+			wantTokens:  []string{"▁ab", "</s>", "▁", "c", "</s>"},
+			wantTypeIds: []int64{0, 0, 0, 0, 0},
+		},
+	}
+
+	texts := map[string][2]string{
+		"bert.json":    {"hello", "world"},
+		"roberta.json": {"hello", "hello"},
+		"unigram.json": {"ab", "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			tok := loadTestTokenizer(t, tt.file)
+			got, err := tok.EncodePair(texts[tt.file][0], texts[tt.file][1], 0)
+			if err != nil {
+				t.Fatalf("EncodePair() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+			if !reflect.DeepEqual(got.TokenTypeIds, tt.wantTypeIds) {
+				t.Errorf("TokenTypeIds = %v, want %v", got.TokenTypeIds, tt.wantTypeIds)
+			}
+		})
+	}
+}
+
+func TestHuggingFaceTruncationDefaults(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "bert.json"))
+	if err != nil {
+		t.Fatalf("failed to read bert.json: %v", err)
+	}
+	var file map[string]any
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("failed to parse bert.json: %v", err)
+	}
+	file["truncation"] = map[string]any{"direction": "Right", "max_length": 5, "strategy": "LongestFirst", "stride": 1}
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := NewHuggingFaceTokenizer(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewHuggingFaceTokenizer() error = %v", err)
+	}
+
+	// the max_length of the file truncates without padding when no maximum length
+	// is given, and a given one takes precedence
+	out, err := tok.Encode("hello world hello world", 0, WithOverflow(-1))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if want := []string{"[CLS]", "hello", "world", "hello", "[SEP]"}; !reflect.DeepEqual(out.Tokens, want) {
+		t.Errorf("Encode() tokens = %q, want %q", out.Tokens, want)
+	}
+	// windows of three tokens that share the stride of one token, padded to the
+	// length of the first
+	if len(out.Overflowing) != 1 || !reflect.DeepEqual(out.Overflowing[0].Tokens, []string{"[CLS]", "hello", "world", "[SEP]", "[PAD]"}) {
+		t.Errorf("Encode() overflowing = %d windows, want one window sharing one token", len(out.Overflowing))
+	}
+	if out, err = tok.Encode("hello world hello world", 8); err != nil || len(out.Tokens) != 8 || out.Tokens[5] != "[SEP]" {
+		t.Errorf("Encode(8) = %q, %v, want the whole text padded to 8 tokens", out.Tokens, err)
+	}
+}
+
+func TestHuggingFaceSpecialTokens(t *testing.T) {
+	tests := []struct {
+		file          string
+		want          SpecialTokens
+		wantVocabSize int
+	}{
+		{
+			file:          "bert.json",
+			want:          DefaultSpecialTokens(),
+			wantVocabSize: 13,
+		},
+		{
+			file:          "roberta.json",
+			want:          SpecialTokens{PAD: "<pad>", UNK: "", CLS: "<s>", SEP: "</s>", MASK: "<mask>"},
+			wantVocabSize: 22,
+		},
+		{
+			file:          "unigram.json",
+			want:          SpecialTokens{PAD: "<pad>", UNK: "<unk>", CLS: "", SEP: "</s>"},
+			wantVocabSize: 9,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			tok := loadTestTokenizer(t, tt.file)
+			if got := tok.SpecialTokens(); got != tt.want {
+				t.Errorf("SpecialTokens() = %+v, want %+v", got, tt.want)
+			}
+			if got := tok.VocabSize(); got != tt.wantVocabSize {
+				t.Errorf("VocabSize() = %d, want %d", got, tt.wantVocabSize)
+			}
+			for id, token := range tok.Labels() {
+				if got, ok := tok.TokenToID(token); !ok || got != int64(id) {
+					t.Errorf("TokenToID(%q) = %d, %v, want %d", token, got, ok, id)
+				}
+			}
+		})
+	}
+}
+
+func TestHuggingFaceTokenizerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{
+			name: "invalid json",
+			json: `{`,
+			want: "failed to decode tokenizer",
+		},
+		{
+			name: "missing model",
+			json: `{"model": null}`,
+			want: "tokenizer has no model",
+		},
+		{
+			name: "unsupported normalizer",
+			json: `{"normalizer": {"type": "Unknown"}, "model": {"type": "WordLevel", "vocab": {"<unk>": 0}, "unk_token": "<unk>"}}`,
+			want: `unsupported normalizer type "Unknown"`,
+		},
+		{
+			name: "merge outside vocabulary",
+			json: `{"model": {"type": "BPE", "vocab": {"a": 0}, "merges": ["a b"]}}`,
+			want: `token "b" is not in the vocabulary`,
+		},
+		{
+			name: "undefined template token",
+			json: `{"model": {"type": "WordLevel", "vocab": {"<unk>": 0}, "unk_token": "<unk>"}, "post_processor": {"type": "TemplateProcessing", "single": [{"SpecialToken": {"id": "[CLS]", "type_id": 0}}], "pair": [], "special_tokens": {}}}`,
+			want: `undefined special token "[CLS]"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHuggingFaceTokenizer(strings.NewReader(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewHuggingFaceTokenizer() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHuggingFaceTokenizerOffsets(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		text        string
+		wantTokens  []string
+		wantOffsets []Offset
+		wantWords   []int
+	}{
+		{
+			name:       "normalized subwords",
+			file:       "bert.json",
+			text:       "HÉLLO, unaffable worlds",
+			wantTokens: []string{"[CLS]", "hello", ",", "un", "##aff", "##able", "world", "##s", "[SEP]"},
+			wantOffsets: []Offset{
+				{}, {0, 6, 0, 5}, {6, 7, 5, 6}, {8, 10, 7, 9}, {10, 13, 9, 12},
+				{13, 17, 12, 16}, {18, 23, 17, 22}, {23, 24, 22, 23}, {},
+			},
+			wantWords: []int{-1, 0, 1, 2, 2, 2, 3, 3, -1},
+		},
+		{
+			name:        "trimmed byte-level offsets",
+			file:        "roberta.json",
+			text:        "hello world <mask>",
+			wantTokens:  []string{"<s>", "hello", "Ġworld", "<mask>", "</s>"},
+			wantOffsets: []Offset{{}, {0, 5, 0, 5}, {6, 11, 6, 11}, {12, 18, 12, 18}, {}},
+			wantWords:   []int{-1, 0, 1, 2, -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadTestTokenizer(t, tt.file).Encode(tt.text, 0)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+			if !reflect.DeepEqual(got.Offsets, tt.wantOffsets) {
+				t.Errorf("Offsets = %v, want %v", got.Offsets, tt.wantOffsets)
+			}
+			if !reflect.DeepEqual(got.WordIds, tt.wantWords) {
+				t.Errorf("WordIds = %v, want %v", got.WordIds, tt.wantWords)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// token is a token produced by a model with its byte range in the word it came from
+type token struct {
+	id         int64
+	value      string
+	start, end int
+}
+
+// model turns a pre-tokenized word into tokens
+type model interface {
+	tokenize(word string) ([]token, error)
+	tokenToID(token string) (int64, bool)
+	idToToken(id int64) (string, bool)
+	vocabSize() int
+}
+
+// tokenAppender is implemented by models that can tokenize into a reused buffer
+type tokenAppender interface {
+	appendTokens(dst []token, word string) []token
+}
+
+// vocabulary is a bidirectional token to id mapping
+type vocabulary struct {
+	ids    map[string]int64
+	tokens map[int64]string
+}
+
+func newVocabulary(ids map[string]int64) *vocabulary {
+	tokens := make(map[int64]string, len(ids))
+	for token, id := range ids {
+		tokens[id] = token
+	}
+	return &vocabulary{ids: ids, tokens: tokens}
+}
+
+func (v *vocabulary) tokenToID(token string) (int64, bool) {
+	id, ok := v.ids[token]
+	return id, ok
+}
+
+func (v *vocabulary) idToToken(id int64) (string, bool) {
+	token, ok := v.tokens[id]
+	return token, ok
+}
+
+func (v *vocabulary) vocabSize() int {
+	return len(v.ids)
+}
+
+// requireToken returns the id of a token that must be in the vocabulary
+func (v *vocabulary) requireToken(token string) (int64, error) {
+	id, ok := v.ids[token]
+	if !ok {
+		return 0, fmt.Errorf("token %q is not in the vocabulary", token)
+	}
+	return id, nil
+}
+
+// wordPieceModel splits words greedily into the longest pieces in the vocabulary
+type wordPieceModel struct {
+	*vocabulary
+	unk           string
+	unkID         int64
+	prefix        string
+	maxInputChars int
+	// initial matches pieces at the start of a word, continuation the pieces
+	// after it, which are stored without their prefix
+	initial, continuation *trie
+}
+
+func newWordPieceModel(vocab *vocabulary, unk, prefix string, maxInputChars int) (*wordPieceModel, error) {
+	unkID, err := vocab.requireToken(unk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unknown token: %w", err)
+	}
+	if maxInputChars <= 0 {
+		maxInputChars = 100
+	}
+
+	continuation := make(map[string]int64)
+	for token, id := range vocab.ids {
+		if strings.HasPrefix(token, prefix) {
+			continuation[token[len(prefix):]] = id
+		}
+	}
+	return &wordPieceModel{
+		vocabulary:    vocab,
+		unk:           unk,
+		unkID:         unkID,
+		prefix:        prefix,
+		maxInputChars: maxInputChars,
+		initial:       newTrie(vocab.ids, ""),
+		continuation:  newTrie(continuation, prefix),
+	}, nil
+}
+
+func (m *wordPieceModel) tokenize(word string) ([]token, error) {
+	return m.appendTokens(nil, word), nil
+}
+
+// appendTokens appends the pieces of word to dst, or a single unknown token when
+// a part of the word matches no piece
+func (m *wordPieceModel) appendTokens(dst []token, word string) []token {
+	unknown := token{id: m.unkID, value: m.unk, start: 0, end: len(word)}
+	if len(word) > m.maxInputChars && utf8.RuneCountInString(word) > m.maxInputChars {
+		return append(dst, unknown)
+	}
+
+	n := len(dst)
+	pieces := m.initial
+	for start := 0; start < len(word); {
+		node, length := pieces.longestPrefix(word[start:])
+		if node < 0 {
+			return append(dst[:n], unknown)
+		}
+		t := &pieces.nodes[node]
+		dst = append(dst, token{id: t.id, value: t.value, start: start, end: start + length})
+		start += length
+		pieces = m.continuation
+	}
+	return dst
+}
+
+// wordLevelModel maps whole words to ids
+type wordLevelModel struct {
+	*vocabulary
+	unk   string
+	unkID int64
+}
+
+func newWordLevelModel(vocab *vocabulary, unk string) (*wordLevelModel, error) {
+	unkID, err := vocab.requireToken(unk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unknown token: %w", err)
+	}
+	return &wordLevelModel{vocabulary: vocab, unk: unk, unkID: unkID}, nil
+}
+
+func (m *wordLevelModel) tokenize(word string) ([]token, error) {
+	if id, ok := m.ids[word]; ok {
+		return []token{{id: id, value: word, start: 0, end: len(word)}}, nil
+	}
+	return []token{{id: m.unkID, value: m.unk, start: 0, end: len(word)}}, nil
+}
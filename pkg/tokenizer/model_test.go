@@ -0,0 +1,247 @@
+package tokenizer
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// greedyWordPiece is a map based longest-match WordPiece that splits words on
+// character boundaries, kept as a reference for the trie
+func greedyWordPiece(m *wordPieceModel, word string) []token {
+	unknown := []token{{id: m.unkID, value: m.unk, start: 0, end: len(word)}}
+	if utf8.RuneCountInString(word) > m.maxInputChars {
+		return unknown
+	}
+
+	var tokens []token
+	for start := 0; start < len(word); {
+		found := false
+		for end := len(word); end > start; {
+			piece := word[start:end]
+			if start > 0 {
+				piece = m.prefix + piece
+			}
+			if id, ok := m.ids[piece]; ok {
+				tokens = append(tokens, token{id: id, value: piece, start: start, end: end})
+				start = end
+				found = true
+				break
+			}
+			_, size := utf8.DecodeLastRuneInString(word[start:end])
+			end -= size
+		}
+		if !found {
+			return unknown
+		}
+	}
+	return tokens
+}
+
+// corpusWords returns the words of the test corpus as the BERT tokenizer sees
+// them before WordPiece
+func corpusWords(t testing.TB, tok *BERTTokenizer) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "corpus.txt"))
+	if err != nil {
+		t.Fatalf("failed to read corpus: %v", err)
+	}
+	return splitWords(tok, string(data))
+}
+
+// splitWords normalizes and pre-tokenizes text with the BERT tokenizer
+func splitWords(tok *BERTTokenizer, text string) []string {
+	n := newNormalized(text, 0)
+	tok.pipeline.normalizer.normalize(n)
+	var words []string
+	for _, w := range tok.pipeline.preTokenizer.preTokenize([]*normalized{n}) {
+		if w.text != "" {
+			words = append(words, w.text)
+		}
+	}
+	return words
+}
+
+func TestWordPieceTrie(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+	m := tok.pipeline.model.(*wordPieceModel)
+
+	words := append(corpusWords(t, tok), "", "##s", "unaffable", "xqzjv", "ab##cd", strings.Repeat("a", 100), strings.Repeat("a", 101), "é", "\xff")
+	for _, word := range words {
+		got, err := m.tokenize(word)
+		if err != nil {
+			t.Fatalf("tokenize(%q) error = %v", word, err)
+		}
+		if want := greedyWordPiece(m, word); !reflect.DeepEqual(got, want) {
+			t.Errorf("tokenize(%q) = %v, want %v", word, got, want)
+		}
+	}
+}
+
+func TestTrie(t *testing.T) {
+	tr := newTrie(map[string]int64{"a": 1, "ab": 2, "abcd": 3, "b": 4, "": 5}, "##")
+	tests := []struct {
+		s         string
+		wantValue string
+		wantLen   int
+	}{
+		{"abc", "##ab", 2},
+		{"abcd", "##abcd", 4},
+		{"abcde", "##abcd", 4},
+		{"ba", "##b", 1},
+		{"c", "", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		node, length := tr.longestPrefix(tt.s)
+		var value string
+		if node >= 0 {
+			value = tr.nodes[node].value
+		}
+		if value != tt.wantValue || length != tt.wantLen {
+			t.Errorf("longestPrefix(%q) = %q, %d, want %q, %d", tt.s, value, length, tt.wantValue, tt.wantLen)
+		}
+	}
+}
+
+func TestAddedVocabularySplit(t *testing.T) {
+	a := newAddedVocabulary([]addedToken{
+		{id: 1, content: "<s>"},
+		{id: 2, content: "<s><s>"},
+		{id: 3, content: "cat", singleWord: true},
+		{id: 4, content: "ca"},
+		{id: 5, content: "[M]", lstrip: true, rstrip: true},
+	}, nil)
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"<s><s><s>", []string{"<s><s>", "<s>"}},
+		{"a cat", []string{"a ", "cat"}},
+		// the single word token is part of a word, the shorter token matches
+		{"cats", []string{"ca", "ts"}},
+		{"a [M] b", []string{"a", "[M]", "b"}},
+		{"plain", []string{"plain"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, seg := range a.split(newNormalized(tt.text, 0), a.raw) {
+			if seg.added != nil {
+				got = append(got, seg.added.content)
+			} else {
+				got = append(got, seg.text.text)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("split(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkWordPiece(b *testing.B) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		b.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+	m := tok.pipeline.model.(*wordPieceModel)
+	words := splitWords(tok, benchmarkCorpus(b))
+	size := 0
+	for _, w := range words {
+		size += len(w)
+	}
+
+	// the exported WordPiece takes its vocabulary as a map of ints
+	vocab := make(map[string]int, len(m.ids))
+	for piece, id := range m.ids {
+		vocab[piece] = int(id)
+	}
+	special := SpecialTokens{UNK: m.unk}
+
+	b.Run("map", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		for range b.N {
+			for _, w := range words {
+				WordPiece(vocab, special, w)
+			}
+		}
+	})
+	b.Run("trie", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		var dst []token
+		for range b.N {
+			for _, w := range words {
+				dst = m.appendTokens(dst[:0], w)
+			}
+		}
+	})
+}
+
+var corpusFile = flag.String("corpus", "", "text file to benchmark encoding with instead of the repeated test corpus")
+
+// benchmarkCorpus returns the file given with -corpus, or else the test corpus
+// repeated to about 1 MB. The repeated corpus measures the throughput of the
+// encoding pipeline on fewer than two hundred distinct words that stay in the CPU caches,
+// so a real corpus with a realistic vocabulary gives slower, truer numbers.
+func benchmarkCorpus(b *testing.B) string {
+	if *corpusFile != "" {
+		data, err := os.ReadFile(*corpusFile)
+		if err != nil {
+			b.Fatalf("failed to read corpus: %v", err)
+		}
+		return string(data)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "corpus.txt"))
+	if err != nil {
+		b.Fatalf("failed to read corpus: %v", err)
+	}
+	return strings.Repeat(string(data), 1<<20/len(data))
+}
+
+func BenchmarkBERTEncode(b *testing.B) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		b.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+	corpus := benchmarkCorpus(b)
+	lines := strings.Split(corpus, "\n")
+
+	b.Run("document", func(b *testing.B) {
+		b.SetBytes(int64(len(corpus)))
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := tok.Encode(corpus, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("lines", func(b *testing.B) {
+		b.SetBytes(int64(len(corpus)))
+		b.ReportAllocs()
+		for range b.N {
+			for _, line := range lines {
+				if _, err := tok.Encode(line, 128); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.SetBytes(int64(len(corpus)))
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := tok.EncodeBatch(lines, 128); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// span is a byte range [start, end) in the original input text
+type span struct {
+	start, end int
+}
+
+// normalized is a piece of text that remembers, for every byte, which bytes of
+// the original input produced it. Normalizers and pre-tokenizers rewrite and split
+// it so that tokens can be mapped back to the input.
+type normalized struct {
+	text  string
+	spans []span
+}
+
+// newNormalized creates an unmodified normalized string for s, which starts at
+// byte offset base of the original input
+func newNormalized(s string, base int) *normalized {
+	spans := make([]span, len(s))
+	for i := 0; i < len(s); {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		for j := range size {
+			spans[i+j] = span{base + i, base + i + size}
+		}
+		i += size
+	}
+	return &normalized{text: s, spans: spans}
+}
+
+// original returns the original byte range covered by text[start:end]. Empty
+// ranges map to the position of the byte they precede.
+func (n *normalized) original(start, end int) span {
+	if len(n.spans) == 0 {
+		return span{}
+	}
+	if start >= end {
+		if start >= len(n.spans) {
+			last := n.spans[len(n.spans)-1].end
+			return span{last, last}
+		}
+		return span{n.spans[start].start, n.spans[start].start}
+	}
+	return span{n.spans[start].start, n.spans[end-1].end}
+}
+
+// slice returns text[start:end] with its alignment
+func (n *normalized) slice(start, end int) *normalized {
+	return &normalized{text: n.text[start:end], spans: n.spans[start:end]}
+}
+
+// mapRunes replaces every rune with the bytes fn appends for it, which may be
+// none to remove it. The replacement is aligned with the original range of the
+// rune it replaces. Text is only rewritten once fn changes something.
+func (n *normalized) mapRunes(fn func(dst []byte, r rune) []byte) {
+	var scratch [utf8.UTFMax * 3]byte
+	var text []byte
+	var spans []span
+	for i := 0; i < len(n.text); {
+		r, size := utf8.DecodeRuneInString(n.text[i:])
+		out := fn(scratch[:0], r)
+		if text == nil {
+			if string(out) == n.text[i:i+size] {
+				i += size
+				continue
+			}
+			text = make([]byte, i, len(n.text)+len(out))
+			copy(text, n.text[:i])
+			spans = make([]span, i, len(n.spans)+len(out))
+			copy(spans, n.spans[:i])
+		}
+		if string(out) == n.text[i:i+size] {
+			spans = append(spans, n.spans[i:i+size]...)
+		} else {
+			s := n.original(i, i+size)
+			for range len(out) {
+				spans = append(spans, s)
+			}
+		}
+		text = append(text, out...)
+		i += size
+	}
+	if text != nil {
+		n.text = string(text)
+		n.spans = spans
+	}
+}
+
+// normalizeForm applies a Unicode normalization form. Every normalization segment
+// that changes is aligned with the original range of the whole segment.
+func (n *normalized) normalizeForm(form norm.Form) {
+	if form.IsNormalString(n.text) {
+		return
+	}
+
+	var b strings.Builder
+	b.Grow(len(n.text))
+	spans := make([]span, 0, len(n.spans))
+	for start := 0; start < len(n.text); {
+		end := start + form.NextBoundaryInString(n.text[start:], true)
+		if end <= start {
+			end = len(n.text)
+		}
+		segment := n.text[start:end]
+		out := form.String(segment)
+		b.WriteString(out)
+		if out == segment {
+			spans = append(spans, n.spans[start:end]...)
+		} else {
+			s := n.original(start, end)
+			for range len(out) {
+				spans = append(spans, s)
+			}
+		}
+		start = end
+	}
+	n.text = b.String()
+	n.spans = spans
+}
+
+// prepend inserts s before the text, aligned with the start of the first byte
+func (n *normalized) prepend(s string) {
+	at := n.original(0, 0)
+	spans := make([]span, 0, len(s)+len(n.spans))
+	for range len(s) {
+		spans = append(spans, at)
+	}
+	n.text = s + n.text
+	n.spans = append(spans, n.spans...)
+}
+
+// trim removes leading and trailing bytes for which the rune predicate holds
+func (n *normalized) trim(left, right bool, fn func(r rune) bool) {
+	start, end := 0, len(n.text)
+	if left {
+		start = len(n.text) - len(strings.TrimLeftFunc(n.text, fn))
+	}
+	if right {
+		end = len(strings.TrimRightFunc(n.text, fn))
+	}
+	if end < start {
+		end = start
+	}
+	n.text = n.text[start:end]
+	n.spans = n.spans[start:end]
+}
+
+// splitBehavior decides what happens to the delimiters matched by a split
+type splitBehavior int
+
+const (
+	splitRemoved splitBehavior = iota
+	splitIsolated
+	splitMergedWithPrevious
+	splitMergedWithNext
+	splitContiguous
+)
+
+// split cuts the text at the given non-overlapping, sorted delimiter matches.
+// With invert set the matches are the pieces to keep and the text between them
+// acts as the delimiter.
+func (n *normalized) split(matches [][2]int, behavior splitBehavior, invert bool) []*normalized {
+	type piece struct {
+		start, end int
+		match      bool
+	}
+
+	var pieces []piece
+	prev := 0
+	for _, m := range matches {
+		if m[0] > prev {
+			pieces = append(pieces, piece{prev, m[0], invert})
+		}
+		if m[1] > m[0] {
+			pieces = append(pieces, piece{m[0], m[1], !invert})
+		}
+		prev = m[1]
+	}
+	if prev < len(n.text) {
+		pieces = append(pieces, piece{prev, len(n.text), invert})
+	}
+
+	var out []piece
+	switch behavior {
+	case splitRemoved:
+		for _, p := range pieces {
+			if !p.match {
+				out = append(out, p)
+			}
+		}
+	case splitIsolated:
+		out = pieces
+	case splitContiguous:
+		for _, p := range pieces {
+			if len(out) > 0 && p.match && out[len(out)-1].match {
+				out[len(out)-1].end = p.end
+				continue
+			}
+			out = append(out, p)
+		}
+	case splitMergedWithPrevious:
+		for _, p := range pieces {
+			if len(out) > 0 && p.match && !out[len(out)-1].match {
+				out[len(out)-1].end = p.end
+				out[len(out)-1].match = true
+				continue
+			}
+			out = append(out, p)
+		}
+	case splitMergedWithNext:
+		for i := len(pieces) - 1; i >= 0; i-- {
+			p := pieces[i]
+			if len(out) > 0 && p.match && !out[len(out)-1].match {
+				out[len(out)-1].start = p.start
+				out[len(out)-1].match = true
+				continue
+			}
+			out = append(out, p)
+		}
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+
+	result := make([]*normalized, 0, len(out))
+	for _, p := range out {
+		result = append(result, n.slice(p.start, p.end))
+	}
+	return result
+}
+
+// runeMatches returns the byte ranges of the runs of runes for which fn holds.
+// Unless contiguous is set every rune forms its own match.
+func runeMatches(s string, contiguous bool, fn func(r rune) bool) [][2]int {
+	var matches [][2]int
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		start, end := i, i+size
+		i = end
+		if !fn(r) {
+			continue
+		}
+		if contiguous && len(matches) > 0 && matches[len(matches)-1][1] == start {
+			matches[len(matches)-1][1] = end
+			continue
+		}
+		matches = append(matches, [2]int{start, end})
+	}
+	return matches
+}
@@ -0,0 +1,196 @@
+package tokenizer
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizer rewrites text before it is split into words
+type normalizer interface {
+	normalize(n *normalized)
+}
+
+// normalizerSequence applies normalizers in order
+type normalizerSequence []normalizer
+
+func (s normalizerSequence) normalize(n *normalized) {
+	for _, normalizer := range s {
+		normalizer.normalize(n)
+	}
+}
+
+// bertNormalizer mirrors the text cleanup of the original BERT BasicTokenizer
+type bertNormalizer struct {
+	cleanText          bool
+	handleChineseChars bool
+	stripAccents       bool
+	lowercase          bool
+}
+
+func (b bertNormalizer) normalize(n *normalized) {
+	if b.cleanText {
+		n.mapRunes(func(dst []byte, r rune) []byte {
+			switch {
+			case r == 0 || r == unicode.ReplacementChar || isControl(r):
+				return dst
+			case isWhitespace(r):
+				return append(dst, ' ')
+			}
+			return utf8.AppendRune(dst, r)
+		})
+	}
+	if b.handleChineseChars {
+		n.mapRunes(func(dst []byte, r rune) []byte {
+			if isChineseChar(r) {
+				return append(utf8.AppendRune(append(dst, ' '), r), ' ')
+			}
+			return utf8.AppendRune(dst, r)
+		})
+	}
+	if b.stripAccents {
+		stripAccents{}.normalize(n)
+	}
+	if b.lowercase {
+		lowercase{}.normalize(n)
+	}
+}
+
+// lowercase maps text to lower case
+type lowercase struct{}
+
+func (lowercase) normalize(n *normalized) {
+	n.mapRunes(func(dst []byte, r rune) []byte {
+		return utf8.AppendRune(dst, unicode.ToLower(r))
+	})
+}
+
+// stripAccents decomposes text and removes combining marks
+type stripAccents struct{}
+
+func (stripAccents) normalize(n *normalized) {
+	n.normalizeForm(norm.NFD)
+	n.mapRunes(func(dst []byte, r rune) []byte {
+		if unicode.Is(unicode.Mn, r) {
+			return dst
+		}
+		return utf8.AppendRune(dst, r)
+	})
+}
+
+// unicodeNormalizer applies a Unicode normalization form
+type unicodeNormalizer struct {
+	form norm.Form
+}
+
+func (u unicodeNormalizer) normalize(n *normalized) {
+	n.normalizeForm(u.form)
+}
+
+// strip removes leading and/or trailing whitespace
+type strip struct {
+	left, right bool
+}
+
+func (s strip) normalize(n *normalized) {
+	n.trim(s.left, s.right, unicode.IsSpace)
+}
+
+// nmt removes control characters and maps unusual spaces to a plain space, like
+// the SentencePiece NMT normalization rules
+type nmt struct{}
+
+func (nmt) normalize(n *normalized) {
+	n.mapRunes(func(dst []byte, r rune) []byte {
+		switch {
+		case (r >= 0x01 && r <= 0x08) || r == 0x0B || (r >= 0x0E && r <= 0x1F) || r == 0x7F || r == 0x8F || r == 0x9F:
+			return dst
+		case r == 0x09 || r == 0x0A || r == 0x0C || r == 0x0D || r == 0x1680 ||
+			(r >= 0x200B && r <= 0x200F) || r == 0x2028 || r == 0x2029 || r == 0x2581 || r == 0xFEFF || r == 0xFFFD:
+			return append(dst, ' ')
+		}
+		return utf8.AppendRune(dst, r)
+	})
+}
+
+// prepend adds a prefix to non-empty text
+type prepend struct {
+	prefix string
+}
+
+func (p prepend) normalize(n *normalized) {
+	if n.text != "" {
+		n.prepend(p.prefix)
+	}
+}
+
+// replace substitutes every match of a pattern
+type replace struct {
+	pattern *regexp.Regexp
+	content string
+}
+
+func (r replace) normalize(n *normalized) {
+	matches := r.pattern.FindAllStringIndex(n.text, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	spans := make([]span, 0, len(n.spans))
+	prev := 0
+	for _, m := range matches {
+		b.WriteString(n.text[prev:m[0]])
+		spans = append(spans, n.spans[prev:m[0]]...)
+		s := n.original(m[0], m[1])
+		b.WriteString(r.content)
+		for range len(r.content) {
+			spans = append(spans, s)
+		}
+		prev = m[1]
+	}
+	b.WriteString(n.text[prev:])
+	spans = append(spans, n.spans[prev:]...)
+	n.text = b.String()
+	n.spans = spans
+}
+
+// isWhitespace reports whether r is whitespace as defined by the BERT tokenizer
+func isWhitespace(r rune) bool {
+	return unicode.IsSpace(r)
+}
+
+// isControl reports whether r is a control character as defined by the BERT
+// tokenizer, which treats tab and newlines as whitespace instead. Every "C"
+// category counts, including unassigned code points.
+func isControl(r rune) bool {
+	switch r {
+	case '\t', '\n', '\r':
+		return false
+	}
+	return !unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z)
+}
+
+// isPunctuation reports whether r is punctuation as defined by the BERT tokenizer,
+// which includes every non-alphanumeric ASCII character
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// isChineseChar reports whether r is in the CJK Unified Ideographs blocks
+func isChineseChar(r rune) bool {
+	return (r >= 0x4E00 && r <= 0x9FFF) ||
+		(r >= 0x3400 && r <= 0x4DBF) ||
+		(r >= 0x20000 && r <= 0x2A6DF) ||
+		(r >= 0x2A700 && r <= 0x2B73F) ||
+		(r >= 0x2B740 && r <= 0x2B81F) ||
+		(r >= 0x2B820 && r <= 0x2CEAF) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0x2F800 && r <= 0x2FA1F)
+}
@@ -0,0 +1,358 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// encoding is a tokenized sequence with per-token alignment information
+type encoding struct {
+	ids     []int64
+	tokens  []string
+	spans   []span
+	words   []int
+	typeIDs []int64
+	// sequences holds the input text of every token, -1 for added special tokens
+	sequences []int
+	special   []bool
+}
+
+func newEncoding(capacity int) *encoding {
+	return &encoding{
+		ids:       make([]int64, 0, capacity),
+		tokens:    make([]string, 0, capacity),
+		spans:     make([]span, 0, capacity),
+		words:     make([]int, 0, capacity),
+		typeIDs:   make([]int64, 0, capacity),
+		sequences: make([]int, 0, capacity),
+		special:   make([]bool, 0, capacity),
+	}
+}
+
+// len returns the number of tokens, zero for a nil encoding
+func (e *encoding) len() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.ids)
+}
+
+func (e *encoding) append(id int64, token string, s span, word int, typeID int64, sequence int, special bool) {
+	e.ids = append(e.ids, id)
+	e.tokens = append(e.tokens, token)
+	e.spans = append(e.spans, s)
+	e.words = append(e.words, word)
+	e.typeIDs = append(e.typeIDs, typeID)
+	e.sequences = append(e.sequences, sequence)
+	e.special = append(e.special, special)
+}
+
+// extend appends every token of other with the given type id
+func (e *encoding) extend(other *encoding, typeID int64) {
+	for i := range other.ids {
+		e.append(other.ids[i], other.tokens[i], other.spans[i], other.words[i], typeID, other.sequences[i], other.special[i])
+	}
+}
+
+// truncate keeps the first n tokens
+func (e *encoding) truncate(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if n >= len(e.ids) {
+		return
+	}
+	e.ids = e.ids[:n]
+	e.tokens = e.tokens[:n]
+	e.spans = e.spans[:n]
+	e.words = e.words[:n]
+	e.typeIDs = e.typeIDs[:n]
+	e.sequences = e.sequences[:n]
+	e.special = e.special[:n]
+}
+
+// pad adds padding tokens to the given side until the encoding has n tokens
+func (e *encoding) pad(n int, t specialToken, typeID int64, side Side) {
+	if side == SideRight {
+		for len(e.ids) < n {
+			e.append(t.id, t.value, span{}, -1, typeID, -1, true)
+		}
+		return
+	}
+	if len(e.ids) >= n {
+		return
+	}
+	padded := newEncoding(n)
+	padded.pad(n-len(e.ids), t, typeID, SideRight)
+	for i := range e.ids {
+		padded.append(e.ids[i], e.tokens[i], e.spans[i], e.words[i], e.typeIDs[i], e.sequences[i], e.special[i])
+	}
+	*e = *padded
+}
+
+// output converts the encoding to the public tokenizer output, where the
+// padding on the given side of the first tokens is masked out. Offsets index
+// into texts, the input text of every sequence.
+func (e *encoding) output(tokens int, padSide Side, texts ...string) *TokenizerOutput {
+	mask := make([]int64, len(e.ids))
+	start := 0
+	if padSide == SideLeft {
+		start = len(e.ids) - tokens
+	}
+	for i := start; i < start+tokens; i++ {
+		mask[i] = 1
+	}
+
+	runes := make([][]int, len(texts))
+	offsets := make([]Offset, len(e.ids))
+	for i, s := range e.spans {
+		seq := e.sequences[i]
+		if seq < 0 || seq >= len(texts) {
+			continue
+		}
+		if runes[seq] == nil {
+			runes[seq] = runeIndex(texts[seq])
+		}
+		offsets[i] = Offset{Start: s.start, End: s.end, RuneStart: runes[seq][s.start], RuneEnd: runes[seq][s.end]}
+	}
+
+	return &TokenizerOutput{
+		InputIds:      e.ids,
+		AttentionMask: mask,
+		TokenTypeIds:  e.typeIDs,
+		Tokens:        e.tokens,
+		Offsets:       offsets,
+		WordIds:       e.words,
+		SequenceIds:   e.sequences,
+	}
+}
+
+// runeIndex returns the number of runes before every byte offset of s, including len(s)
+func runeIndex(s string) []int {
+	index := make([]int, len(s)+1)
+	n := 0
+	for i := 0; i < len(s); {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		for j := range size {
+			index[i+j] = n
+		}
+		n++
+		i += size
+	}
+	index[len(s)] = n
+	return index
+}
+
+// trimOffsets shrinks the spans of tokens so that they exclude surrounding
+// whitespace, which byte-level tokens carry in front of words
+func (e *encoding) trimOffsets(texts []string) {
+	for i, s := range e.spans {
+		seq := e.sequences[i]
+		if seq < 0 || seq >= len(texts) || e.special[i] {
+			continue
+		}
+		text := texts[seq][s.start:s.end]
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+		start := s.start + len(text) - len(trimmed)
+		end := start + len(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+		if end > start {
+			e.spans[i] = span{start, end}
+		}
+	}
+}
+
+// pipeline runs the normalizer, pre-tokenizer, model and post-processor stages
+// shared by every tokenizer
+type pipeline struct {
+	normalizer   normalizer
+	preTokenizer preTokenizer
+	model        model
+	processor    postProcessor
+	decoder      decoder
+	added        *addedVocabulary
+	padToken     specialToken
+	padTypeID    int64
+	// trimOffsets removes whitespace from token offsets
+	trimOffsets bool
+	// defaults holds the encode options of the tokenizer, such as the truncation
+	// and padding stored in tokenizer.json
+	defaults encodeOptions
+}
+
+// encodeSequence tokenizes a single sequence without special tokens
+func (p *pipeline) encodeSequence(text string, sequence int) (*encoding, error) {
+	enc := newEncoding(len(text) / 3)
+	word := 0
+	var buf []token
+
+	for _, seg := range p.added.split(newNormalized(text, 0), p.added.raw) {
+		if seg.added != nil {
+			enc.append(seg.added.id, seg.added.content, seg.span, word, 0, sequence, seg.added.special)
+			word++
+			continue
+		}
+		if p.normalizer != nil {
+			p.normalizer.normalize(seg.text)
+		}
+		for _, inner := range p.added.split(seg.text, p.added.normalized) {
+			if inner.added != nil {
+				t := p.added.byID[inner.added.id]
+				enc.append(t.id, t.content, inner.span, word, 0, sequence, t.special)
+				word++
+				continue
+			}
+			words := []*normalized{inner.text}
+			if p.preTokenizer != nil {
+				words = p.preTokenizer.preTokenize(words)
+			}
+			for _, w := range words {
+				if w.text == "" {
+					continue
+				}
+				var err error
+				if appender, ok := p.model.(tokenAppender); ok {
+					buf = appender.appendTokens(buf[:0], w.text)
+				} else if buf, err = p.model.tokenize(w.text); err != nil {
+					return nil, fmt.Errorf("failed to tokenize word: %w", err)
+				}
+				for _, t := range buf {
+					enc.append(t.id, t.value, w.original(t.start, t.end), word, 0, sequence, false)
+				}
+				word++
+			}
+		}
+	}
+	return enc, nil
+}
+
+// options applies opts over the defaults of the pipeline
+func (p *pipeline) options(opts []EncodeOption) *encodeOptions {
+	o := p.defaults
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &o
+}
+
+// encode tokenizes text with special tokens, truncated and padded to maxLength
+// when it is positive
+func (p *pipeline) encode(text string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	o := p.options(opts)
+	windows, err := p.encodeWindows(text, maxLength, o)
+	if err != nil {
+		return nil, err
+	}
+	return p.finish(windows, o.padLength(maxLength, windows), o, text), nil
+}
+
+// encodeWindows tokenizes text with special tokens and returns the windows kept
+// by truncation, the first one followed by the overflowing ones
+func (p *pipeline) encodeWindows(text string, maxLength int, o *encodeOptions) ([]*encoding, error) {
+	enc, err := p.encodeSequence(text, 0)
+	if err != nil {
+		return nil, err
+	}
+	windows := []*encoding{enc}
+	limit := o.truncationLength(maxLength)
+	if limit > 0 {
+		if windows, err = o.truncate(enc, limit-p.processor.addedTokens(false)); err != nil {
+			return nil, fmt.Errorf("failed to truncate: %w", err)
+		}
+	}
+	for i, w := range windows {
+		windows[i] = p.process(w, nil, limit, o, text)
+	}
+	return windows, nil
+}
+
+// encodePair tokenizes a pair of texts with special tokens. When maxLength is
+// positive the pair is truncated following the truncation strategy, the longer
+// sequence first by default, and the output is padded.
+func (p *pipeline) encodePair(a, b string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	o := p.options(opts)
+	windows, err := p.encodePairWindows(a, b, maxLength, o)
+	if err != nil {
+		return nil, err
+	}
+	return p.finish(windows, o.padLength(maxLength, windows), o, a, b), nil
+}
+
+// encodePairWindows tokenizes a pair of texts with special tokens and returns
+// the windows kept by truncation, the first one followed by the overflowing ones
+func (p *pipeline) encodePairWindows(a, b string, maxLength int, o *encodeOptions) ([]*encoding, error) {
+	encA, err := p.encodeSequence(a, 0)
+	if err != nil {
+		return nil, err
+	}
+	encB, err := p.encodeSequence(b, 1)
+	if err != nil {
+		return nil, err
+	}
+	pairs := [][2]*encoding{{encA, encB}}
+	limit := o.truncationLength(maxLength)
+	if limit > 0 {
+		if pairs, err = o.truncatePair(encA, encB, limit-p.processor.addedTokens(true)); err != nil {
+			return nil, fmt.Errorf("failed to truncate: %w", err)
+		}
+	}
+	windows := make([]*encoding, len(pairs))
+	for i, pair := range pairs {
+		windows[i] = p.process(pair[0], pair[1], limit, o, a, b)
+	}
+	return windows, nil
+}
+
+// process adds the special tokens to truncated sequences
+func (p *pipeline) process(a, b *encoding, maxLength int, o *encodeOptions, texts ...string) *encoding {
+	enc := p.processor.process(a, b)
+	if p.trimOffsets {
+		enc.trimOffsets(texts)
+	}
+	if maxLength > 0 && o.strategy != DoNotTruncate {
+		// special tokens alone may not fit
+		enc.truncate(maxLength)
+	}
+	return enc
+}
+
+// finish pads processed windows to length and converts them to the output, the
+// first window holding the others as overflowing
+func (p *pipeline) finish(windows []*encoding, length int, o *encodeOptions, texts ...string) *TokenizerOutput {
+	out := make([]*TokenizerOutput, len(windows))
+	for i, enc := range windows {
+		tokens := enc.len()
+		enc.pad(length, p.padToken, p.padTypeID, o.padSide)
+		out[i] = enc.output(tokens, o.padSide, texts...)
+	}
+	out[0].Overflowing = out[1:]
+	return out[0]
+}
+
+// tokenToID returns the id of a token in the added or model vocabulary
+func (p *pipeline) tokenToID(token string) (int64, bool) {
+	if id, ok := p.added.lookup(token); ok {
+		return id, true
+	}
+	return p.model.tokenToID(token)
+}
+
+// idToToken returns the token of an id in the added or model vocabulary
+func (p *pipeline) idToToken(id int64) (string, bool) {
+	if t, ok := p.added.byID[id]; ok {
+		return t.content, true
+	}
+	return p.model.idToToken(id)
+}
+
+// vocabSize returns the size of the model vocabulary including added tokens
+func (p *pipeline) vocabSize() int {
+	size := p.model.vocabSize()
+	for _, t := range p.added.tokens {
+		if _, ok := p.model.tokenToID(t.content); !ok {
+			size++
+		}
+	}
+	return size
+}
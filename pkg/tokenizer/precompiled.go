@@ -0,0 +1,119 @@
+package tokenizer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// precompiled applies a SentencePiece precompiled character map: a double-array
+// trie of source strings followed by a blob of NUL-terminated replacements
+type precompiled struct {
+	trie       []uint32
+	normalized []byte
+}
+
+func newPrecompiled(charsMap []byte) (*precompiled, error) {
+	if len(charsMap) == 0 {
+		return &precompiled{}, nil
+	}
+	if len(charsMap) < 4 {
+		return nil, fmt.Errorf("precompiled charsmap is truncated")
+	}
+	trieSize := int(binary.LittleEndian.Uint32(charsMap))
+	if trieSize%4 != 0 || 4+trieSize > len(charsMap) {
+		return nil, fmt.Errorf("precompiled charsmap has invalid trie size %d", trieSize)
+	}
+
+	trie := make([]uint32, trieSize/4)
+	for i := range trie {
+		trie[i] = binary.LittleEndian.Uint32(charsMap[4+i*4:])
+	}
+	return &precompiled{trie: trie, normalized: charsMap[4+trieSize:]}, nil
+}
+
+func (p *precompiled) normalize(n *normalized) {
+	if len(p.trie) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.Grow(len(n.text))
+	spans := make([]span, 0, len(n.spans))
+	for i := 0; i < len(n.text); {
+		replacement, length := p.normalizePrefix(n.text[i:])
+		if replacement == n.text[i:i+length] {
+			spans = append(spans, n.spans[i:i+length]...)
+		} else {
+			s := n.original(i, i+length)
+			for range len(replacement) {
+				spans = append(spans, s)
+			}
+		}
+		b.WriteString(replacement)
+		i += length
+	}
+	n.text = b.String()
+	n.spans = spans
+}
+
+// normalizePrefix returns the replacement for the longest mapped prefix of s and
+// the number of bytes it consumes
+func (p *precompiled) normalizePrefix(s string) (string, int) {
+	length, value := p.longestPrefix(s)
+	if length == 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && size == 1 {
+			return "�", 1
+		}
+		return s[:size], size
+	}
+
+	if value < 0 || value >= len(p.normalized) {
+		return s[:length], length
+	}
+	end := value
+	for end < len(p.normalized) && p.normalized[end] != 0 {
+		end++
+	}
+	return string(p.normalized[value:end]), length
+}
+
+// longestPrefix walks the double-array trie and returns the length and value of
+// the longest key that is a prefix of s
+func (p *precompiled) longestPrefix(s string) (length, value int) {
+	unit := func(pos uint32) (uint32, bool) {
+		if int(pos) >= len(p.trie) {
+			return 0, false
+		}
+		return p.trie[pos], true
+	}
+	offset := func(u uint32) uint32 {
+		return (u >> 10) << ((u & (1 << 9)) >> 6)
+	}
+
+	pos := uint32(0)
+	u, ok := unit(pos)
+	if !ok {
+		return 0, 0
+	}
+	pos ^= offset(u)
+	for i := 0; i < len(s); i++ {
+		c := uint32(s[i])
+		pos ^= c
+		u, ok = unit(pos)
+		if !ok || u&((1<<31)|0xFF) != c {
+			break
+		}
+		pos ^= offset(u)
+		if (u>>8)&1 == 1 {
+			leaf, ok := unit(pos)
+			if !ok {
+				break
+			}
+			length, value = i+1, int(leaf&((1<<31)-1))
+		}
+	}
+	return length, value
+}
@@ -0,0 +1,307 @@
+package tokenizer
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// preTokenizer splits normalized text into words that are tokenized independently
+type preTokenizer interface {
+	preTokenize(pieces []*normalized) []*normalized
+}
+
+// splitEach applies fn to every piece and concatenates the results
+func splitEach(pieces []*normalized, fn func(n *normalized) []*normalized) []*normalized {
+	out := make([]*normalized, 0, len(pieces))
+	for _, piece := range pieces {
+		out = append(out, fn(piece)...)
+	}
+	return out
+}
+
+// preTokenizerSequence applies pre-tokenizers in order
+type preTokenizerSequence []preTokenizer
+
+func (s preTokenizerSequence) preTokenize(pieces []*normalized) []*normalized {
+	for _, p := range s {
+		pieces = p.preTokenize(pieces)
+	}
+	return pieces
+}
+
+// bertPreTokenizer splits on whitespace and isolates every punctuation character
+type bertPreTokenizer struct{}
+
+func (bertPreTokenizer) preTokenize(pieces []*normalized) []*normalized {
+	return splitEach(pieces, func(n *normalized) []*normalized {
+		var out []*normalized
+		for _, word := range n.split(runeMatches(n.text, true, isWhitespace), splitRemoved, false) {
+			out = append(out, word.split(runeMatches(word.text, false, isPunctuation), splitIsolated, false)...)
+		}
+		return out
+	})
+}
+
+// whitespaceSplit splits on whitespace
+type whitespaceSplit struct{}
+
+func (whitespaceSplit) preTokenize(pieces []*normalized) []*normalized {
+	return splitEach(pieces, func(n *normalized) []*normalized {
+		return n.split(runeMatches(n.text, true, unicode.IsSpace), splitRemoved, false)
+	})
+}
+
+// wordPattern matches \w+|[^\w\s]+ with Unicode word characters
+var wordPattern = regexp.MustCompile(`[\p{L}\p{M}\p{Nd}\p{Pc}]+|[^\p{L}\p{M}\p{Nd}\p{Pc}\s]+`)
+
+// whitespacePreTokenizer keeps runs of word characters and runs of symbols
+type whitespacePreTokenizer struct{}
+
+func (whitespacePreTokenizer) preTokenize(pieces []*normalized) []*normalized {
+	return splitEach(pieces, func(n *normalized) []*normalized {
+		return n.split(regexpMatches(wordPattern, n.text), splitRemoved, true)
+	})
+}
+
+// punctuation splits on punctuation characters
+type punctuation struct {
+	behavior splitBehavior
+}
+
+func (p punctuation) preTokenize(pieces []*normalized) []*normalized {
+	return splitEach(pieces, func(n *normalized) []*normalized {
+		return n.split(runeMatches(n.text, false, isPunctuation), p.behavior, false)
+	})
+}
+
+// digits isolates digits, individually or as runs
+type digits struct {
+	individual bool
+}
+
+func (d digits) preTokenize(pieces []*normalized) []*normalized {
+	return splitEach(pieces, func(n *normalized) []*normalized {
+		return n.split(runeMatches(n.text, !d.individual, unicode.IsDigit), splitIsolated, false)
+	})
+}
+
+// charDelimiterSplit splits on a delimiter character, which is removed
+type charDelimiterSplit struct {
+	delimiter rune
+}
+
+func (c charDelimiterSplit) preTokenize(pieces []*normalized) []*normalized {
+	return splitEach(pieces, func(n *normalized) []*normalized {
+		return n.split(runeMatches(n.text, false, func(r rune) bool { return r == c.delimiter }), splitRemoved, false)
+	})
+}
+
+// patternSplit splits on the matches of a pattern
+type patternSplit struct {
+	matches  func(s string) [][2]int
+	behavior splitBehavior
+	invert   bool
+}
+
+func (p patternSplit) preTokenize(pieces []*normalized) []*normalized {
+	return splitEach(pieces, func(n *normalized) []*normalized {
+		return n.split(p.matches(n.text), p.behavior, p.invert)
+	})
+}
+
+func regexpMatches(pattern *regexp.Regexp, s string) [][2]int {
+	indices := pattern.FindAllStringIndex(s, -1)
+	matches := make([][2]int, len(indices))
+	for i, m := range indices {
+		matches[i] = [2]int{m[0], m[1]}
+	}
+	return matches
+}
+
+// metaspaceReplacement is the SentencePiece word boundary marker
+const metaspaceReplacement = "▁"
+
+// prependScheme decides when Metaspace adds a leading replacement character
+type prependScheme int
+
+const (
+	prependAlways prependScheme = iota
+	prependFirst
+	prependNever
+)
+
+// metaspace replaces spaces with the word boundary marker and splits before it
+type metaspace struct {
+	replacement string
+	prepend     prependScheme
+	split       bool
+}
+
+func (m metaspace) preTokenize(pieces []*normalized) []*normalized {
+	var out []*normalized
+	for _, piece := range pieces {
+		if piece.text == "" {
+			continue
+		}
+		piece.mapRunes(func(dst []byte, r rune) []byte {
+			if r == ' ' {
+				return append(dst, m.replacement...)
+			}
+			return utf8.AppendRune(dst, r)
+		})
+		// with prependFirst only the text at the very start of the input gets a prefix
+		if m.prepend == prependAlways || (m.prepend == prependFirst && piece.original(0, 0).start == 0) {
+			if !strings.HasPrefix(piece.text, m.replacement) {
+				piece.prepend(m.replacement)
+			}
+		}
+		if !m.split {
+			out = append(out, piece)
+			continue
+		}
+		var matches [][2]int
+		for start := 0; ; {
+			j := strings.Index(piece.text[start:], m.replacement)
+			if j < 0 {
+				break
+			}
+			matches = append(matches, [2]int{start + j, start + j + len(m.replacement)})
+			start += j + len(m.replacement)
+		}
+		out = append(out, piece.split(matches, splitMergedWithNext, false)...)
+	}
+	return out
+}
+
+// byteLevel splits text with the GPT-2 pattern and maps every byte to a printable
+// character so that byte-level BPE never needs an unknown token
+type byteLevel struct {
+	addPrefixSpace bool
+	useRegex       bool
+}
+
+func (b byteLevel) preTokenize(pieces []*normalized) []*normalized {
+	var out []*normalized
+	for _, piece := range pieces {
+		if piece.text == "" {
+			continue
+		}
+		if b.addPrefixSpace && !strings.HasPrefix(piece.text, " ") {
+			piece.prepend(" ")
+		}
+		words := []*normalized{piece}
+		if b.useRegex {
+			words = piece.split(gpt2Matches(piece.text), splitIsolated, true)
+		}
+		for _, word := range words {
+			word.mapBytes(byteToUnicode)
+			out = append(out, word)
+		}
+	}
+	return out
+}
+
+// byteToUnicode is the GPT-2 mapping from bytes to printable runes
+var byteToUnicode, unicodeToByte = newByteLevelAlphabet()
+
+func newByteLevelAlphabet() ([256]string, map[rune]byte) {
+	var encode [256]string
+	decode := make(map[rune]byte, 256)
+	next := rune(256)
+	for b := 0; b < 256; b++ {
+		r := rune(b)
+		if !((b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF)) {
+			r = next
+			next++
+		}
+		encode[b] = string(r)
+		decode[r] = byte(b)
+	}
+	return encode, decode
+}
+
+// mapBytes replaces every byte with its mapping, aligned with the original range
+// of the character the byte belongs to
+func (n *normalized) mapBytes(mapping [256]string) {
+	var b strings.Builder
+	b.Grow(len(n.text) * 2)
+	spans := make([]span, 0, len(n.spans)*2)
+	for i := 0; i < len(n.text); i++ {
+		out := mapping[n.text[i]]
+		b.WriteString(out)
+		for range len(out) {
+			spans = append(spans, n.spans[i])
+		}
+	}
+	n.text = b.String()
+	n.spans = spans
+}
+
+// gpt2Pattern is the GPT-2 pre-tokenization pattern, which needs a lookahead that
+// Go's regexp package does not support
+const gpt2Pattern = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+`
+
+// gpt2Matches returns the matches of gpt2Pattern
+func gpt2Matches(s string) [][2]int {
+	var matches [][2]int
+	for i := 0; i < len(s); {
+		end := gpt2Match(s, i)
+		matches = append(matches, [2]int{i, end})
+		i = end
+	}
+	return matches
+}
+
+// gpt2Match returns the end of the GPT-2 pattern match starting at i
+func gpt2Match(s string, i int) int {
+	if s[i] == '\'' {
+		for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+			if strings.HasPrefix(s[i+1:], suffix) {
+				return i + 1 + len(suffix)
+			}
+		}
+	}
+
+	start := i
+	if s[i] == ' ' && i+1 < len(s) {
+		if r, _ := utf8.DecodeRuneInString(s[i+1:]); !unicode.IsSpace(r) {
+			start = i + 1
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(s[start:])
+	switch {
+	case unicode.IsLetter(r):
+		return runEnd(s, start, unicode.IsLetter)
+	case unicode.IsNumber(r):
+		return runEnd(s, start, unicode.IsNumber)
+	case !unicode.IsSpace(r):
+		return runEnd(s, start, func(r rune) bool {
+			return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+	}
+
+	// a run of whitespace gives up its last character to a following word
+	end := runEnd(s, i, unicode.IsSpace)
+	if end == len(s) {
+		return end
+	}
+	_, lastSize := utf8.DecodeLastRuneInString(s[i:end])
+	if end-lastSize > i {
+		return end - lastSize
+	}
+	return end
+}
+
+// runEnd returns the end of the run of runes for which fn holds starting at i
+func runEnd(s string, i int, fn func(r rune) bool) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !fn(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
@@ -0,0 +1,157 @@
+package tokenizer
+
+// postProcessor adds the special tokens of a model around encoded sequences
+type postProcessor interface {
+	// addedTokens returns the number of special tokens added to a single
+	// sequence or a pair of sequences
+	addedTokens(pair bool) int
+	// process merges the encodings of the input sequences, b may be nil
+	process(a, b *encoding) *encoding
+}
+
+// specialToken is a special token with its id
+type specialToken struct {
+	value string
+	id    int64
+}
+
+// appendSpecial appends a special token that belongs to no word
+func (e *encoding) appendSpecial(t specialToken, typeID int64) {
+	e.append(t.id, t.value, span{}, -1, typeID, -1, true)
+}
+
+// bertProcessing produces [CLS] A [SEP] and [CLS] A [SEP] B [SEP]
+type bertProcessing struct {
+	cls, sep specialToken
+}
+
+func (p bertProcessing) addedTokens(pair bool) int {
+	if pair {
+		return 3
+	}
+	return 2
+}
+
+func (p bertProcessing) process(a, b *encoding) *encoding {
+	out := newEncoding(a.len() + b.len() + p.addedTokens(b != nil))
+	out.appendSpecial(p.cls, 0)
+	out.extend(a, 0)
+	out.appendSpecial(p.sep, 0)
+	if b != nil {
+		out.extend(b, 1)
+		out.appendSpecial(p.sep, 1)
+	}
+	return out
+}
+
+// robertaProcessing produces <s> A </s> and <s> A </s></s> B </s> with every
+// type id set to 0
+type robertaProcessing struct {
+	cls, sep specialToken
+}
+
+func (p robertaProcessing) addedTokens(pair bool) int {
+	if pair {
+		return 4
+	}
+	return 2
+}
+
+func (p robertaProcessing) process(a, b *encoding) *encoding {
+	out := newEncoding(a.len() + b.len() + p.addedTokens(b != nil))
+	out.appendSpecial(p.cls, 0)
+	out.extend(a, 0)
+	out.appendSpecial(p.sep, 0)
+	if b != nil {
+		out.appendSpecial(p.sep, 0)
+		out.extend(b, 0)
+		out.appendSpecial(p.sep, 0)
+	}
+	return out
+}
+
+// templatePiece is either a special token or one of the input sequences
+type templatePiece struct {
+	// sequence is 0 for A, 1 for B and -1 for special tokens
+	sequence int
+	special  []specialToken
+	typeID   int64
+}
+
+// templateProcessing lays out sequences and special tokens following a template
+type templateProcessing struct {
+	single, pair []templatePiece
+}
+
+func (p templateProcessing) addedTokens(pair bool) int {
+	template := p.single
+	if pair {
+		template = p.pair
+	}
+	n := 0
+	for _, piece := range template {
+		n += len(piece.special)
+	}
+	return n
+}
+
+func (p templateProcessing) process(a, b *encoding) *encoding {
+	template := p.single
+	if b != nil {
+		template = p.pair
+	}
+	out := newEncoding(a.len() + b.len() + p.addedTokens(b != nil))
+	for _, piece := range template {
+		switch piece.sequence {
+		case 0:
+			out.extend(a, piece.typeID)
+		case 1:
+			if b != nil {
+				out.extend(b, piece.typeID)
+			}
+		default:
+			for _, t := range piece.special {
+				out.appendSpecial(t, piece.typeID)
+			}
+		}
+	}
+	return out
+}
+
+// concatProcessing joins sequences without special tokens, the default when a
+// tokenizer has no post-processor
+type concatProcessing struct{}
+
+func (concatProcessing) addedTokens(bool) int {
+	return 0
+}
+
+func (concatProcessing) process(a, b *encoding) *encoding {
+	out := newEncoding(a.len() + b.len())
+	out.extend(a, 0)
+	if b != nil {
+		out.extend(b, 1)
+	}
+	return out
+}
+
+// processorSequence applies the one processor of a sequence that adds special
+// tokens. Byte-level processors only adjust offsets and are skipped.
+type processorSequence []postProcessor
+
+func (s processorSequence) main() postProcessor {
+	for _, p := range s {
+		if _, ok := p.(concatProcessing); !ok {
+			return p
+		}
+	}
+	return concatProcessing{}
+}
+
+func (s processorSequence) addedTokens(pair bool) int {
+	return s.main().addedTokens(pair)
+}
+
+func (s processorSequence) process(a, b *encoding) *encoding {
+	return s.main().process(a, b)
+}
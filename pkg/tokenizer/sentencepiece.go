@@ -0,0 +1,485 @@
+package tokenizer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/joeychilson/infergo/internal/protowire"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SentencePiece piece types
+const (
+	spNormal      = 1
+	spUnknown     = 2
+	spControl     = 3
+	spUserDefined = 4
+	spUnused      = 5
+	spByte        = 6
+)
+
+// SentencePiece model types
+const (
+	spUnigram = 1
+	spBPE     = 2
+)
+
+// spPiece is a vocabulary entry of a SentencePiece model
+type spPiece struct {
+	piece string
+	score float32
+	kind  int
+}
+
+// spModel is the subset of a SentencePiece ModelProto needed for encoding
+type spModel struct {
+	pieces       []spPiece
+	modelType    int
+	byteFallback bool
+	unkID        int64
+	bosID        int64
+	eosID        int64
+	padID        int64
+
+	normalizerName         string
+	charsMap               []byte
+	addDummyPrefix         bool
+	removeExtraWhitespaces bool
+	escapeWhitespaces      bool
+}
+
+// parseSentencePieceModel decodes a serialized SentencePiece ModelProto
+func parseSentencePieceModel(buf []byte) (*spModel, error) {
+	m := &spModel{
+		modelType:              spUnigram,
+		unkID:                  0,
+		bosID:                  1,
+		eosID:                  2,
+		padID:                  -1,
+		addDummyPrefix:         true,
+		removeExtraWhitespaces: true,
+		escapeWhitespaces:      true,
+	}
+
+	err := protowire.Walk(buf, func(f protowire.Field) error {
+		switch f.Number {
+		case 1:
+			piece := spPiece{kind: spNormal}
+			err := protowire.Walk(f.Bytes, func(f protowire.Field) error {
+				switch f.Number {
+				case 1:
+					piece.piece = f.String()
+				case 2:
+					piece.score = f.Float32()
+				case 3:
+					piece.kind = int(f.Int32())
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to decode piece %d: %w", len(m.pieces), err)
+			}
+			m.pieces = append(m.pieces, piece)
+		case 2:
+			err := protowire.Walk(f.Bytes, func(f protowire.Field) error {
+				switch f.Number {
+				case 3:
+					m.modelType = int(f.Int32())
+				case 35:
+					m.byteFallback = f.Bool()
+				case 40:
+					m.unkID = int64(f.Int32())
+				case 41:
+					m.bosID = int64(f.Int32())
+				case 42:
+					m.eosID = int64(f.Int32())
+				case 43:
+					m.padID = int64(f.Int32())
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to decode trainer spec: %w", err)
+			}
+		case 3:
+			err := protowire.Walk(f.Bytes, func(f protowire.Field) error {
+				switch f.Number {
+				case 1:
+					m.normalizerName = f.String()
+				case 2:
+					m.charsMap = f.Bytes
+				case 3:
+					m.addDummyPrefix = f.Bool()
+				case 4:
+					m.removeExtraWhitespaces = f.Bool()
+				case 5:
+					m.escapeWhitespaces = f.Bool()
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to decode normalizer spec: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(m.pieces) == 0 {
+		return nil, fmt.Errorf("model has no pieces")
+	}
+	return m, nil
+}
+
+// piece returns the piece with the given id, or an empty string if the id is unused
+func (m *spModel) piece(id int64) string {
+	if id < 0 || id >= int64(len(m.pieces)) {
+		return ""
+	}
+	return m.pieces[id].piece
+}
+
+// toFairseq rearranges the vocabulary like fairseq models such as XLM-R, which
+// put <s>, <pad>, </s> and <unk> first, shift the remaining pieces by one and
+// append <mask>
+func (m *spModel) toFairseq() error {
+	if m.unkID != 0 || m.bosID != 1 || m.eosID != 2 || len(m.pieces) < 3 ||
+		m.pieces[0].kind != spUnknown || m.pieces[1].kind != spControl || m.pieces[2].kind != spControl {
+		return fmt.Errorf("model does not use the <unk>, <s>, </s> layout of fairseq models")
+	}
+	pieces := make([]spPiece, 0, len(m.pieces)+2)
+	pieces = append(pieces,
+		spPiece{piece: m.pieces[1].piece, kind: spControl},
+		spPiece{piece: "<pad>", kind: spControl},
+		spPiece{piece: m.pieces[2].piece, kind: spControl},
+		spPiece{piece: m.pieces[0].piece, kind: spUnknown},
+	)
+	pieces = append(pieces, m.pieces[3:]...)
+	pieces = append(pieces, spPiece{piece: "<mask>", kind: spControl})
+	m.pieces = pieces
+	m.bosID, m.padID, m.eosID, m.unkID = 0, 1, 2, 3
+	return nil
+}
+
+// spWhitespace applies the whitespace rules of SentencePiece normalization
+type spWhitespace struct {
+	removeExtra bool
+	dummyPrefix bool
+}
+
+func (s spWhitespace) normalize(n *normalized) {
+	if s.removeExtra {
+		n.trim(true, true, func(r rune) bool { return r == ' ' })
+		extraSpaces.normalize(n)
+	}
+	if s.dummyPrefix && n.text != "" {
+		n.prepend(" ")
+	}
+}
+
+// extraSpaces collapses runs of spaces
+var extraSpaces = replace{pattern: regexp.MustCompile(` {2,}`), content: " "}
+
+// SentencePieceTokenizer is a tokenizer for SentencePiece .model files, as used by
+// T5, XLM-R, ALBERT and Llama models
+type SentencePieceTokenizer struct {
+	pipeline      *pipeline
+	specialTokens SpecialTokens
+	labels        map[int]string
+}
+
+// SentencePieceOption configures a SentencePieceTokenizer
+type SentencePieceOption func(*sentencePieceOptions)
+
+type sentencePieceOptions struct {
+	addBOS, addEOS *bool
+	fairseq        bool
+}
+
+// WithBOS sets whether the beginning of sequence token is added, by default it is
+// added when the model has one
+func WithBOS(enabled bool) SentencePieceOption {
+	return func(o *sentencePieceOptions) {
+		o.addBOS = &enabled
+	}
+}
+
+// WithEOS sets whether the end of sequence token is added, by default it is added
+// when the model has one
+func WithEOS(enabled bool) SentencePieceOption {
+	return func(o *sentencePieceOptions) {
+		o.addEOS = &enabled
+	}
+}
+
+// WithFairseqIDs maps pieces to the ids of models trained with fairseq, such as
+// XLM-R, whose embeddings do not follow the ids of the SentencePiece model
+func WithFairseqIDs() SentencePieceOption {
+	return func(o *sentencePieceOptions) {
+		o.fairseq = true
+	}
+}
+
+// LoadSentencePieceTokenizer loads a tokenizer from a SentencePiece .model file
+func LoadSentencePieceTokenizer(path string, opts ...SentencePieceOption) (*SentencePieceTokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model file: %w", err)
+	}
+	defer f.Close()
+	return NewSentencePieceTokenizer(f, opts...)
+}
+
+// NewSentencePieceTokenizer reads a serialized SentencePiece model
+func NewSentencePieceTokenizer(r io.Reader, opts ...SentencePieceOption) (*SentencePieceTokenizer, error) {
+	options := &sentencePieceOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+	sp, err := parseSentencePieceModel(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode model: %w", err)
+	}
+	if options.fairseq {
+		if err := sp.toFairseq(); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &pipeline{processor: concatProcessing{}}
+	if p.normalizer, err = sp.normalizer(); err != nil {
+		return nil, err
+	}
+	if sp.escapeWhitespaces {
+		p.preTokenizer = metaspace{replacement: metaspaceReplacement, prepend: prependNever, split: true}
+	}
+	decoderPrepend := prependNever
+	if sp.addDummyPrefix {
+		decoderPrepend = prependAlways
+	}
+	p.decoder = decoderSequence{byteFallbackDecoder{}, metaspaceDecoder{replacement: metaspaceReplacement, prepend: decoderPrepend}}
+
+	switch sp.modelType {
+	case spUnigram:
+		pieces := make([]unigramPiece, len(sp.pieces))
+		for i, piece := range sp.pieces {
+			pieces[i] = unigramPiece{
+				piece:     piece.piece,
+				score:     float64(piece.score),
+				matchable: piece.kind == spNormal || piece.kind == spUserDefined,
+			}
+		}
+		if sp.piece(sp.unkID) == "" {
+			sp.unkID = -1
+		}
+		if p.model, err = newUnigramModel(pieces, sp.unkID, sp.byteFallback); err != nil {
+			return nil, err
+		}
+	case spBPE:
+		if p.model, err = sp.bpeModel(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported model type %d", sp.modelType)
+	}
+
+	var added []addedToken
+	for i, piece := range sp.pieces {
+		switch piece.kind {
+		case spControl, spUnknown:
+			added = append(added, addedToken{id: int64(i), content: piece.piece, special: true})
+		case spUserDefined:
+			added = append(added, addedToken{id: int64(i), content: piece.piece})
+		}
+	}
+	p.added = newAddedVocabulary(added, p.normalizer)
+
+	special := SpecialTokens{
+		PAD: sp.piece(sp.padID),
+		UNK: sp.piece(sp.unkID),
+	}
+	if _, ok := p.tokenToID("<mask>"); ok {
+		special.MASK = "<mask>"
+	}
+	if special.PAD != "" {
+		p.padToken = specialToken{value: special.PAD, id: sp.padID}
+	}
+
+	var bos, eos []specialToken
+	if boolOr(options.addBOS, true) && sp.piece(sp.bosID) != "" {
+		special.CLS = sp.piece(sp.bosID)
+		bos = []specialToken{{value: special.CLS, id: sp.bosID}}
+	}
+	if boolOr(options.addEOS, true) && sp.piece(sp.eosID) != "" {
+		special.SEP = sp.piece(sp.eosID)
+		eos = []specialToken{{value: special.SEP, id: sp.eosID}}
+	}
+	switch {
+	case bos != nil && eos != nil:
+		p.processor = robertaProcessing{cls: bos[0], sep: eos[0]}
+	case bos != nil || eos != nil:
+		p.processor = templateProcessing{
+			single: []templatePiece{{sequence: -1, special: bos}, {sequence: 0}, {sequence: -1, special: eos}},
+			pair: []templatePiece{
+				{sequence: -1, special: bos}, {sequence: 0}, {sequence: -1, special: eos},
+				{sequence: -1, special: bos, typeID: 1}, {sequence: 1, typeID: 1}, {sequence: -1, special: eos, typeID: 1},
+			},
+		}
+	}
+
+	labels := make(map[int]string, len(sp.pieces))
+	for i, piece := range sp.pieces {
+		labels[i] = piece.piece
+	}
+	return &SentencePieceTokenizer{pipeline: p, specialTokens: special, labels: labels}, nil
+}
+
+// normalizer returns the normalization rules of the model
+func (m *spModel) normalizer() (normalizer, error) {
+	var sequence normalizerSequence
+	if len(m.charsMap) > 0 {
+		charsMap, err := newPrecompiled(m.charsMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode normalization rules: %w", err)
+		}
+		sequence = append(sequence, charsMap)
+	} else {
+		// models without a compiled rule set only name their normalization
+		switch m.normalizerName {
+		case "", "identity":
+		case "nfkc":
+			sequence = append(sequence, unicodeNormalizer{form: norm.NFKC})
+		case "nmt_nfkc":
+			sequence = append(sequence, nmt{}, unicodeNormalizer{form: norm.NFKC})
+		case "nfkc_cf":
+			sequence = append(sequence, unicodeNormalizer{form: norm.NFKC}, lowercase{})
+		case "nmt_nfkc_cf":
+			sequence = append(sequence, nmt{}, unicodeNormalizer{form: norm.NFKC}, lowercase{})
+		default:
+			return nil, fmt.Errorf("unsupported normalization rule %q", m.normalizerName)
+		}
+	}
+	sequence = append(sequence, spWhitespace{removeExtra: m.removeExtraWhitespaces, dummyPrefix: m.addDummyPrefix})
+	return sequence, nil
+}
+
+// bpeModel converts a SentencePiece BPE model, whose merges are implied by the
+// scores of the merged pieces, into an explicit list of ranked merges
+func (m *spModel) bpeModel() (*bpeModel, error) {
+	ids := make(map[string]int64, len(m.pieces))
+	for i, piece := range m.pieces {
+		if _, ok := ids[piece.piece]; !ok {
+			ids[piece.piece] = int64(i)
+		}
+	}
+
+	type candidate struct {
+		pair [2]string
+		rank [3]int64
+	}
+	var candidates []candidate
+	for i, piece := range m.pieces {
+		if piece.kind != spNormal && piece.kind != spUserDefined {
+			continue
+		}
+		for j := range piece.piece {
+			if j == 0 {
+				continue
+			}
+			a, b := piece.piece[:j], piece.piece[j:]
+			idA, okA := ids[a]
+			idB, okB := ids[b]
+			if okA && okB {
+				candidates = append(candidates, candidate{pair: [2]string{a, b}, rank: [3]int64{int64(i), idA, idB}})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i].rank, candidates[j].rank
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+	merges := make([][2]string, len(candidates))
+	for i, c := range candidates {
+		merges[i] = c.pair
+	}
+
+	model, err := newBPEModel(newVocabulary(ids), merges, "")
+	if err != nil {
+		return nil, err
+	}
+	model.byteFallback = m.byteFallback
+	model.fuseUnk = true
+	if unk := m.piece(m.unkID); unk != "" {
+		if err := model.setUnknown(unk); err != nil {
+			return nil, err
+		}
+	}
+	return model, nil
+}
+
+// Encode tokenizes text with the special tokens of the model. When maxLength is
+// positive the text is truncated, keeping the special tokens, and the output is
+// padded to exactly maxLength tokens. opts configure truncation and overflow.
+func (t *SentencePieceTokenizer) Encode(text string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encode(text, maxLength, opts...)
+}
+
+// EncodePair tokenizes a pair of texts, such as a question and a context, with
+// the special tokens of the model. When maxLength is positive the longer text is
+// truncated first, unless opts select another strategy, and the output is padded
+// to exactly maxLength tokens.
+func (t *SentencePieceTokenizer) EncodePair(a, b string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error) {
+	return t.pipeline.encodePair(a, b, maxLength, opts...)
+}
+
+// EncodeBatch tokenizes texts concurrently like Encode and pads them to the
+// longest encoding of the batch, unless opts select another padding strategy
+func (t *SentencePieceTokenizer) EncodeBatch(texts []string, maxLength int, opts ...EncodeOption) (*BatchOutput, error) {
+	return t.pipeline.encodeBatch(texts, maxLength, opts...)
+}
+
+// Decode converts ids back to text
+func (t *SentencePieceTokenizer) Decode(ids []int64, skipSpecial bool) (string, error) {
+	return t.pipeline.decode(ids, skipSpecial)
+}
+
+// TokenToID returns the id of a piece
+func (t *SentencePieceTokenizer) TokenToID(token string) (int64, bool) {
+	return t.pipeline.tokenToID(token)
+}
+
+// IDToToken returns the piece of an id
+func (t *SentencePieceTokenizer) IDToToken(id int64) (string, bool) {
+	return t.pipeline.idToToken(id)
+}
+
+// VocabSize returns the number of pieces
+func (t *SentencePieceTokenizer) VocabSize() int {
+	return len(t.labels)
+}
+
+// SpecialTokens returns the special tokens of the tokenizer. Tokens the model
+// does not use are empty.
+func (t *SentencePieceTokenizer) SpecialTokens() SpecialTokens {
+	return t.specialTokens
+}
+
+// Labels returns the pieces of the vocabulary by id
+func (t *SentencePieceTokenizer) Labels() map[int]string {
+	return t.labels
+}
@@ -0,0 +1,172 @@
+package tokenizer
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/joeychilson/infergo/internal/protowire"
+)
+
+// encodeSentencePieceModel serializes a ModelProto with the given pieces and
+// trainer spec fields
+func encodeSentencePieceModel(pieces []spPiece, modelType int, byteFallback bool) []byte {
+	var b []byte
+	for _, p := range pieces {
+		piece := protowire.AppendString(nil, 1, p.piece)
+		piece = protowire.AppendFixed32(piece, 2, math.Float32bits(p.score))
+		piece = protowire.AppendVarint(piece, 3, uint64(p.kind))
+		b = protowire.AppendBytes(b, 1, piece)
+	}
+
+	trainer := protowire.AppendVarint(nil, 3, uint64(modelType))
+	if byteFallback {
+		trainer = protowire.AppendVarint(trainer, 35, 1)
+	}
+	trainer = protowire.AppendVarint(trainer, 43, uint64(math.MaxUint64)) // pad_id -1
+	b = protowire.AppendBytes(b, 2, trainer)
+
+	normalizer := protowire.AppendString(nil, 1, "identity")
+	normalizer = protowire.AppendVarint(normalizer, 3, 1)
+	b = protowire.AppendBytes(b, 3, normalizer)
+	return b
+}
+
+var testUnigramPieces = []spPiece{
+	{"<unk>", 0, spUnknown},
+	{"<s>", 0, spControl},
+	{"</s>", 0, spControl},
+	{"▁", -2, spNormal},
+	{"a", -3, spNormal},
+	{"b", -3, spNormal},
+	{"▁ab", -1.5, spNormal},
+	{"c", -4, spNormal},
+	{"<0x78>", 0, spByte},
+	{"<0xC3>", 0, spByte},
+	{"<0xA9>", 0, spByte},
+}
+
+func TestSentencePieceTokenizer(t *testing.T) {
+	bpePieces := []spPiece{
+		{"<unk>", 0, spUnknown},
+		{"<s>", 0, spControl},
+		{"</s>", 0, spControl},
+		{"▁", -1, spNormal},
+		{"a", -2, spNormal},
+		{"b", -3, spNormal},
+		{"▁a", -4, spNormal},
+		{"▁ab", -5, spNormal},
+	}
+
+	tests := []struct {
+		name         string
+		model        []byte
+		opts         []SentencePieceOption
+		text         string
+		wantIDs      []int64
+		wantTokens   []string
+		wantDecoded  string
+		wantSpecials SpecialTokens
+	}{
+		{
+			name:         "unigram",
+			model:        encodeSentencePieceModel(testUnigramPieces, spUnigram, false),
+			text:         "  ab   c ",
+			wantIDs:      []int64{1, 6, 3, 7, 2},
+			wantTokens:   []string{"<s>", "▁ab", "▁", "c", "</s>"},
+			wantDecoded:  "ab c",
+			wantSpecials: SpecialTokens{UNK: "<unk>", CLS: "<s>", SEP: "</s>"},
+		},
+		{
+			name:         "unknown",
+			model:        encodeSentencePieceModel(testUnigramPieces, spUnigram, false),
+			opts:         []SentencePieceOption{WithBOS(false)},
+			text:         "ax",
+			wantIDs:      []int64{3, 4, 0, 2},
+			wantTokens:   []string{"▁", "a", "<unk>", "</s>"},
+			wantDecoded:  "a",
+			wantSpecials: SpecialTokens{UNK: "<unk>", SEP: "</s>"},
+		},
+		{
+			name:         "byte fallback",
+			model:        encodeSentencePieceModel(testUnigramPieces, spUnigram, true),
+			opts:         []SentencePieceOption{WithBOS(false), WithEOS(false)},
+			text:         "xé",
+			wantIDs:      []int64{3, 8, 9, 10},
+			wantTokens:   []string{"▁", "<0x78>", "<0xC3>", "<0xA9>"},
+			wantDecoded:  "xé",
+			wantSpecials: SpecialTokens{UNK: "<unk>"},
+		},
+		{
+			name:         "fairseq ids",
+			model:        encodeSentencePieceModel(testUnigramPieces, spUnigram, false),
+			opts:         []SentencePieceOption{WithFairseqIDs()},
+			text:         "ab <mask>",
+			wantIDs:      []int64{0, 7, 12, 2},
+			wantTokens:   []string{"<s>", "▁ab", "<mask>", "</s>"},
+			wantDecoded:  "ab",
+			wantSpecials: SpecialTokens{PAD: "<pad>", UNK: "<unk>", CLS: "<s>", SEP: "</s>", MASK: "<mask>"},
+		},
+		{
+			name:         "bpe",
+			model:        encodeSentencePieceModel(bpePieces, spBPE, false),
+			opts:         []SentencePieceOption{WithEOS(false)},
+			text:         "ab",
+			wantIDs:      []int64{1, 7},
+			wantTokens:   []string{"<s>", "▁ab"},
+			wantDecoded:  "ab",
+			wantSpecials: SpecialTokens{UNK: "<unk>", CLS: "<s>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := NewSentencePieceTokenizer(bytes.NewReader(tt.model), tt.opts...)
+			if err != nil {
+				t.Fatalf("NewSentencePieceTokenizer() error = %v", err)
+			}
+			got, err := tok.Encode(tt.text, 0)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.InputIds, tt.wantIDs) {
+				t.Errorf("InputIds = %v, want %v", got.InputIds, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+			decoded, err := tok.Decode(got.InputIds, true)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if decoded != tt.wantDecoded {
+				t.Errorf("Decode() = %q, want %q", decoded, tt.wantDecoded)
+			}
+			if specials := tok.SpecialTokens(); specials != tt.wantSpecials {
+				t.Errorf("SpecialTokens() = %+v, want %+v", specials, tt.wantSpecials)
+			}
+		})
+	}
+}
+
+func TestSentencePieceTokenizerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		model []byte
+		opts  []SentencePieceOption
+	}{
+		{name: "empty", model: nil},
+		{name: "truncated", model: []byte{0x0a, 0x10}},
+		{name: "unsupported model type", model: encodeSentencePieceModel(testUnigramPieces, 3, false)},
+		{name: "fairseq layout", model: encodeSentencePieceModel(testUnigramPieces[3:], spUnigram, false), opts: []SentencePieceOption{WithFairseqIDs()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSentencePieceTokenizer(bytes.NewReader(tt.model), tt.opts...); err == nil {
+				t.Error("NewSentencePieceTokenizer() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+"""Writes reference encodings of the conformance inputs for released models with
+the Hugging Face tokenizers library. Every model gets its tokenizer.json saved
+as <name>.tokenizer.json and its encodings as <name>.json, which
+TestReferenceEncodings requires.
+
+    pip install tokenizers
+    cd pkg/tokenizer/testdata/conformance/reference && python generate.py
+"""
+
+import json
+
+import tokenizers
+
+# a byte-level BPE, a SentencePiece Unigram with a Metaspace pre-tokenizer and
+# a SentencePiece Unigram with a Precompiled normalizer
+MODELS = ["roberta-base", "xlm-roberta-base", "t5-small"]
+
+with open("../inputs.json", encoding="utf-8") as f:
+    inputs = json.load(f)
+
+for name in MODELS:
+    tok = tokenizers.Tokenizer.from_pretrained(name)
+    tok.no_truncation()
+    tok.no_padding()
+    tok.save(f"{name}.tokenizer.json")
+
+    cases = []
+    for case in inputs:
+        enc = tok.encode(case["text"], case.get("pair"))
+        cases.append({"name": case["name"], "text": case["text"], "pair": case.get("pair"), "ids": enc.ids, "tokens": enc.tokens})
+    reference = {
+        "tokenizer": f"{name}.tokenizer.json",
+        "source": f"{name} from the Hugging Face Hub, tokenizers {tokenizers.__version__}, generate.py",
+        "cases": cases,
+    }
+    with open(f"{name}.json", "w", encoding="utf-8") as f:
+        json.dump(reference, f, ensure_ascii=False, indent=2)
+        f.write("\n")
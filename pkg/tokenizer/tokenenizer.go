@@ -1,6 +1,72 @@
 package tokenizer
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
+
+// Tokenizer converts text to the token ids of a model and back. Every tokenizer
+// in this package implements it.
+type Tokenizer interface {
+	// Encode tokenizes text with the special tokens of the model, truncated and
+	// padded to maxLength when it is positive
+	Encode(text string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error)
+	// EncodePair tokenizes a pair of texts, such as a question and a context
+	EncodePair(a, b string, maxLength int, opts ...EncodeOption) (*TokenizerOutput, error)
+	// EncodeBatch tokenizes texts into padded row-major matrices
+	EncodeBatch(texts []string, maxLength int, opts ...EncodeOption) (*BatchOutput, error)
+	// Decode converts ids back to text, without special tokens when skipSpecial is set
+	Decode(ids []int64, skipSpecial bool) (string, error)
+	// VocabSize returns the number of tokens in the vocabulary
+	VocabSize() int
+	// SpecialTokens returns the special tokens of the vocabulary
+	SpecialTokens() SpecialTokens
+}
+
+var (
+	_ Tokenizer = (*BERTTokenizer)(nil)
+	_ Tokenizer = (*BPETokenizer)(nil)
+	_ Tokenizer = (*HuggingFaceTokenizer)(nil)
+	_ Tokenizer = (*SentencePieceTokenizer)(nil)
+)
+
+// MaskLogits represents the logits for masked tokens
+type MaskLogits struct {
+	Position int       // Position of the mask token
+	Logits   []float32 // Logits for the mask token
+}
+
+// FindMaskLogits extracts logits for all mask tokens in the sequence from the
+// [sequence, vocab] logits of a masked language model
+func FindMaskLogits(t Tokenizer, tokens []string, logits []float32) ([]MaskLogits, error) {
+	mask := t.SpecialTokens().MASK
+	if mask == "" {
+		return nil, fmt.Errorf("tokenizer has no mask token")
+	}
+	if len(tokens) == 0 || len(logits)%len(tokens) != 0 {
+		return nil, fmt.Errorf("logits length (%d) is not a multiple of tokens length (%d)", len(logits), len(tokens))
+	}
+
+	vocabSize := t.VocabSize()
+
+	var maskLogits []MaskLogits
+	for pos, token := range tokens {
+		if token == mask {
+			start := pos * vocabSize
+			end := start + vocabSize
+			if end > len(logits) {
+				return nil, fmt.Errorf("logits array too short for mask at position %d", pos)
+			}
+
+			maskLogits = append(maskLogits, MaskLogits{
+				Position: pos,
+				Logits:   logits[start:end],
+			})
+		}
+	}
+
+	return maskLogits, nil
+}
 
 // SpecialTokens represents the special tokens used by the tokenizer
 type SpecialTokens struct {
@@ -46,9 +112,37 @@ func (st SpecialTokens) IsSpecialToken(token string) (string, bool) {
 type TokenizerOutput struct {
 	InputIds      []int64
 	AttentionMask []int64
-	Tokens        []string
+	// TokenTypeIds holds the segment of every token, 0 for the first text and 1
+	// for the second text of a pair
+	TokenTypeIds []int64
+	Tokens       []string
+	// Offsets holds the range of the input text every token was produced from.
+	// Special and padding tokens have empty offsets.
+	Offsets []Offset
+	// WordIds holds the index of the word of every token within its text, -1 for
+	// special and padding tokens
+	WordIds []int
+	// SequenceIds holds which text of a pair every token belongs to, -1 for
+	// special and padding tokens
+	SequenceIds []int
+	// Overflowing holds the windows of tokens removed by truncation when encoding
+	// with WithOverflow
+	Overflowing []*TokenizerOutput
+}
+
+// Offset is a range of the input text, in bytes and in runes. For pairs it
+// indexes into the text given by the sequence id of the token.
+type Offset struct {
+	Start, End         int
+	RuneStart, RuneEnd int
 }
 
+// WordPiece splits a word into the longest pieces of vocab, looking up every
+// candidate substring of the word
+//
+// Deprecated: WordPiece is slow on long words and may split a word inside a
+// multi-byte character. Use NewBERTTokenizer or LoadBERTTokenizer, whose model
+// matches pieces with a trie, instead.
 func WordPiece(vocab map[string]int, specialTokens SpecialTokens, word string) []string {
 	if _, ok := vocab[word]; ok {
 		return []string{word}
@@ -0,0 +1,61 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindMaskLogits(t *testing.T) {
+	bert, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		tok       Tokenizer
+		text      string
+		wantMasks []int
+	}{
+		{name: "bert", tok: bert, text: "the [MASK] sat on the [MASK]", wantMasks: []int{2, 6}},
+		{name: "bpe", tok: loadTestBPETokenizer(t), text: "hello <mask>", wantMasks: []int{2}},
+		{name: "tokenizer.json", tok: loadTestTokenizer(t, "roberta.json"), text: "<mask> world", wantMasks: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tt.tok.Encode(tt.text, 0)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			vocabSize := tt.tok.VocabSize()
+			logits := make([]float32, len(out.Tokens)*vocabSize)
+			for i := range logits {
+				logits[i] = float32(i / vocabSize)
+			}
+
+			got, err := FindMaskLogits(tt.tok, out.Tokens, logits)
+			if err != nil {
+				t.Fatalf("FindMaskLogits() error = %v", err)
+			}
+			var positions []int
+			for _, m := range got {
+				positions = append(positions, m.Position)
+				if len(m.Logits) != vocabSize || m.Logits[0] != float32(m.Position) {
+					t.Errorf("logits of position %d = %d values starting with %v", m.Position, len(m.Logits), m.Logits[0])
+				}
+			}
+			if !reflect.DeepEqual(positions, tt.wantMasks) {
+				t.Errorf("positions = %v, want %v", positions, tt.wantMasks)
+			}
+		})
+	}
+
+	if _, err := FindMaskLogits(bert, []string{"a", "b"}, make([]float32, 3)); err == nil {
+		t.Error("FindMaskLogits() with mismatched logits succeeded")
+	}
+	noMask := loadTestBPETokenizer(t, WithBPESpecialTokens(SpecialTokens{}))
+	if _, err := FindMaskLogits(noMask, []string{"hello"}, make([]float32, noMask.VocabSize())); err == nil {
+		t.Error("FindMaskLogits() without a mask token succeeded")
+	}
+}
@@ -0,0 +1,561 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TrainerOption configures vocabulary training
+type TrainerOption func(*trainerOptions)
+
+type trainerOptions struct {
+	vocabSize     int
+	minFrequency  int
+	specialTokens *SpecialTokens
+	lowercase     bool
+}
+
+// WithVocabSize sets the target number of tokens, including special tokens and
+// the initial alphabet. Training stops earlier when no pair is frequent enough.
+func WithVocabSize(size int) TrainerOption {
+	return func(o *trainerOptions) {
+		o.vocabSize = size
+	}
+}
+
+// WithMinFrequency sets how often a pair of symbols must occur in the corpus to
+// be merged into a new token
+func WithMinFrequency(count int) TrainerOption {
+	return func(o *trainerOptions) {
+		o.minFrequency = count
+	}
+}
+
+// WithTrainerSpecialTokens sets the special tokens placed at the start of the
+// vocabulary. They default to the BERT tokens for WordPiece and the RoBERTa
+// tokens for BPE.
+func WithTrainerSpecialTokens(tokens SpecialTokens) TrainerOption {
+	return func(o *trainerOptions) {
+		o.specialTokens = &tokens
+	}
+}
+
+// WithTrainerLowerCase sets whether WordPiece training lower-cases text and
+// strips accents like uncased BERT models, which is the default
+func WithTrainerLowerCase(enabled bool) TrainerOption {
+	return func(o *trainerOptions) {
+		o.lowercase = enabled
+	}
+}
+
+// TrainedVocabulary is a vocabulary learned from a corpus by TrainWordPiece or
+// TrainBPE
+type TrainedVocabulary struct {
+	// Tokens lists the tokens in id order
+	Tokens []string
+	// Merges lists the BPE merges in rank order. It is nil for WordPiece.
+	Merges [][2]string
+	// SpecialTokens are the special tokens at the start of the vocabulary
+	SpecialTokens SpecialTokens
+
+	ids       map[string]int
+	bpe       bool
+	lowercase bool
+}
+
+// TrainWordPiece learns a WordPiece vocabulary from a corpus read line by line.
+// Text is normalized and split into words like NewBERTTokenizer does, and pieces
+// are grown from single characters by merging the most frequent adjacent pairs.
+func TrainWordPiece(r io.Reader, opts ...TrainerOption) (*TrainedVocabulary, error) {
+	options := newTrainerOptions(DefaultSpecialTokens(), opts)
+	if options.specialTokens.UNK == "" {
+		return nil, fmt.Errorf("WordPiece needs an unknown token")
+	}
+
+	normalizer := normalizerSequence{
+		unicodeNormalizer{form: norm.NFC},
+		bertNormalizer{cleanText: true, handleChineseChars: true, stripAccents: options.lowercase, lowercase: options.lowercase},
+	}
+	counts, err := countWords(r, normalizer, bertPreTokenizer{})
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]trainWord, 0, len(counts))
+	alphabet := make(map[string]bool)
+	for word, count := range counts {
+		var symbols []string
+		for i, r := range word {
+			symbol := string(r)
+			if i > 0 {
+				symbol = "##" + symbol
+			}
+			symbols = append(symbols, symbol)
+			alphabet[symbol] = true
+		}
+		words = append(words, trainWord{symbols: symbols, count: count})
+	}
+
+	join := func(a, b string) string {
+		return a + strings.TrimPrefix(b, "##")
+	}
+	v := newTrainedVocabulary(*options.specialTokens, sortedKeys(alphabet))
+	v.lowercase = options.lowercase
+	for _, merge := range learnMerges(words, options.vocabSize-len(v.Tokens), options.minFrequency, join) {
+		v.add(join(merge[0], merge[1]))
+	}
+	return v, nil
+}
+
+// TrainBPE learns a byte-level BPE vocabulary and its merges from a corpus read
+// line by line. Text is split into words like NewBPETokenizer does, and every
+// byte is part of the initial alphabet so that any text can be encoded.
+func TrainBPE(r io.Reader, opts ...TrainerOption) (*TrainedVocabulary, error) {
+	options := newTrainerOptions(DefaultBPESpecialTokens(), opts)
+	counts, err := countWords(r, nil, byteLevel{useRegex: true})
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]trainWord, 0, len(counts))
+	for word, count := range counts {
+		var symbols []string
+		for _, r := range word {
+			symbols = append(symbols, string(r))
+		}
+		words = append(words, trainWord{symbols: symbols, count: count})
+	}
+
+	v := newTrainedVocabulary(*options.specialTokens, byteToUnicode[:])
+	v.bpe = true
+	join := func(a, b string) string {
+		return a + b
+	}
+	v.Merges = learnMerges(words, options.vocabSize-len(v.Tokens), options.minFrequency, join)
+	for _, merge := range v.Merges {
+		v.add(join(merge[0], merge[1]))
+	}
+	return v, nil
+}
+
+func newTrainerOptions(special SpecialTokens, opts []TrainerOption) *trainerOptions {
+	options := &trainerOptions{vocabSize: 30000, minFrequency: 2, lowercase: true}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.specialTokens == nil {
+		options.specialTokens = &special
+	}
+	return options
+}
+
+// newTrainedVocabulary starts a vocabulary with the special tokens followed by
+// the initial alphabet
+func newTrainedVocabulary(special SpecialTokens, alphabet []string) *TrainedVocabulary {
+	v := &TrainedVocabulary{SpecialTokens: special, ids: make(map[string]int)}
+	for _, token := range special.list() {
+		v.add(token)
+	}
+	for _, symbol := range alphabet {
+		v.add(symbol)
+	}
+	return v
+}
+
+// list returns the tokens that are set in the order they get ids
+func (s SpecialTokens) list() []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	for _, token := range []string{s.PAD, s.UNK, s.CLS, s.SEP, s.MASK} {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// add appends a token unless the vocabulary already has it. Different merges can
+// produce the same token.
+func (v *TrainedVocabulary) add(token string) {
+	if _, ok := v.ids[token]; !ok {
+		v.ids[token] = len(v.Tokens)
+		v.Tokens = append(v.Tokens, token)
+	}
+}
+
+// countWords splits every line of the corpus into words and counts them
+func countWords(r io.Reader, normalizer normalizer, preTokenizer preTokenizer) (map[string]int, error) {
+	counts := make(map[string]int)
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			n := newNormalized(strings.TrimRight(line, "\r\n"), 0)
+			if normalizer != nil {
+				normalizer.normalize(n)
+			}
+			for _, word := range preTokenizer.preTokenize([]*normalized{n}) {
+				if word.text != "" {
+					counts[word.text]++
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus: %w", err)
+		}
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("corpus has no words")
+	}
+	return counts, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// trainWord is a distinct word of the corpus split into its current symbols
+type trainWord struct {
+	symbols []string
+	count   int
+}
+
+type symbolPair [2]string
+
+// pairCount is a candidate merge. The heap holds stale counts too, which are
+// skipped when they reach the top.
+type pairCount struct {
+	pair  symbolPair
+	count int
+}
+
+type pairHeap []pairCount
+
+func (h pairHeap) Len() int { return len(h) }
+
+func (h pairHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count > h[j].count
+	}
+	if h[i].pair[0] != h[j].pair[0] {
+		return h[i].pair[0] < h[j].pair[0]
+	}
+	return h[i].pair[1] < h[j].pair[1]
+}
+
+func (h pairHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *pairHeap) Push(x any) { *h = append(*h, x.(pairCount)) }
+
+func (h *pairHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// learnMerges repeatedly merges the most frequent adjacent pair of symbols, ties
+// broken by the pair itself, until n new tokens are made or no pair occurs at
+// least minFrequency times. The symbols of the words are merged in place.
+func learnMerges(words []trainWord, n, minFrequency int, join func(a, b string) string) [][2]string {
+	counts := make(map[symbolPair]int)
+	where := make(map[symbolPair]map[int]bool)
+	for i, w := range words {
+		for j := 1; j < len(w.symbols); j++ {
+			pair := symbolPair{w.symbols[j-1], w.symbols[j]}
+			counts[pair] += w.count
+			if where[pair] == nil {
+				where[pair] = make(map[int]bool)
+			}
+			where[pair][i] = true
+		}
+	}
+	h := make(pairHeap, 0, len(counts))
+	for pair, count := range counts {
+		h = append(h, pairCount{pair, count})
+	}
+	heap.Init(&h)
+
+	var merges [][2]string
+	made := make(map[string]bool)
+	for len(made) < n && h.Len() > 0 {
+		top := heap.Pop(&h).(pairCount)
+		if counts[top.pair] != top.count {
+			continue
+		}
+		if top.count < max(minFrequency, 1) {
+			break
+		}
+		merges = append(merges, top.pair)
+		made[join(top.pair[0], top.pair[1])] = true
+
+		changed := make(map[symbolPair]bool)
+		indices := make([]int, 0, len(where[top.pair]))
+		for i := range where[top.pair] {
+			indices = append(indices, i)
+		}
+		sort.Ints(indices)
+		for _, i := range indices {
+			w := &words[i]
+			merged := mergeSymbols(w.symbols, top.pair, join)
+			if len(merged) == len(w.symbols) {
+				continue
+			}
+			for j := 1; j < len(w.symbols); j++ {
+				pair := symbolPair{w.symbols[j-1], w.symbols[j]}
+				counts[pair] -= w.count
+				changed[pair] = true
+			}
+			for j := 1; j < len(merged); j++ {
+				pair := symbolPair{merged[j-1], merged[j]}
+				counts[pair] += w.count
+				changed[pair] = true
+				if where[pair] == nil {
+					where[pair] = make(map[int]bool)
+				}
+				where[pair][i] = true
+			}
+			w.symbols = merged
+		}
+		delete(where, top.pair)
+		for pair := range changed {
+			if counts[pair] > 0 {
+				heap.Push(&h, pairCount{pair, counts[pair]})
+			} else {
+				delete(counts, pair)
+			}
+		}
+	}
+	return merges
+}
+
+// mergeSymbols joins every non-overlapping occurrence of pair from left to right
+func mergeSymbols(symbols []string, pair symbolPair, join func(a, b string) string) []string {
+	var out []string
+	for i := 0; i < len(symbols); i++ {
+		if i+1 < len(symbols) && symbols[i] == pair[0] && symbols[i+1] == pair[1] {
+			if out == nil {
+				out = append(make([]string, 0, len(symbols)-1), symbols[:i]...)
+			}
+			out = append(out, join(pair[0], pair[1]))
+			i++
+			continue
+		}
+		if out != nil {
+			out = append(out, symbols[i])
+		}
+	}
+	if out == nil {
+		return symbols
+	}
+	return out
+}
+
+// WriteVocab writes the vocabulary as vocab.txt, one token per line, for
+// WordPiece and as vocab.json for BPE
+func (v *TrainedVocabulary) WriteVocab(w io.Writer) error {
+	var b bytes.Buffer
+	if !v.bpe {
+		for _, token := range v.Tokens {
+			b.WriteString(token)
+			b.WriteByte('\n')
+		}
+	} else if err := v.writeVocabJSON(&b); err != nil {
+		return err
+	}
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("failed to write vocab: %w", err)
+	}
+	return nil
+}
+
+// writeVocabJSON writes a JSON object from token to id with the keys in id order
+func (v *TrainedVocabulary) writeVocabJSON(b *bytes.Buffer) error {
+	b.WriteByte('{')
+	for id, token := range v.Tokens {
+		key, err := marshalJSON(token)
+		if err != nil {
+			return err
+		}
+		if id > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(key)
+		fmt.Fprintf(b, ":%d", id)
+	}
+	b.WriteByte('}')
+	return nil
+}
+
+// WriteMerges writes the BPE merges as merges.txt
+func (v *TrainedVocabulary) WriteMerges(w io.Writer) error {
+	if !v.bpe {
+		return fmt.Errorf("WordPiece vocabularies have no merges")
+	}
+	var b bytes.Buffer
+	b.WriteString("#version: 0.2\n")
+	for _, merge := range v.Merges {
+		b.WriteString(merge[0] + " " + merge[1] + "\n")
+	}
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("failed to write merges: %w", err)
+	}
+	return nil
+}
+
+// WriteTokenizerJSON writes the vocabulary as a tokenizer.json file with the same
+// normalization, pre-tokenization, special tokens and decoding as the tokenizer
+// it was trained for
+func (v *TrainedVocabulary) WriteTokenizerJSON(w io.Writer) error {
+	special := v.SpecialTokens
+	pair := func(token string) []any {
+		return []any{token, v.ids[token]}
+	}
+
+	var added []hfAddedToken
+	for _, token := range special.list() {
+		added = append(added, hfAddedToken{
+			ID:      int64(v.ids[token]),
+			Content: token,
+			LStrip:  v.bpe && token == special.MASK,
+			Special: true,
+		})
+	}
+
+	var vocab bytes.Buffer
+	file := map[string]any{
+		"version":      "1.0",
+		"truncation":   nil,
+		"padding":      nil,
+		"added_tokens": added,
+	}
+	if v.bpe {
+		if err := v.writeVocabJSON(&vocab); err != nil {
+			return err
+		}
+		merges := make([]string, len(v.Merges))
+		for i, merge := range v.Merges {
+			merges[i] = merge[0] + " " + merge[1]
+		}
+		byteLevel := map[string]any{"type": "ByteLevel", "add_prefix_space": false, "trim_offsets": true, "use_regex": true}
+		file["normalizer"] = nil
+		file["pre_tokenizer"] = byteLevel
+		file["decoder"] = byteLevel
+		file["model"] = map[string]any{
+			"type":                      "BPE",
+			"dropout":                   nil,
+			"unk_token":                 nil,
+			"continuing_subword_prefix": nil,
+			"end_of_word_suffix":        nil,
+			"fuse_unk":                  false,
+			"byte_fallback":             false,
+			"vocab":                     json.RawMessage(vocab.Bytes()),
+			"merges":                    merges,
+		}
+		file["post_processor"] = nil
+		if special.CLS != "" && special.SEP != "" {
+			file["post_processor"] = map[string]any{
+				"type":             "RobertaProcessing",
+				"sep":              pair(special.SEP),
+				"cls":              pair(special.CLS),
+				"trim_offsets":     true,
+				"add_prefix_space": false,
+			}
+		}
+	} else {
+		if err := v.writeVocabJSON(&vocab); err != nil {
+			return err
+		}
+		file["normalizer"] = map[string]any{
+			"type": "Sequence",
+			"normalizers": []any{
+				map[string]any{"type": "NFC"},
+				map[string]any{"type": "BertNormalizer", "clean_text": true, "handle_chinese_chars": true, "strip_accents": v.lowercase, "lowercase": v.lowercase},
+			},
+		}
+		file["pre_tokenizer"] = map[string]any{"type": "BertPreTokenizer"}
+		file["decoder"] = map[string]any{"type": "WordPiece", "prefix": "##", "cleanup": true}
+		file["model"] = map[string]any{
+			"type":                      "WordPiece",
+			"unk_token":                 special.UNK,
+			"continuing_subword_prefix": "##",
+			"max_input_chars_per_word":  100,
+			"vocab":                     json.RawMessage(vocab.Bytes()),
+		}
+		file["post_processor"] = nil
+		if special.CLS != "" && special.SEP != "" {
+			file["post_processor"] = map[string]any{
+				"type": "BertProcessing",
+				"sep":  pair(special.SEP),
+				"cls":  pair(special.CLS),
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(file); err != nil {
+		return fmt.Errorf("failed to write tokenizer: %w", err)
+	}
+	return nil
+}
+
+// Save writes vocab.txt for WordPiece, or vocab.json and merges.txt for BPE, and
+// tokenizer.json to a directory
+func (v *TrainedVocabulary) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	files := map[string]func(io.Writer) error{"tokenizer.json": v.WriteTokenizerJSON}
+	if v.bpe {
+		files["vocab.json"] = v.WriteVocab
+		files["merges.txt"] = v.WriteMerges
+	} else {
+		files["vocab.txt"] = v.WriteVocab
+	}
+	for name, write := range files {
+		var b bytes.Buffer
+		if err := write(&b); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// marshalJSON encodes a string without escaping HTML characters
+func marshalJSON(s string) ([]byte, error) {
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("token %q is not valid UTF-8", s)
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,132 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLearnMerges(t *testing.T) {
+	words := func() []trainWord {
+		return []trainWord{
+			{symbols: []string{"h", "u", "g"}, count: 10},
+			{symbols: []string{"p", "u", "g"}, count: 5},
+			{symbols: []string{"p", "u", "n"}, count: 12},
+			{symbols: []string{"b", "u", "n"}, count: 4},
+			{symbols: []string{"h", "u", "g", "s"}, count: 5},
+		}
+	}
+	join := func(a, b string) string { return a + b }
+
+	want := [][2]string{{"u", "g"}, {"u", "n"}, {"h", "ug"}}
+	if got := learnMerges(words(), 3, 2, join); !reflect.DeepEqual(got, want) {
+		t.Errorf("learnMerges() = %q, want %q", got, want)
+	}
+	if got := learnMerges(words(), 100, 16, join); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("learnMerges() with min frequency 16 = %q, want %q", got, want[:2])
+	}
+}
+
+func TestTrainWordPiece(t *testing.T) {
+	corpus := readCorpus(t)
+	v, err := TrainWordPiece(strings.NewReader(corpus), WithVocabSize(200))
+	if err != nil {
+		t.Fatalf("TrainWordPiece() error = %v", err)
+	}
+	if len(v.Tokens) != 200 {
+		t.Errorf("len(Tokens) = %d, want 200", len(v.Tokens))
+	}
+	if want := []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "[MASK]"}; !reflect.DeepEqual(v.Tokens[:5], want) {
+		t.Errorf("Tokens[:5] = %q, want %q", v.Tokens[:5], want)
+	}
+
+	dir := t.TempDir()
+	if err := v.Save(dir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	bert, err := LoadBERTTokenizer(filepath.Join(dir, "vocab.txt"))
+	if err != nil {
+		t.Fatalf("LoadBERTTokenizer() error = %v", err)
+	}
+	hf, err := LoadHuggingFaceTokenizer(filepath.Join(dir, "tokenizer.json"))
+	if err != nil {
+		t.Fatalf("LoadHuggingFaceTokenizer() error = %v", err)
+	}
+	checkTrainedTokenizers(t, corpus, bert, hf)
+
+	want, err := bert.Encode(corpus, 0)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if slices.Contains(want.Tokens, "[UNK]") {
+		t.Error("the training corpus encodes with unknown tokens")
+	}
+}
+
+func TestTrainBPE(t *testing.T) {
+	corpus := readCorpus(t)
+	v, err := TrainBPE(strings.NewReader(corpus), WithVocabSize(600), WithMinFrequency(3))
+	if err != nil {
+		t.Fatalf("TrainBPE() error = %v", err)
+	}
+	if len(v.Tokens) != len(v.Merges)+5+256 {
+		t.Errorf("len(Tokens) = %d with %d merges, want merges + 261", len(v.Tokens), len(v.Merges))
+	}
+
+	dir := t.TempDir()
+	if err := v.Save(dir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	bpe, err := LoadBPETokenizer(filepath.Join(dir, "vocab.json"), filepath.Join(dir, "merges.txt"))
+	if err != nil {
+		t.Fatalf("LoadBPETokenizer() error = %v", err)
+	}
+	hf, err := LoadHuggingFaceTokenizer(filepath.Join(dir, "tokenizer.json"))
+	if err != nil {
+		t.Fatalf("LoadHuggingFaceTokenizer() error = %v", err)
+	}
+	checkTrainedTokenizers(t, corpus, bpe, hf)
+
+	text := "Ünïcode 👋 <mask> and new words"
+	out, err := bpe.Encode(text, 0)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if got, err := bpe.Decode(out.InputIds, true); err != nil || got != strings.Replace(text, " <mask>", "", 1) {
+		t.Errorf("Decode(Encode(%q)) = %q, %v", text, got, err)
+	}
+}
+
+// checkTrainedTokenizers checks that the vocabulary files and tokenizer.json
+// written for a trained vocabulary encode every line alike
+func checkTrainedTokenizers(t *testing.T, corpus string, a, b Tokenizer) {
+	t.Helper()
+	if a.VocabSize() != b.VocabSize() {
+		t.Errorf("VocabSize() = %d and %d", a.VocabSize(), b.VocabSize())
+	}
+	for _, line := range strings.Split(corpus, "\n") {
+		want, err := a.Encode(line, 0)
+		if err != nil {
+			t.Fatalf("Encode(%q) error = %v", line, err)
+		}
+		got, err := b.Encode(line, 0)
+		if err != nil {
+			t.Fatalf("Encode(%q) error = %v", line, err)
+		}
+		if !reflect.DeepEqual(got.Tokens, want.Tokens) || !reflect.DeepEqual(got.InputIds, want.InputIds) {
+			t.Errorf("Encode(%q) = %q, want %q", line, got.Tokens, want.Tokens)
+		}
+	}
+}
+
+func readCorpus(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "corpus.txt"))
+	if err != nil {
+		t.Fatalf("failed to read corpus: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,106 @@
+package tokenizer
+
+import "sort"
+
+// trie is a byte trie stored in flat arrays. The children of a node are a range
+// of edges sorted by byte, so lookups do not allocate or hash.
+type trie struct {
+	nodes []trieNode
+	edges []trieEdge
+}
+
+type trieNode struct {
+	// edges is the range of the children of the node in trie.edges
+	first, count int32
+	// id is the id of the token ending at the node, -1 if none
+	id    int64
+	value string
+}
+
+type trieEdge struct {
+	b    byte
+	node int32
+}
+
+// newTrie builds a trie of the keys and their ids. The token stored at the end
+// of a key is the key with the given prefix.
+func newTrie(keys map[string]int64, prefix string) *trie {
+	sorted := make([]string, 0, len(keys))
+	size := 1
+	for key := range keys {
+		if key != "" {
+			sorted = append(sorted, key)
+			size += len(key)
+		}
+	}
+	sort.Strings(sorted)
+
+	// every node covers the range of sorted keys sharing its prefix, laid out
+	// breadth first so that the children of a node are contiguous
+	type keyRange struct {
+		lo, hi, depth int
+	}
+	// keys share prefixes, so their total length bounds the number of nodes
+	t := &trie{nodes: make([]trieNode, 1, size), edges: make([]trieEdge, 0, size)}
+	t.nodes[0].id = -1
+	queue := make([]keyRange, 1, size)
+	queue[0] = keyRange{0, len(sorted), 0}
+	for i := 0; i < len(queue); i++ {
+		r := queue[i]
+		lo := r.lo
+		if lo < r.hi && len(sorted[lo]) == r.depth {
+			t.nodes[i].id = keys[sorted[lo]]
+			t.nodes[i].value = prefix + sorted[lo]
+			lo++
+		}
+		t.nodes[i].first = int32(len(t.edges))
+		for lo < r.hi {
+			b := sorted[lo][r.depth]
+			hi := lo + 1
+			for hi < r.hi && sorted[hi][r.depth] == b {
+				hi++
+			}
+			t.edges = append(t.edges, trieEdge{b: b, node: int32(len(queue))})
+			queue = append(queue, keyRange{lo, hi, r.depth + 1})
+			t.nodes = append(t.nodes, trieNode{id: -1})
+			lo = hi
+		}
+		t.nodes[i].count = int32(len(t.edges)) - t.nodes[i].first
+	}
+	return t
+}
+
+// child returns the child of a node along byte b, or -1
+func (t *trie) child(node int32, b byte) int32 {
+	n := &t.nodes[node]
+	edges := t.edges[n.first : n.first+n.count]
+	lo, hi := 0, len(edges)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if edges[mid].b < b {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(edges) && edges[lo].b == b {
+		return edges[lo].node
+	}
+	return -1
+}
+
+// longestPrefix returns the node of the longest key that is a prefix of s and
+// its length, or -1 when no key is
+func (t *trie) longestPrefix(s string) (node int32, length int) {
+	node, length = -1, 0
+	n := int32(0)
+	for i := 0; i < len(s); i++ {
+		if n = t.child(n, s[i]); n < 0 {
+			break
+		}
+		if t.nodes[n].id >= 0 {
+			node, length = n, i+1
+		}
+	}
+	return node, length
+}
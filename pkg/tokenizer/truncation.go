@@ -0,0 +1,265 @@
+package tokenizer
+
+import "fmt"
+
+// TruncationStrategy selects which sequences lose tokens when an encoding is
+// longer than the maximum length
+type TruncationStrategy int
+
+const (
+	// LongestFirst removes tokens one at a time from the longer sequence
+	LongestFirst TruncationStrategy = iota
+	// OnlyFirst truncates only the first sequence of a pair
+	OnlyFirst
+	// OnlySecond truncates only the second sequence of a pair
+	OnlySecond
+	// DoNotTruncate keeps every token, the output is only padded
+	DoNotTruncate
+)
+
+// Side is the end of a sequence that tokens are removed from or added to
+type Side int
+
+const (
+	// SideRight is the end of a sequence
+	SideRight Side = iota
+	// SideLeft is the start of a sequence
+	SideLeft
+)
+
+// EncodeOption configures a single call to Encode, EncodePair or EncodeBatch
+type EncodeOption func(*encodeOptions)
+
+type encodeOptions struct {
+	strategy TruncationStrategy
+	side     Side
+	// maxLength truncates encodings when the caller gives no maximum length, as
+	// the truncation stored in a tokenizer.json does
+	maxLength int
+	overflow  bool
+	stride    int
+	padding   PaddingStrategy
+	padSide   Side
+	multiple  int
+}
+
+// WithTruncation sets the truncation strategy, LongestFirst by default
+func WithTruncation(strategy TruncationStrategy) EncodeOption {
+	return func(o *encodeOptions) {
+		o.strategy = strategy
+	}
+}
+
+// WithTruncationSide sets the side tokens are removed from, SideRight by default
+func WithTruncationSide(side Side) EncodeOption {
+	return func(o *encodeOptions) {
+		o.side = side
+	}
+}
+
+// WithOverflow keeps the tokens removed by truncation as additional windows in
+// TokenizerOutput.Overflowing. Consecutive windows share stride tokens so that
+// no token loses all of its context at a window boundary. A negative stride uses
+// the stride of the truncation stored in a tokenizer.json, or 0. A pair truncated
+// LongestFirst gets a window for every combination of the windows of its two
+// sequences.
+func WithOverflow(stride int) EncodeOption {
+	return func(o *encodeOptions) {
+		o.overflow = true
+		if stride >= 0 {
+			o.stride = stride
+		}
+	}
+}
+
+// truncationLength returns the length encodings are truncated to, maxLength or
+// the default maximum length of the tokenizer when maxLength is 0
+func (o *encodeOptions) truncationLength(maxLength int) int {
+	if maxLength == 0 {
+		return o.maxLength
+	}
+	return maxLength
+}
+
+// slice returns the tokens of e in [start, end)
+func (e *encoding) slice(start, end int) *encoding {
+	return &encoding{
+		ids:       e.ids[start:end],
+		tokens:    e.tokens[start:end],
+		spans:     e.spans[start:end],
+		words:     e.words[start:end],
+		typeIDs:   e.typeIDs[start:end],
+		sequences: e.sequences[start:end],
+		special:   e.special[start:end],
+	}
+}
+
+// truncateSide keeps n tokens of e, removing the others from the given side
+func (e *encoding) truncateSide(n int, side Side) {
+	if side == SideRight {
+		e.truncate(n)
+		return
+	}
+	n = max(n, 0)
+	if n < e.len() {
+		*e = *e.slice(e.len()-n, e.len())
+	}
+}
+
+// windows splits e into windows of at most n tokens in text order, where
+// consecutive windows overlap by stride tokens. Windows are aligned with the
+// start of e when truncating from the right and with its end otherwise.
+func (e *encoding) windows(n, stride int, side Side) ([]*encoding, error) {
+	n = max(n, 0)
+	if e.len() <= n {
+		return []*encoding{e}, nil
+	}
+	if stride < 0 || stride >= n {
+		return nil, fmt.Errorf("stride %d must be smaller than the %d tokens available per window", stride, n)
+	}
+
+	step := n - stride
+	var windows []*encoding
+	if side == SideRight {
+		for start := 0; ; start += step {
+			end := min(start+n, e.len())
+			windows = append(windows, e.slice(start, end))
+			if end == e.len() {
+				break
+			}
+		}
+		return windows, nil
+	}
+	for end := e.len(); ; end -= step {
+		start := max(end-n, 0)
+		windows = append(windows, e.slice(start, end))
+		if start == 0 {
+			break
+		}
+	}
+	for i, j := 0, len(windows)-1; i < j; i, j = i+1, j-1 {
+		windows[i], windows[j] = windows[j], windows[i]
+	}
+	return windows, nil
+}
+
+// truncatePair removes tokens from the longer sequence until both fit in n
+// tokens, taking from b when they are equally long
+func truncatePair(a, b *encoding, n int, side Side) {
+	for a.len()+b.len() > max(n, 0) {
+		if a.len() > b.len() {
+			a.truncateSide(a.len()-1, side)
+		} else {
+			b.truncateSide(b.len()-1, side)
+		}
+	}
+}
+
+// truncate returns the windows of a single sequence that fit in n tokens, which
+// every strategy but DoNotTruncate truncates. The first window is the one kept
+// by truncation, followed by the overflowing ones.
+func (o *encodeOptions) truncate(enc *encoding, n int) ([]*encoding, error) {
+	if o.strategy == DoNotTruncate {
+		return []*encoding{enc}, nil
+	}
+	if !o.overflow {
+		enc.truncateSide(n, o.side)
+		return []*encoding{enc}, nil
+	}
+	windows, err := enc.windows(n, o.stride, o.side)
+	if err != nil {
+		return nil, err
+	}
+	return keptFirst(windows, o.side), nil
+}
+
+// truncatePair returns the pairs of windows that fit in n tokens, the pair kept
+// by truncation first
+func (o *encodeOptions) truncatePair(a, b *encoding, n int) ([][2]*encoding, error) {
+	switch o.strategy {
+	case DoNotTruncate:
+		return [][2]*encoding{{a, b}}, nil
+	case LongestFirst:
+		if o.overflow && a.len()+b.len() > n {
+			return o.longestFirstWindows(a, b, n)
+		}
+		truncatePair(a, b, n, o.side)
+		return [][2]*encoding{{a, b}}, nil
+	}
+
+	truncated, other := a, b
+	if o.strategy == OnlySecond {
+		truncated, other = b, a
+	}
+	if truncated.len()+other.len() <= n {
+		return [][2]*encoding{{a, b}}, nil
+	}
+	budget := n - other.len()
+	if budget <= 0 {
+		return nil, fmt.Errorf("the untruncated sequence has %d tokens and leaves no room in %d", other.len(), n)
+	}
+
+	windows := []*encoding{truncated}
+	if o.overflow {
+		var err error
+		if windows, err = truncated.windows(budget, o.stride, o.side); err != nil {
+			return nil, err
+		}
+		windows = keptFirst(windows, o.side)
+	} else {
+		truncated.truncateSide(budget, o.side)
+	}
+
+	pairs := make([][2]*encoding, len(windows))
+	for i, w := range windows {
+		if o.strategy == OnlySecond {
+			pairs[i] = [2]*encoding{other, w}
+		} else {
+			pairs[i] = [2]*encoding{w, other}
+		}
+	}
+	return pairs, nil
+}
+
+// longestFirstWindows splits both sequences of a pair into windows of the
+// lengths that truncating longest first leaves them, and pairs every window of
+// one sequence with every window of the other in the order of Hugging Face
+// tokenizers: the kept pair, then every other window of a with each window of b,
+// then the kept window of a with the other windows of b
+func (o *encodeOptions) longestFirstWindows(a, b *encoding, n int) ([][2]*encoding, error) {
+	// truncate copies to find the lengths, the windows are cut from a and b
+	ta, tb := *a, *b
+	truncatePair(&ta, &tb, n, o.side)
+
+	windowsA, err := a.windows(ta.len(), o.stride, o.side)
+	if err != nil {
+		return nil, err
+	}
+	windowsB, err := b.windows(tb.len(), o.stride, o.side)
+	if err != nil {
+		return nil, err
+	}
+	windowsA, windowsB = keptFirst(windowsA, o.side), keptFirst(windowsB, o.side)
+
+	pairs := make([][2]*encoding, 0, len(windowsA)*len(windowsB))
+	pairs = append(pairs, [2]*encoding{windowsA[0], windowsB[0]})
+	for _, wa := range windowsA[1:] {
+		for _, wb := range windowsB {
+			pairs = append(pairs, [2]*encoding{wa, wb})
+		}
+	}
+	for _, wb := range windowsB[1:] {
+		pairs = append(pairs, [2]*encoding{windowsA[0], wb})
+	}
+	return pairs, nil
+}
+
+// keptFirst moves the window that truncation keeps to the front, the last
+// window when truncating from the left
+func keptFirst(windows []*encoding, side Side) []*encoding {
+	if side == SideRight {
+		return windows
+	}
+	last := len(windows) - 1
+	return append([]*encoding{windows[last]}, windows[:last]...)
+}
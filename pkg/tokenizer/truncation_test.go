@@ -0,0 +1,167 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTruncation(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	const text = "one two three four five six"
+	tests := []struct {
+		name            string
+		a, b            string
+		pair            bool
+		maxLength       int
+		opts            []EncodeOption
+		wantTokens      []string
+		wantOverflowing [][]string
+	}{
+		{
+			name:       "right",
+			a:          text,
+			maxLength:  6,
+			wantTokens: []string{"[CLS]", "one", "two", "three", "four", "[SEP]"},
+		},
+		{
+			name:       "left",
+			a:          text,
+			maxLength:  6,
+			opts:       []EncodeOption{WithTruncationSide(SideLeft)},
+			wantTokens: []string{"[CLS]", "three", "four", "five", "six", "[SEP]"},
+		},
+		{
+			name:       "do not truncate",
+			a:          text,
+			maxLength:  4,
+			opts:       []EncodeOption{WithTruncation(DoNotTruncate)},
+			wantTokens: []string{"[CLS]", "one", "two", "three", "four", "five", "six", "[SEP]"},
+		},
+		{
+			name:       "overflow with stride",
+			a:          text,
+			maxLength:  6,
+			opts:       []EncodeOption{WithOverflow(1)},
+			wantTokens: []string{"[CLS]", "one", "two", "three", "four", "[SEP]"},
+			wantOverflowing: [][]string{
+				{"[CLS]", "four", "five", "six", "[SEP]", "[PAD]"},
+			},
+		},
+		{
+			name:       "left overflow",
+			a:          text,
+			maxLength:  6,
+			opts:       []EncodeOption{WithTruncationSide(SideLeft), WithOverflow(1)},
+			wantTokens: []string{"[CLS]", "three", "four", "five", "six", "[SEP]"},
+			wantOverflowing: [][]string{
+				{"[CLS]", "one", "two", "three", "[SEP]", "[PAD]"},
+			},
+		},
+		{
+			name:       "pair longest first",
+			a:          "one two",
+			b:          text,
+			pair:       true,
+			maxLength:  8,
+			wantTokens: []string{"[CLS]", "one", "two", "[SEP]", "one", "two", "three", "[SEP]"},
+		},
+		{
+			name:       "pair only first",
+			a:          text,
+			b:          "one two",
+			pair:       true,
+			maxLength:  8,
+			opts:       []EncodeOption{WithTruncation(OnlyFirst), WithTruncationSide(SideLeft)},
+			wantTokens: []string{"[CLS]", "four", "five", "six", "[SEP]", "one", "two", "[SEP]"},
+		},
+		{
+			name:       "pair only second with overflow",
+			a:          "one two",
+			b:          text,
+			pair:       true,
+			maxLength:  8,
+			opts:       []EncodeOption{WithTruncation(OnlySecond), WithOverflow(1)},
+			wantTokens: []string{"[CLS]", "one", "two", "[SEP]", "one", "two", "three", "[SEP]"},
+			wantOverflowing: [][]string{
+				{"[CLS]", "one", "two", "[SEP]", "three", "four", "five", "[SEP]"},
+				{"[CLS]", "one", "two", "[SEP]", "five", "six", "[SEP]", "[PAD]"},
+			},
+		},
+		{
+			name:       "pair longest first with overflow",
+			a:          text,
+			b:          "one two three four",
+			pair:       true,
+			maxLength:  9,
+			opts:       []EncodeOption{WithOverflow(0)},
+			wantTokens: []string{"[CLS]", "one", "two", "three", "[SEP]", "one", "two", "three", "[SEP]"},
+			wantOverflowing: [][]string{
+				{"[CLS]", "four", "five", "six", "[SEP]", "one", "two", "three", "[SEP]"},
+				{"[CLS]", "four", "five", "six", "[SEP]", "four", "[SEP]", "[PAD]", "[PAD]"},
+				{"[CLS]", "one", "two", "three", "[SEP]", "four", "[SEP]", "[PAD]", "[PAD]"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *TokenizerOutput
+			var err error
+			if tt.pair {
+				got, err = tok.EncodePair(tt.a, tt.b, tt.maxLength, tt.opts...)
+			} else {
+				got, err = tok.Encode(tt.a, tt.maxLength, tt.opts...)
+			}
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Tokens, tt.wantTokens) {
+				t.Errorf("Tokens = %q, want %q", got.Tokens, tt.wantTokens)
+			}
+			var overflowing [][]string
+			for _, o := range got.Overflowing {
+				overflowing = append(overflowing, o.Tokens)
+			}
+			if !reflect.DeepEqual(overflowing, tt.wantOverflowing) {
+				t.Errorf("Overflowing = %q, want %q", overflowing, tt.wantOverflowing)
+			}
+		})
+	}
+}
+
+func TestTruncationErrors(t *testing.T) {
+	tok, err := NewBERTTokenizer()
+	if err != nil {
+		t.Fatalf("NewBERTTokenizer() error = %v", err)
+	}
+
+	const text = "one two three four five six"
+	tests := []struct {
+		name      string
+		a, b      string
+		maxLength int
+		opts      []EncodeOption
+	}{
+		{name: "stride too large", a: text, maxLength: 6, opts: []EncodeOption{WithOverflow(4)}},
+		{name: "pair overflow stride too large", a: text, b: text, maxLength: 8, opts: []EncodeOption{WithOverflow(2)}},
+		{name: "second sequence too long", a: "one", b: text, maxLength: 8, opts: []EncodeOption{WithTruncation(OnlyFirst)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.b != "" {
+				_, err = tok.EncodePair(tt.a, tt.b, tt.maxLength, tt.opts...)
+			} else {
+				_, err = tok.Encode(tt.a, tt.maxLength, tt.opts...)
+			}
+			if err == nil {
+				t.Error("Encode() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package tokenizer
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// unkPenalty is subtracted from the lowest piece score to score unknown characters
+const unkPenalty = 10.0
+
+// unigramModel segments words with the Viterbi algorithm over piece log probabilities
+type unigramModel struct {
+	*vocabulary
+	scores       map[int64]float64
+	trie         *byteTrie
+	unkID        int64
+	hasUnk       bool
+	unkScore     float64
+	byteFallback bool
+}
+
+// unigramPiece is a vocabulary entry of a Unigram model
+type unigramPiece struct {
+	piece string
+	score float64
+	// matchable reports whether Viterbi segmentation may produce the piece
+	matchable bool
+}
+
+// newUnigramModel creates a Unigram model whose ids are the indices of pieces.
+// A negative unkID means the model has no unknown token.
+func newUnigramModel(pieces []unigramPiece, unkID int64, byteFallback bool) (*unigramModel, error) {
+	ids := make(map[string]int64, len(pieces))
+	m := &unigramModel{
+		scores:       make(map[int64]float64, len(pieces)),
+		trie:         newByteTrie(),
+		unkID:        unkID,
+		hasUnk:       unkID >= 0,
+		byteFallback: byteFallback,
+	}
+
+	minScore := math.Inf(1)
+	for i, p := range pieces {
+		id := int64(i)
+		if _, ok := ids[p.piece]; !ok {
+			ids[p.piece] = id
+		}
+		m.scores[id] = p.score
+		minScore = min(minScore, p.score)
+		if p.matchable && id != unkID {
+			m.trie.insert(p.piece, id)
+		}
+	}
+	if m.hasUnk && unkID >= int64(len(pieces)) {
+		return nil, fmt.Errorf("unknown token id %d is out of range for %d pieces", unkID, len(pieces))
+	}
+	if math.IsInf(minScore, 1) {
+		minScore = 0
+	}
+	m.unkScore = minScore - unkPenalty
+	m.vocabulary = newVocabulary(ids)
+	for i, p := range pieces {
+		// keep the first occurrence of duplicated pieces for token lookups but every id for decoding
+		m.tokens[int64(i)] = p.piece
+	}
+	return m, nil
+}
+
+func (m *unigramModel) tokenize(word string) ([]token, error) {
+	if word == "" {
+		return nil, nil
+	}
+
+	type node struct {
+		id    int64
+		start int
+		score float64
+		set   bool
+	}
+	best := make([]node, len(word)+1)
+	best[0].set = true
+
+	for start := 0; start < len(word); {
+		_, charLen := utf8.DecodeRuneInString(word[start:])
+		if best[start].set {
+			hasSingleChar := false
+			m.trie.prefixes(word[start:], func(length int, id int64) {
+				end := start + length
+				score := best[start].score + m.scores[id]
+				if !best[end].set || score > best[end].score {
+					best[end] = node{id: id, start: start, score: score, set: true}
+				}
+				if length == charLen {
+					hasSingleChar = true
+				}
+			})
+			if !hasSingleChar {
+				end := start + charLen
+				score := best[start].score + m.unkScore
+				if !best[end].set || score > best[end].score {
+					best[end] = node{id: m.unkID, start: start, score: score, set: true}
+				}
+			}
+		}
+		start += charLen
+	}
+
+	var reversed []token
+	for end := len(word); end > 0; {
+		n := best[end]
+		reversed = append(reversed, token{id: n.id, start: n.start, end: end})
+		end = n.start
+	}
+
+	tokens := make([]token, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		t := reversed[i]
+		if t.id == m.unkID && m.byteFallback {
+			if bytes, ok := byteFallbackTokens(m.vocabulary, word[t.start:t.end], t.start); ok {
+				for _, b := range bytes {
+					b.value = m.tokens[b.id]
+					tokens = append(tokens, b)
+				}
+				continue
+			}
+		}
+		if t.id == m.unkID {
+			if !m.hasUnk {
+				return nil, fmt.Errorf("cannot tokenize %q without an unknown token", word[t.start:t.end])
+			}
+			// consecutive unknown characters are fused into a single token
+			if len(tokens) > 0 && tokens[len(tokens)-1].id == m.unkID {
+				tokens[len(tokens)-1].end = t.end
+				continue
+			}
+		}
+		t.value = m.tokens[t.id]
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// byteTrie is a prefix tree over the bytes of vocabulary pieces
+type byteTrie struct {
+	children map[byte]*byteTrie
+	id       int64
+	leaf     bool
+}
+
+func newByteTrie() *byteTrie {
+	return &byteTrie{}
+}
+
+func (t *byteTrie) insert(key string, id int64) {
+	node := t
+	for i := 0; i < len(key); i++ {
+		if node.children == nil {
+			node.children = make(map[byte]*byteTrie)
+		}
+		child, ok := node.children[key[i]]
+		if !ok {
+			child = &byteTrie{}
+			node.children[key[i]] = child
+		}
+		node = child
+	}
+	if !node.leaf {
+		node.id, node.leaf = id, true
+	}
+}
+
+// prefixes calls fn for every key that is a prefix of s, shortest first
+func (t *byteTrie) prefixes(s string, fn func(length int, id int64)) {
+	node := t
+	for i := 0; i < len(s); i++ {
+		child, ok := node.children[s[i]]
+		if !ok {
+			return
+		}
+		node = child
+		if node.leaf {
+			fn(i+1, node.id)
+		}
+	}
+}